@@ -0,0 +1,62 @@
+package goimpl
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// checkAdapt finds the methods of Adapt the methods of the interface call (see GenOpts.Adapt).
+// The ones that can not be adapted are stubs, with the reason in the comment.
+func (opts *GenOpts) checkAdapt() error {
+	if opts.Adapt == nil {
+		return nil
+	}
+	if opts.Adapt.Kind() != reflect.Interface {
+		return diagf(NotAnInterface, opts.Adapt.String(), "", "Adapt is %s, not an interface", opts.Adapt.Kind())
+	}
+	if opts.Existing != nil || opts.FuncType || opts.Underlying != "" {
+		return diagf(InvalidOptions, "", "", "Adapt can not be set with Existing, FuncType or Underlying.")
+	}
+	if pkg, _ := packageAndName(opts.Adapt); pkg != opts.PkgName && opts.Adapt.PkgPath() != "" {
+		opts.Extra = append(opts.Extra, opts.Adapt.PkgPath())
+	}
+	opts.adapted = map[string]string{}
+	for _, m := range opts.Methods(opts.Inter) {
+		am, ok := opts.Adapt.MethodByName(m.Name)
+		if !ok {
+			opts.addComment(m.Name, "next has no "+m.Name+".")
+			continue
+		}
+		it, at := m.Type, am.Type
+		args := make([]string, len(m.Inputs))
+		for i, a := range m.Inputs {
+			args[i] = a.ArgName
+		}
+		switch {
+		case sameSignature(at, 0, it):
+		case it.NumIn() > 0 && it.In(0) == contextType && sameSignature(it, 1, at):
+			args = args[1:]
+		case at.NumIn() > 0 && at.In(0) == contextType && sameSignature(at, 1, it):
+			args = append([]string{"context.Background()"}, args...)
+		default:
+			opts.addComment(m.Name, "next."+m.Name+": "+opts.Method("", am).Diff(m)+".")
+			continue
+		}
+		call := strings.Join(args, ", ")
+		if m.Variadic {
+			call += "..."
+		}
+		opts.adapted[m.Name] = call
+	}
+	return nil
+}
+
+func (opts *GenOpts) addComment(name, comment string) {
+	if c := opts.Comments[name]; c != "" {
+		comment = c + " " + comment
+	}
+	opts.Comments[name] = comment
+}
@@ -0,0 +1,156 @@
+package goimpl
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+	Delete(keys ...string)
+	Len() int
+}
+
+type CtxStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Put(ctx context.Context, key, value string) error
+	Delete(ctx context.Context, keys ...string)
+	Len(ctx context.Context) (int, error)
+}
+
+// storesSrc declares Store and CtxStore, for type-checking the adapters.
+const storesSrc = `package goimpl
+
+import "context"
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+	Delete(keys ...string)
+	Len() int
+}
+
+type CtxStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Put(ctx context.Context, key, value string) error
+	Delete(ctx context.Context, keys ...string)
+	Len(ctx context.Context) (int, error)
+}
+`
+
+// checkAdapter type-checks the generated adapter with storesSrc.
+func checkAdapter(t *testing.T, name string, b []byte) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range []interface{}{storesSrc, b} {
+		f, err := parser.ParseFile(fset, "", src, 0)
+		if err != nil {
+			t.Fatalf("%s: %v in\n%s", name, err, b)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("goimpl", fset, files, nil); err != nil {
+		t.Errorf("%s: %v in\n%s", name, err, b)
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	opts := GenOpts{
+		PkgName:             "goimpl",
+		ImplName:            "*storeAdapter",
+		Inter:               reflect.TypeOf((*Store)(nil)).Elem(),
+		Adapt:               reflect.TypeOf((*CtxStore)(nil)).Elem(),
+		NoNamedReturnValues: true,
+		NoGoImports:         true,
+	}
+	bts, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAdapter(t, "Store from CtxStore", bts)
+	checkGenerated(t, "Store from CtxStore", string(bts), `package goimpl
+
+import (
+	"context"
+	"errors"
+)
+
+type storeAdapter struct {
+	next CtxStore
+}
+
+func (s *storeAdapter) Delete(s1 ...string) {
+	s.next.Delete(context.Background(), s1...)
+}
+
+func (s *storeAdapter) Get(s1 string) (string, error) {
+	return s.next.Get(context.Background(), s1)
+}
+
+// next.Len: number of inputs: had 1, want 0; number of outputs: had 2, want 1.
+func (s *storeAdapter) Len() int {
+	panic(errors.New("*storeAdapter.Len not implemented"))
+}
+
+func (s *storeAdapter) Put(s1 string, s2 string) error {
+	return s.next.Put(context.Background(), s1, s2)
+}
+`)
+
+	opts = GenOpts{
+		PkgName:             "goimpl",
+		ImplName:            "ctxStore",
+		Inter:               reflect.TypeOf((*CtxStore)(nil)).Elem(),
+		Adapt:               reflect.TypeOf((*Store)(nil)).Elem(),
+		NoNamedReturnValues: true,
+		NoGoImports:         true,
+		MethodBlacklist:     map[string]struct{}{"Len": {}},
+	}
+	if bts, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	checkAdapter(t, "CtxStore from Store", bts)
+	checkGenerated(t, "CtxStore from Store", string(bts), `package goimpl
+
+import (
+	"context"
+)
+
+type ctxStore struct {
+	next Store
+}
+
+func (c ctxStore) Delete(ctx context.Context, s ...string) {
+	c.next.Delete(s...)
+}
+
+func (c ctxStore) Get(ctx context.Context, s string) (string, error) {
+	return c.next.Get(s)
+}
+
+func (c ctxStore) Put(ctx context.Context, s string, s1 string) error {
+	return c.next.Put(s, s1)
+}
+`)
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	opts := GenOpts{ImplName: "T"}
+	var got []string
+	for _, m := range opts.Methods(reflect.TypeOf((*CtxStore)(nil)).Elem()) {
+		got = append(got, fmt.Sprint(m.Name, " ", m.HasContext(), " ", opts.ReturnsError(m), " ", len(opts.Results(m))))
+	}
+	expected := []string{"Delete true false 0", "Get true true 1", "Len true true 1", "Put true true 0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,313 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GenerateFromAST generates an empty implementation of the interface from syntax alone,
+// for the cases the code can not be type checked (e.g. unsaved editor buffers).
+// file is the file the interface is declared in: its package clause, imports and type declarations are used
+// to qualify the types. Packages imported without a name are assumed to be named after the last element of the path.
+// Embedded interfaces are only expanded if declared in the same file (or are the error interface).
+func GenerateFromAST(opts *GenOpts, inter *ast.InterfaceType, file *ast.File, out io.Writer) error {
+	c := newASTConverter(file)
+	it, err := c.interfaceType(inter)
+	if err != nil {
+		return err
+	}
+	if opts.PkgName == "" {
+		opts.PkgName = file.Name.Name
+	}
+	return GenerateFromTypes(opts, it, nil, out)
+}
+
+// astConverter builds go/types types from syntax.
+type astConverter struct {
+	pkg     *types.Package            // Package the file belongs to.
+	imports map[string]*types.Package // Imported packages by name.
+	decls   map[string]*ast.TypeSpec  // Types declared in the file.
+	named   map[string]*types.Named   // Named types by qualified name.
+}
+
+func newASTConverter(file *ast.File) *astConverter {
+	c := &astConverter{
+		pkg:     types.NewPackage(file.Name.Name, file.Name.Name),
+		imports: map[string]*types.Package{},
+		decls:   map[string]*ast.TypeSpec{},
+		named:   map[string]*types.Named{},
+	}
+	for _, imp := range file.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := guessPackageName(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		c.imports[name] = types.NewPackage(p, name)
+	}
+	for _, d := range file.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			c.decls[ts.Name.Name] = ts
+		}
+	}
+	return c
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// guessPackageName guesses the name of the package from the import path,
+// e.g. gopkg.in/yaml.v3 -> yaml, github.com/mattn/go-sqlite3 -> sqlite3, github.com/x/y/v2 -> y.
+func guessPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if majorVersion.MatchString(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return strings.Replace(name, "-", "_", -1)
+}
+
+func (c *astConverter) interfaceType(it *ast.InterfaceType) (*types.Interface, error) {
+	var methods []*types.Func
+	var embedded []types.Type
+	for _, f := range it.Methods.List {
+		if len(f.Names) == 0 {
+			t, ok, err := c.embedded(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				embedded = append(embedded, t)
+			}
+			continue
+		}
+		ft, ok := f.Type.(*ast.FuncType)
+		if !ok {
+			return nil, diagf(UnresolvableType, types.ExprString(f.Type), "", "unexpected method type %T", f.Type)
+		}
+		sig, err := c.signature(ft)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range f.Names {
+			methods = append(methods, types.NewFunc(n.Pos(), c.pkg, n.Name, sig))
+		}
+	}
+	return types.NewInterfaceType(methods, embedded).Complete(), nil
+}
+
+// embedded returns the embedded interface if its methods are known.
+func (c *astConverter) embedded(e ast.Expr) (types.Type, bool, error) {
+	id, ok := e.(*ast.Ident)
+	if !ok {
+		return nil, false, nil
+	}
+	if id.Name == "error" {
+		return types.Universe.Lookup("error").Type(), true, nil
+	}
+	if ts, ok := c.decls[id.Name]; !ok || !isInterface(ts.Type) {
+		return nil, false, nil
+	}
+	t, err := c.typ(id)
+	return t, err == nil, err
+}
+
+func isInterface(e ast.Expr) bool {
+	_, ok := e.(*ast.InterfaceType)
+	return ok
+}
+
+func (c *astConverter) signature(ft *ast.FuncType) (*types.Signature, error) {
+	params, variadic, err := c.tuple(ft.Params)
+	if err != nil {
+		return nil, err
+	}
+	results, _, err := c.tuple(ft.Results)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewSignatureType(nil, nil, nil, params, results, variadic), nil
+}
+
+func (c *astConverter) tuple(fl *ast.FieldList) (*types.Tuple, bool, error) {
+	if fl == nil {
+		return types.NewTuple(), false, nil
+	}
+	var vars []*types.Var
+	variadic := false
+	for _, f := range fl.List {
+		e := f.Type
+		if el, ok := e.(*ast.Ellipsis); ok {
+			e, variadic = &ast.ArrayType{Elt: el.Elt}, true
+		}
+		t, err := c.typ(e)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(f.Names) == 0 {
+			vars = append(vars, types.NewParam(f.Pos(), c.pkg, "", t))
+		}
+		for _, n := range f.Names {
+			vars = append(vars, types.NewParam(n.Pos(), c.pkg, n.Name, t))
+		}
+	}
+	return types.NewTuple(vars...), variadic, nil
+}
+
+// typ converts a type expression.
+func (c *astConverter) typ(e ast.Expr) (types.Type, error) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if ts, ok := c.decls[e.Name]; ok {
+			return c.namedType(c.pkg, e.Name, ts)
+		}
+		if tn, ok := types.Universe.Lookup(e.Name).(*types.TypeName); ok {
+			return tn.Type(), nil
+		}
+		// Declared in another file of the package.
+		return c.namedType(c.pkg, e.Name, nil)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil, diagf(UnresolvableType, types.ExprString(e), "", "unexpected type %s", types.ExprString(e))
+		}
+		p, ok := c.imports[x.Name]
+		if !ok {
+			return nil, diagf(UnresolvableType, types.ExprString(e), "import the package in the file.", "unknown package %s in %s", x.Name, types.ExprString(e))
+		}
+		return c.namedType(p, e.Sel.Name, nil)
+	case *ast.ParenExpr:
+		return c.typ(e.X)
+	case *ast.StarExpr:
+		t, err := c.typ(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(t), nil
+	case *ast.ArrayType:
+		elem, err := c.typ(e.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if e.Len == nil {
+			return types.NewSlice(elem), nil
+		}
+		lit, ok := e.Len.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return nil, diagf(UnresolvableType, types.ExprString(e), "use a constant literal.", "unsupported array length in %s", types.ExprString(e))
+		}
+		n, err := strconv.ParseInt(lit.Value, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewArray(elem, n), nil
+	case *ast.MapType:
+		k, err := c.typ(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := c.typ(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewMap(k, v), nil
+	case *ast.ChanType:
+		elem, err := c.typ(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		dir := types.SendRecv
+		switch e.Dir {
+		case ast.SEND:
+			dir = types.SendOnly
+		case ast.RECV:
+			dir = types.RecvOnly
+		}
+		return types.NewChan(dir, elem), nil
+	case *ast.FuncType:
+		return c.signature(e)
+	case *ast.InterfaceType:
+		return c.interfaceType(e)
+	case *ast.StructType:
+		var fields []*types.Var
+		var tags []string
+		for _, f := range e.Fields.List {
+			t, err := c.typ(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			tag := ""
+			if f.Tag != nil {
+				tag, _ = strconv.Unquote(f.Tag.Value)
+			}
+			if len(f.Names) == 0 {
+				fields = append(fields, types.NewField(f.Pos(), c.pkg, embeddedName(f.Type), t, true))
+				tags = append(tags, tag)
+			}
+			for _, n := range f.Names {
+				fields = append(fields, types.NewField(n.Pos(), c.pkg, n.Name, t, false))
+				tags = append(tags, tag)
+			}
+		}
+		return types.NewStruct(fields, tags), nil
+	}
+	return nil, diagf(UnresolvableType, types.ExprString(e), "", "unsupported type %s", types.ExprString(e))
+}
+
+// namedType returns the named type, creating it if needed.
+// The underlying type is only known for the types declared in the file, the rest get an empty struct.
+func (c *astConverter) namedType(p *types.Package, name string, ts *ast.TypeSpec) (types.Type, error) {
+	if ts != nil && ts.Assign.IsValid() {
+		// An alias.
+		return c.typ(ts.Type)
+	}
+	key := p.Path() + "." + name
+	if n, ok := c.named[key]; ok {
+		return n, nil
+	}
+	n := types.NewNamed(types.NewTypeName(token.NoPos, p, name, nil), nil, nil)
+	c.named[key] = n
+	var u types.Type = types.NewStruct(nil, nil)
+	if ts != nil {
+		t, err := c.typ(ts.Type)
+		if err != nil {
+			return nil, err
+		}
+		if t.Underlying() != nil {
+			u = t.Underlying()
+		}
+	}
+	n.SetUnderlying(u)
+	return n, nil
+}
+
+func embeddedName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const bufferSrc = `package editor
+
+import (
+	"context"
+	yml "gopkg.in/yaml.v3"
+	"github.com/x/go-store/v2"
+)
+
+type Node struct {
+	Next *Node
+}
+
+type Closer interface {
+	Close() error
+}
+
+type Buffer interface {
+	Closer
+	unknown.Embedded
+	Load(ctx context.Context, s store.Store, path string) (*Node, error)
+	Watch(ch chan<- yml.Node, done <-chan struct{}) func() [2]int
+}
+`
+
+func TestGenerateFromAST(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "buffer.go", bufferSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inter *ast.InterfaceType
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == "Buffer" {
+			inter = ts.Type.(*ast.InterfaceType)
+		}
+		return inter == nil
+	})
+	tc := []struct {
+		name     string
+		opts     GenOpts
+		expected string
+	}{
+		{
+			name: "same package",
+			opts: GenOpts{ImplName: "*fake", NoNamedReturnValues: true, NoGoImports: true},
+			expected: `package editor
+
+import (
+	"context"
+	"errors"
+	store "github.com/x/go-store/v2"
+	yml "gopkg.in/yaml.v3"
+)
+
+type fake struct{}
+
+func (f *fake) Close() error {
+	panic(errors.New("*fake.Close not implemented"))
+}
+
+func (f *fake) Load(ctx context.Context, s store.Store, s1 string) (*Node, error) {
+	panic(errors.New("*fake.Load not implemented"))
+}
+
+func (f *fake) Watch(n chan<- yml.Node, c <-chan struct{}) func() [2]int {
+	panic(errors.New("*fake.Watch not implemented"))
+}
+`,
+		},
+		{
+			name: "other package",
+			opts: GenOpts{PkgName: "fakes", ImplName: "Buffer", NoGoImports: true, MethodBlacklist: map[string]struct{}{"Watch": {}}},
+			expected: `package fakes
+
+import (
+	"context"
+	"editor"
+	"errors"
+	store "github.com/x/go-store/v2"
+)
+
+type Buffer struct{}
+
+func (b Buffer) Close() (err error) {
+	panic(errors.New("Buffer.Close not implemented"))
+}
+
+func (b Buffer) Load(ctx context.Context, s store.Store, s1 string) (n *editor.Node, err error) {
+	panic(errors.New("Buffer.Load not implemented"))
+}
+`,
+		},
+	}
+	for _, c := range tc {
+		var buf bytes.Buffer
+		if err := GenerateFromAST(&c.opts, inter, f, &buf); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		checkGenerated(t, c.name, buf.String(), c.expected)
+	}
+	for path, name := range map[string]string{
+		"io":                          "io",
+		"gopkg.in/yaml.v3":            "yaml",
+		"github.com/mattn/go-sqlite3": "sqlite3",
+		"github.com/x/y/v2":           "y",
+	} {
+		if got := guessPackageName(path); got != name {
+			t.Errorf("guessPackageName(%q) = %q, want %q", path, got, name)
+		}
+	}
+}
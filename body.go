@@ -0,0 +1,531 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// What the generated methods do (see GenOpts.Unimplemented).
+const (
+	UnimplementedPanic = "panic" // Panic with an error (the default).
+	UnimplementedError = "error" // Return an error (and zero values). Methods not returning an error panic.
+	UnimplementedZero  = "zero"  // Return zero values.
+	UnimplementedLog   = "log"   // Log and return zero values.
+	// UnimplementedLogFatal logs and exits (log.Fatal), so long-running processes leave the name of the method in the logs.
+	UnimplementedLogFatal = "logfatal"
+	// UnimplementedLogError logs and returns an error (like UnimplementedError) and zero values.
+	// Methods not returning an error log and return zero values.
+	UnimplementedLogError = "logerror"
+	// UnimplementedGRPC returns a status error with codes.Unimplemented, like the Unimplemented*Server types
+	// protoc-gen-go-grpc generates. Methods not returning an error panic with it.
+	UnimplementedGRPC = "grpc"
+	// UnimplementedSentinel returns ErrNotImplemented (declared in the output, unless GenOpts.Declared has it)
+	// and zero values, so callers can check for it with errors.Is. Methods not returning an error return zero values.
+	UnimplementedSentinel = "sentinel"
+)
+
+// sentinelName is the error returned with UnimplementedSentinel.
+const sentinelName = "ErrNotImplemented"
+
+var unimplementedModes = map[string]struct{}{
+	UnimplementedPanic:    {},
+	UnimplementedError:    {},
+	UnimplementedZero:     {},
+	UnimplementedLog:      {},
+	UnimplementedSentinel: {},
+	UnimplementedLogFatal: {},
+	UnimplementedLogError: {},
+	UnimplementedGRPC:     {},
+}
+
+func (opts *GenOpts) checkUnimplemented() error {
+	if _, ok := unimplementedModes[opts.Unimplemented]; !ok && opts.Unimplemented != "" {
+		return diagf(InvalidOptions, "", "use panic, error, zero, log, logfatal, logerror, sentinel or grpc.", "unknown Unimplemented mode %q", opts.Unimplemented)
+	}
+	for name, mode := range opts.UnimplementedFor {
+		if _, ok := unimplementedModes[mode]; !ok {
+			return diagf(InvalidOptions, name, "use panic, error, zero, log, logfatal, logerror, sentinel or grpc.", "unknown Unimplemented mode %q", mode)
+		}
+	}
+	return nil
+}
+
+// unimplemented returns the mode for the method.
+func (opts *GenOpts) unimplemented(name string) string {
+	if mode, ok := opts.UnimplementedFor[name]; ok {
+		return mode
+	}
+	if opts.Unimplemented == "" {
+		return UnimplementedPanic
+	}
+	return opts.Unimplemented
+}
+
+// BodyGenerator writes the bodies of the stubs (see GenOpts.BodyGenerator).
+type BodyGenerator interface {
+	// Body returns the statements of the body of the method (without the braces) and the import paths they use.
+	// The receiver is named GenOpts.Receiver().
+	Body(m Method) (stmts string, extraImports []string)
+}
+
+// BodyFunc is a BodyGenerator calling the func.
+type BodyFunc func(m Method) (stmts string, extraImports []string)
+
+// Body calls f.
+func (f BodyFunc) Body(m Method) (string, []string) {
+	return f(m)
+}
+
+// stub reports whether the method is a stub: not delegated, adapted, calling the func type, returning a field
+// or given a custom body.
+func (opts *GenOpts) stub(m Method) bool {
+	_, ok := opts.Bodies[m.Name]
+	return !ok && m.Delegate == "" && m.Field == "" && !m.Adapted && !m.Calls
+}
+
+// checkBodies reports the custom bodies that do not parse.
+func (opts *GenOpts) checkBodies() error {
+	for name, body := range opts.Bodies {
+		if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\nfunc f() {\n"+body+"\n}\n", 0); err != nil {
+			return diagf(InvalidOptions, name, "give the statements of the body, without the braces.", "invalid body of %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// callerStmt declares the location of the caller of the generated method (see GenOpts.CallerLocation).
+const callerStmt = "_, callerFile, callerLine, _ := runtime.Caller(1)\n"
+
+// Body returns the body of the generated method (without the braces).
+// rec is the name of the receiver.
+func (opts *GenOpts) Body(rec string, m Method) string {
+	if body, ok := opts.Bodies[m.Name]; ok {
+		return body
+	}
+	if m.Delegate != "" {
+		return opts.delegateBody(rec, m)
+	}
+	if m.Field != "" {
+		return "return " + rec + "." + m.Field
+	}
+	if m.Adapted {
+		call := rec + ".next." + m.Name + "(" + m.AdaptArgs + ")"
+		if len(m.Outputs) == 0 {
+			return call
+		}
+		return "return " + call
+	}
+	if m.Calls {
+		if strings.HasPrefix(opts.ImplName, "*") {
+			rec = "(*" + rec + ")"
+		}
+		et := opts.existingFunc()
+		return opts.forward(rec, et != nil && et.IsVariadic(), false, m)
+	}
+	if opts.BodyGenerator != nil {
+		stmts, _ := opts.BodyGenerator.Body(m)
+		return stmts
+	}
+	if opts.Helper != "" {
+		return opts.helperBody(m)
+	}
+	caller := ""
+	if opts.CallerLocation && !opts.StructuredErrors {
+		caller = callerStmt
+	}
+	switch opts.unimplemented(m.Name) {
+	case UnimplementedError:
+		if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+			return caller + "return " + strings.Join(append(opts.zeros(m.Outputs[:n-1]), opts.notImplemented(m)), ", ")
+		}
+	case UnimplementedZero:
+		return opts.returnZeros(m.Outputs)
+	case UnimplementedSentinel:
+		if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+			return "return " + strings.Join(append(opts.zeros(m.Outputs[:n-1]), sentinelName), ", ")
+		}
+		return opts.returnZeros(m.Outputs)
+	case UnimplementedLog:
+		return opts.logStmt("log.Print", m) + opts.returnZeros(m.Outputs)
+	case UnimplementedLogFatal:
+		return opts.logStmt("log.Fatal", m) + opts.returnZeros(m.Outputs)
+	case UnimplementedLogError:
+		if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+			return opts.logStmt("log.Print", m) + "return " + strings.Join(append(opts.zeros(m.Outputs[:n-1]), opts.notImplemented(m)), ", ")
+		}
+		return opts.logStmt("log.Print", m) + opts.returnZeros(m.Outputs)
+	case UnimplementedGRPC:
+		if opts.CallerLocation {
+			caller = callerStmt
+		}
+		if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+			return caller + "return " + strings.Join(append(opts.zeros(m.Outputs[:n-1]), opts.grpcError(m)), ", ")
+		}
+		return caller + "panic(" + opts.grpcError(m) + ")"
+	}
+	return caller + "panic(" + opts.notImplemented(m) + ")"
+}
+
+// splitHelper splits GenOpts.Helper into the import path ("" for the package of the generated code) and the function.
+func splitHelper(helper string) (pkgPath, fn string) {
+	i := strings.LastIndex(helper, ".")
+	if i < 0 || i < strings.LastIndex(helper, "/") {
+		return "", helper
+	}
+	return helper[:i], helper[i+1:]
+}
+
+// checkHelper reports an invalid Helper.
+func (opts *GenOpts) checkHelper() error {
+	if opts.Helper == "" {
+		return nil
+	}
+	if pkgPath, fn := splitHelper(opts.Helper); !token.IsIdentifier(fn) || strings.HasSuffix(pkgPath, "/") {
+		return diagf(InvalidOptions, opts.Helper, "use importpath.Func, or Func for a function of the generated package.", "invalid helper %q", opts.Helper)
+	}
+	return nil
+}
+
+// helperBody calls the Helper with the not implemented message and returns the error it returns,
+// if the method returns an error.
+func (opts *GenOpts) helperBody(m Method) string {
+	pkgPath, fn := splitHelper(opts.Helper)
+	if pkgPath != "" {
+		fn = importPathName(pkgPath) + "." + fn
+	}
+	msg := strconv.Quote(opts.message(m))
+	if format, args := opts.formatted(opts.message(m), m, false); len(args) > 0 {
+		msg = "fmt.Sprintf(" + strconv.Quote(format) + ", " + strings.Join(args, ", ") + ")"
+	}
+	call := fn + "(" + msg + ")"
+	if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+		return "return " + strings.Join(append(opts.zeros(m.Outputs[:n-1]), call), ", ")
+	}
+	if zeros := opts.returnZeros(m.Outputs); zeros != "" {
+		return call + "\n" + zeros
+	}
+	return call
+}
+
+// PanicData is what GenOpts.PanicFormat is executed with.
+type PanicData struct {
+	Interface string // The interface (e.g. io.Reader), if known (see GenOpts.InterName).
+	Impl      string // GenOpts.ImplName.
+	Method    string
+}
+
+// checkPanicFormat reports an invalid PanicFormat.
+func (opts *GenOpts) checkPanicFormat() error {
+	if opts.PanicFormat == "" {
+		return nil
+	}
+	t, err := template.New("panic").Parse(opts.PanicFormat)
+	if err == nil {
+		err = t.Execute(ioutil.Discard, PanicData{})
+	}
+	if err != nil {
+		return diagf(InvalidOptions, opts.PanicFormat, "use {{.Interface}}, {{.Impl}} and {{.Method}}.", "invalid panic format: %s", err.Error())
+	}
+	return nil
+}
+
+// message returns the message of the not implemented errors, panics and logs of the method.
+func (opts *GenOpts) message(m Method) string {
+	if opts.PanicFormat == "" {
+		return opts.ImplName + "." + m.Name + " not implemented"
+	}
+	data := PanicData{Interface: opts.interName(), Impl: opts.ImplName, Method: m.Name}
+	buf := new(bytes.Buffer)
+	template.Must(template.New("panic").Parse(opts.PanicFormat)).Execute(buf, data)
+	return buf.String()
+}
+
+// interName returns the name of the implemented interface (package.Name), "" if unknown.
+func (opts *GenOpts) interName() string {
+	if opts.Inter != nil {
+		return opts.Inter.String()
+	}
+	return opts.InterName
+}
+
+// escapeVerbs escapes the % in s, so it can be used in a format.
+func escapeVerbs(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// formatted returns the format of the not implemented message msg of the method and its arguments: the values of the inputs
+// with ArgValues, and the location of the caller (callerFile and callerLine) if caller is set.
+func (opts *GenOpts) formatted(msg string, m Method, caller bool) (format string, args []string) {
+	format = escapeVerbs(msg)
+	if opts.ArgValues {
+		var values []string
+		for _, a := range m.Inputs {
+			if a.ArgName == "_" {
+				continue
+			}
+			values = append(values, a.ArgName+"=%#v")
+			args = append(args, a.ArgName)
+		}
+		if len(values) > 0 {
+			format += " (" + strings.Join(values, ", ") + ")"
+		}
+	}
+	if caller {
+		format += " (called from %s:%d)"
+		args = append(args, "callerFile", "callerLine")
+	}
+	return format, args
+}
+
+// logStmt logs the not implemented message of the method with fn (log.Print or log.Fatal),
+// and the location of the caller with CallerLocation (declaring callerFile and callerLine).
+func (opts *GenOpts) logStmt(fn string, m Method) string {
+	format, args := opts.formatted(opts.message(m), m, opts.CallerLocation)
+	if len(args) == 0 {
+		return fn + "ln(" + strconv.Quote(opts.message(m)) + ")\n"
+	}
+	stmt := fn + "f(" + strconv.Quote(format) + ", " + strings.Join(args, ", ") + ")\n"
+	if opts.CallerLocation {
+		stmt = callerStmt + stmt
+	}
+	return stmt
+}
+
+// notImplemented returns the expression for the not implemented error.
+// With CallerLocation, callerFile and callerLine are expected to be declared (unless the error is structured).
+func (opts *GenOpts) notImplemented(m Method) string {
+	if !opts.StructuredErrors {
+		format, args := opts.formatted(opts.message(m), m, opts.CallerLocation)
+		if len(args) == 0 {
+			return "errors.New(" + strconv.Quote(opts.message(m)) + ")"
+		}
+		return "fmt.Errorf(" + strconv.Quote(format) + ", " + strings.Join(args, ", ") + ")"
+	}
+	inter := ""
+	if name := opts.interName(); name != "" {
+		inter = "Interface: " + strconv.Quote(name) + ", "
+	}
+	caller := ""
+	if opts.CallerLocation {
+		caller = ", Caller: notimpl.Caller()"
+	}
+	return "&notimpl.Error{" + inter + "Type: " + strconv.Quote(opts.ImplName) + ", Method: " + strconv.Quote(m.Name) + caller + "}"
+}
+
+// grpcError returns the expression for the status error of UnimplementedGRPC. The message is the one of
+// protoc-gen-go-grpc (method Name not implemented) unless PanicFormat is set.
+// With CallerLocation, callerFile and callerLine are expected to be declared.
+func (opts *GenOpts) grpcError(m Method) string {
+	msg := "method " + m.Name + " not implemented"
+	if opts.PanicFormat != "" {
+		msg = opts.message(m)
+	}
+	format, args := opts.formatted(msg, m, opts.CallerLocation)
+	return "status.Errorf(" + strings.Join(append([]string{"codes.Unimplemented", strconv.Quote(format)}, args...), ", ") + ")"
+}
+
+// delegateBody forwards the call to the field (see GenOpts.Delegate).
+// With WrapErrors, the returned error is wrapped with the name of the method.
+func (opts *GenOpts) delegateBody(rec string, m Method) string {
+	return opts.forward(rec+"."+m.Delegate+"."+m.Name, m.Variadic, opts.WrapErrors, m)
+}
+
+// forward calls fn with the arguments of the method and returns the results.
+// If wrap is set, the returned error is wrapped with the name of the method.
+func (opts *GenOpts) forward(fn string, variadic, wrap bool, m Method) string {
+	m.Variadic = variadic
+	call := fn + "(" + m.CallArgs() + ")"
+	n := len(m.Outputs)
+	if n == 0 {
+		return call
+	}
+	if !wrap || !opts.isError(m.Outputs[n-1]) {
+		return "return " + call
+	}
+	names := make([]string, n)
+	for i, a := range m.Outputs {
+		names[i] = a.ArgName
+	}
+	assign := " := "
+	if m.NamedResults {
+		assign = " = "
+	}
+	err := names[n-1]
+	return strings.Join(names, ", ") + assign + call + "\n" +
+		"if " + err + " != nil {\n" +
+		err + " = fmt.Errorf(" + strconv.Quote(opts.ImplName+"."+m.Name+": %w") + ", " + err + ")\n" +
+		"}\n" +
+		"return " + strings.Join(names, ", ")
+}
+
+// BodyImports returns the imports used by the bodies of the methods.
+func (opts *GenOpts) BodyImports() []string {
+	var errs, log, fmt, context, helper, grpc bool
+	var extra []string
+	for _, m := range opts.Methods(opts.Inter) {
+		if _, ok := opts.Bodies[m.Name]; ok {
+			continue
+		}
+		if m.Delegate != "" {
+			fmt = fmt || opts.WrapErrors && len(m.Outputs) > 0 && opts.isError(m.Outputs[len(m.Outputs)-1])
+			continue
+		}
+		if m.Adapted {
+			context = context || strings.HasPrefix(m.AdaptArgs, "context.Background()")
+			continue
+		}
+		if m.Calls || m.Field != "" {
+			continue
+		}
+		if opts.BodyGenerator != nil {
+			_, imps := opts.BodyGenerator.Body(m)
+			extra = append(extra, imps...)
+			continue
+		}
+		if opts.Helper != "" {
+			if pkgPath, _ := splitHelper(opts.Helper); pkgPath != "" {
+				extra = append(extra, pkgPath)
+			}
+			helper = true
+			continue
+		}
+		switch opts.unimplemented(m.Name) {
+		case UnimplementedZero, UnimplementedSentinel:
+		case UnimplementedLog, UnimplementedLogFatal:
+			log = true
+		case UnimplementedGRPC:
+			grpc = true
+		case UnimplementedLogError:
+			log = true
+			errs = errs || len(m.Outputs) > 0 && opts.isError(m.Outputs[len(m.Outputs)-1])
+		default:
+			errs = true
+		}
+	}
+	caller := opts.CallerLocation && (log || grpc || errs && !opts.StructuredErrors)
+	// With ArgValues the messages are formatted (the imports the file does not use are dropped).
+	fmt = fmt || errs && !opts.StructuredErrors && (opts.CallerLocation || opts.ArgValues) || helper && opts.ArgValues
+	var imports []string
+	if context {
+		imports = append(imports, "context")
+	}
+	if errs && !opts.StructuredErrors && !opts.CallerLocation || opts.Sentinel() != "" {
+		imports = append(imports, "errors")
+	}
+	if fmt {
+		imports = append(imports, "fmt")
+	}
+	if log {
+		imports = append(imports, "log")
+	}
+	if caller {
+		imports = append(imports, "runtime")
+	}
+	if errs && opts.StructuredErrors {
+		imports = append(imports, "github.com/sasha-s/goimpl/notimpl")
+	}
+	if grpc {
+		imports = append(imports, "google.golang.org/grpc/codes", "google.golang.org/grpc/status")
+	}
+	seen := map[string]bool{}
+	for _, imp := range imports {
+		seen[imp] = true
+	}
+	for _, imp := range extra {
+		if !seen[imp] {
+			seen[imp] = true
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+func (opts *GenOpts) returnZeros(outputs []Arg) string {
+	if len(outputs) == 0 {
+		return ""
+	}
+	return "return " + strings.Join(opts.zeros(outputs), ", ")
+}
+
+func (opts *GenOpts) zeros(args []Arg) []string {
+	zs := make([]string, len(args))
+	for i, a := range args {
+		zs[i] = opts.Zero(a)
+	}
+	return zs
+}
+
+func (opts *GenOpts) isError(a Arg) bool {
+	if a.T != nil {
+		return types.Identical(a.T, types.Universe.Lookup("error").Type())
+	}
+	return a.Type == errorType
+}
+
+// Zero returns the zero value of the type of the argument.
+func (opts *GenOpts) Zero(a Arg) string {
+	if a.T != nil {
+		return opts.typesZero(a.T)
+	}
+	switch a.Type.Kind() {
+	case reflect.Bool:
+		return "false"
+	case reflect.String:
+		return `""`
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return "0"
+	case reflect.Struct, reflect.Array:
+		return opts.GetName(a.Type) + "{}"
+	}
+	return "nil"
+}
+
+// typesZero is like Zero, for a type described by go/types.
+func (opts *GenOpts) typesZero(t types.Type) string {
+	if _, ok := t.(*types.TypeParam); ok {
+		return "*new(" + opts.typeString(t) + ")"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return opts.typeString(t) + "{}"
+	}
+	return "nil"
+}
+
+// Sentinel returns the declaration of ErrNotImplemented if the methods return it (see UnimplementedSentinel)
+// and it is not declared already, "" otherwise.
+func (opts *GenOpts) Sentinel() string {
+	for _, d := range opts.Declared {
+		if d == sentinelName {
+			return ""
+		}
+	}
+	for _, m := range opts.Methods(opts.Inter) {
+		if !opts.stub(m) || opts.BodyGenerator != nil || opts.Helper != "" || opts.unimplemented(m.Name) != UnimplementedSentinel {
+			continue
+		}
+		if n := len(m.Outputs); n > 0 && opts.isError(m.Outputs[n-1]) {
+			return "\n// " + sentinelName + " is returned by the methods that are not implemented yet.\n" +
+				"var " + sentinelName + " = errors.New(\"not implemented\")\n"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/imports"
+)
+
+// bootstrapData is used to render the bootstrap program.
+type bootstrapData struct {
+	Imports  []importSpec
+	Inters   []string // Interfaces the bootstrap program knows about.
+	Existing []string // Existing types the bootstrap program knows about.
+}
+
+type importSpec struct {
+	Name string // Empty unless the import is renamed.
+	Path string
+}
+
+// bootstrap returns the source of the bootstrap program for the targets.
+// The program knows about all the exported interfaces in the packages of the targets' interfaces,
+// so it can be reused (see cache.go) for any of them.
+// minimal is the source of the program that only knows about the targets' interfaces.
+func bootstrap(targets []GenOpts) (src []byte, minimal []byte, err error) {
+	d := bootstrapData{}
+	qualifiers := map[string]struct{}{}
+	known := map[string]struct{}{}
+	for _, t := range targets {
+		d.Inters = appendNew(d.Inters, t.Inter)
+		qualifiers[strings.SplitN(t.Inter, ".", 2)[0]] = struct{}{}
+		known[t.Inter] = struct{}{}
+		for _, e := range t.Extra {
+			if !containsImport(d.Imports, e) {
+				d.Imports = append(d.Imports, importSpec{Path: e})
+			}
+		}
+		if t.Existing != "" {
+			d.Existing = appendNew(d.Existing, t.Existing)
+		}
+		if t.Adapt != "" {
+			d.Inters = appendNew(d.Inters, t.Adapt)
+			qualifiers[strings.SplitN(t.Adapt, ".", 2)[0]] = struct{}{}
+			known[t.Adapt] = struct{}{}
+		}
+	}
+	sort.Strings(d.Inters)
+	sort.Strings(d.Existing)
+	// Let goimports figure out the packages.
+	if minimal, err = render(d); err != nil {
+		return nil, nil, err
+	}
+	if d.Imports, err = importsOf(minimal); err != nil {
+		return nil, nil, err
+	}
+	paths := make([]string, len(d.Imports))
+	for i, imp := range d.Imports {
+		paths[i] = imp.Path
+	}
+	pkgs, err := goList(targets[0].Dir, false, paths...)
+	if err != nil {
+		return minimal, minimal, nil
+	}
+	n := len(d.Inters)
+	for _, imp := range d.Imports {
+		p, ok := pkgs[imp.Path]
+		if !ok {
+			continue
+		}
+		qualifier := imp.Name
+		if qualifier == "" {
+			qualifier = p.Name
+		}
+		if _, ok := qualifiers[qualifier]; !ok {
+			continue
+		}
+		inters, err := exportedInterfaces(p)
+		if err != nil {
+			return minimal, minimal, nil
+		}
+		for _, name := range inters {
+			if name = qualifier + "." + name; !contains(known, name) {
+				d.Inters = append(d.Inters, name)
+				known[name] = struct{}{}
+			}
+		}
+	}
+	if len(d.Inters) == n {
+		return minimal, minimal, nil
+	}
+	sort.Strings(d.Inters)
+	src, err = render(d)
+	return src, minimal, err
+}
+
+func contains(set map[string]struct{}, s string) bool {
+	_, ok := set[s]
+	return ok
+}
+
+func appendNew(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func containsImport(specs []importSpec, path string) bool {
+	for _, s := range specs {
+		if s.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func render(d bootstrapData) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := tm.Execute(buf, d); err != nil {
+		return nil, err
+	}
+	return imports.Process("", buf.Bytes(), nil)
+}
+
+// importsOf returns the imports of the bootstrap program, except the ones it always has.
+func importsOf(src []byte) ([]importSpec, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "bootsrap.go", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var specs []importSpec
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if _, ok := bootstrapImports[path]; ok {
+			continue
+		}
+		spec := importSpec{Path: path}
+		if imp.Name != nil {
+			spec.Name = imp.Name.Name
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+var bootstrapImports = map[string]struct{}{
+	"encoding/json":             {},
+	"fmt":                       {},
+	"io":                        {},
+	"os":                        {},
+	"reflect":                   {},
+	"github.com/sasha-s/goimpl": {},
+}
+
+// exportedInterfaces returns the names of the exported, non-generic interfaces declared in the package.
+func exportedInterfaces(p listedPackage) ([]string, error) {
+	fset := token.NewFileSet()
+	var names []string
+	for _, name := range p.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, interfaceNames(f)...)
+	}
+	return names, nil
+}
+
+// interfaceNames returns the names of the exported, non-generic interfaces declared in the file.
+func interfaceNames(f *ast.File) []string {
+	var names []string
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.IsExported() && ts.TypeParams == nil {
+				names = append(names, ts.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+const templateS = `
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/sasha-s/goimpl"
+	{{range .Imports}}{{.Name}} "{{.Path}}"
+	{{end}}
+)
+
+var inters = map[string]reflect.Type{
+	{{range .Inters}}{{printf "%q" .}}: reflect.TypeOf((*{{.}})(nil)).Elem(),
+	{{end}}
+}
+
+var existing = map[string]interface{}{
+	{{range .Existing}}{{printf "%q" .}}: {{.}},
+	{{end}}
+}
+
+type job struct {
+	Inter    string
+	Existing string
+	Adapt    string
+	Opts     goimpl.GenOpts
+}
+
+// The jobs are read from stdin. For every job the result is written to stdout as
+// "goimpl <length of the code> <length of the error>\n<code><error>".
+// Diagnostics are written as JSON.
+func main() {
+	dec := json.NewDecoder(os.Stdin)
+	for {
+		var j job
+		if err := dec.Decode(&j); err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+		opts := j.Opts
+		opts.Inter = inters[j.Inter]
+		if j.Existing != "" {
+			opts.Existing = existing[j.Existing]
+		}
+		if j.Adapt != "" {
+			opts.Adapt = inters[j.Adapt]
+		}
+		code, err := goimpl.GenerateBytes(&opts)
+		msg := ""
+		if d, ok := err.(*goimpl.Diagnostic); ok {
+			b, _ := json.Marshal(d)
+			msg = string(b)
+		} else if err != nil {
+			msg = err.Error()
+		}
+		fmt.Printf("goimpl %d %d\n%s%s", len(code), len(msg), code, msg)
+	}
+}
+`
+
+var tm = template.New("bootstrap")
+
+func init() {
+	_, err := tm.Parse(templateS)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// The compiled bootstrap programs are cached, keyed by the goimpl version, the source of the bootstrap program
+// and the packages it depends on. Regenerating stubs for the interfaces from the same package skips the compilation.
+
+// listedPackage is the part of `go list -json` we need.
+type listedPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	Standard   bool
+	GoFiles    []string
+	Module     *struct {
+		Path    string
+		Version string
+		Replace *struct {
+			Path    string
+			Version string // Empty for the local replacements.
+		}
+	}
+}
+
+// goList lists the packages (and their dependencies if deps is set) as seen from the module in dir
+// (the current one if empty).
+func goList(dir string, deps bool, paths ...string) (map[string]listedPackage, error) {
+	args := []string{"list", "-e", "-json"}
+	if deps {
+		args = append(args, "-deps")
+	}
+	cmd := exec.Command("go", append(args, paths...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := map[string]listedPackage{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			return pkgs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs[p.ImportPath] = p
+	}
+}
+
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goimpl"), nil
+}
+
+// cleanCache removes all the cached bootstrap programs.
+func cleanCache() error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// cacheKey returns the key for the bootstrap program.
+// Packages from versioned modules are identified by the version, the rest by the content of the files.
+// Replaced modules are identified by the replacement, so forks replaced by a local directory are hashed too.
+func cacheKey(src []byte, dir string, paths []string) (string, error) {
+	pkgs, err := goList(dir, true, paths...)
+	if err != nil {
+		return "", err
+	}
+	return packagesKey(src, pkgs)
+}
+
+// packagesKey hashes the source of the bootstrap program with the packages it depends on (see cacheKey).
+func packagesKey(src []byte, pkgs map[string]listedPackage) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "goimpl %s\n%s\n", goimpl.Version, src)
+	for _, path := range sortedKeys(pkgs) {
+		p := pkgs[path]
+		if p.Standard {
+			continue
+		}
+		if m := p.Module; m != nil && m.Replace != nil {
+			if m.Replace.Version != "" {
+				fmt.Fprintf(h, "%s => %s@%s\n", p.ImportPath, m.Replace.Path, m.Replace.Version)
+				continue
+			}
+		} else if m != nil && m.Version != "" {
+			fmt.Fprintf(h, "%s@%s\n", p.ImportPath, m.Version)
+			continue
+		}
+		fmt.Fprintln(h, p.ImportPath)
+		for _, name := range p.GoFiles {
+			b, err := ioutil.ReadFile(filepath.Join(p.Dir, name))
+			if err != nil {
+				return "", err
+			}
+			h.Write(b)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys(m map[string]listedPackage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// build compiles the bootstrap program in the module in dir (the current one if empty)
+// and returns the path to the binary.
+// If useCache is set, the binary is looked up in (and added to) the cache.
+// Otherwise the binary is built in a temporary directory, which should be removed by the caller.
+// Compilation errors are reported as diagnostics about the interfaces.
+func build(src []byte, dir string, useCache bool, inters []string) (string, error) {
+	var bin string
+	if useCache {
+		cache, err := cacheDir()
+		if err != nil {
+			return "", err
+		}
+		imps, err := importsOf(src)
+		if err != nil {
+			return "", err
+		}
+		paths := []string{"github.com/sasha-s/goimpl"}
+		for _, imp := range imps {
+			paths = append(paths, imp.Path)
+		}
+		done := timed("resolve", "packages", paths)
+		key, err := cacheKey(src, dir, paths)
+		done(err, "key", key)
+		if err != nil {
+			return "", err
+		}
+		bin = filepath.Join(cache, key)
+		if _, err := os.Stat(bin); err == nil {
+			logger.Debug("build", "cached", true, "bin", bin)
+			return bin, nil
+		}
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	parent, prefix := tempParent(src, dir)
+	tempDir, err := ioutil.TempDir(parent, prefix)
+	if err != nil {
+		return "", err
+	}
+	// Generate a bootsrap.go that would generate the final code using reflection.
+	tempFile := filepath.Join(tempDir, "bootsrap.go")
+	if err = ioutil.WriteFile(tempFile, src, 0600); err != nil {
+		return "", err
+	}
+	tempBin := filepath.Join(tempDir, "bootstrap")
+	if useCache {
+		// Next to the cached binary: the temporary directory might be on another file system (e.g. in the module,
+		// see tempParent), where renaming fails.
+		tempBin = fmt.Sprintf("%s.%d.tmp", bin, os.Getpid())
+	}
+	cmd := exec.Command("go", "build", "-o", tempBin, tempFile)
+	cmd.Dir = dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	done := timed("build", "cached", false, "dir", dir)
+	err = cmd.Run()
+	done(err)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", compileError(stderr.String(), inters)
+	}
+	if !useCache {
+		return tempBin, nil
+	}
+	defer os.RemoveAll(tempDir)
+	// Rename is atomic, so concurrent invocations do not see partially written binaries.
+	if err := os.Rename(tempBin, bin); err != nil {
+		os.Remove(tempBin)
+		return "", err
+	}
+	return bin, nil
+}
+
+// tempParent returns where to create the directory of the bootstrap program: the system temporary directory or,
+// if the program imports internal packages (which can only be imported from within their module),
+// a hidden directory in the root of the module in dir (so ./... does not match it).
+func tempParent(src []byte, dir string) (parent, prefix string) {
+	imps, err := importsOf(src)
+	if err != nil {
+		return "", "goimpl_"
+	}
+	for _, imp := range imps {
+		if path := "/" + imp.Path + "/"; !strings.Contains(path, "/internal/") {
+			continue
+		}
+		gomod, err := goOutput(dir, "env", "GOMOD")
+		if err != nil || gomod == "" || gomod == os.DevNull {
+			break
+		}
+		return filepath.Dir(gomod), ".goimpl_"
+	}
+	return "", "goimpl_"
+}
+
+// result of a single generation.
+type result struct {
+	out []byte
+	err error
+}
+
+// run runs the bootstrap program for all the targets in a single process.
+func run(targets []GenOpts, useCache bool) ([]result, error) {
+	var bin string
+	err := errors.New("the targets are built in different modules")
+	if sameDir(targets) {
+		bin, err = compile(targets, useCache)
+	}
+	if err != nil && len(targets) > 1 {
+		// The targets might not get along in a single program (e.g. same package names from different paths).
+		results := make([]result, len(targets))
+		for i, t := range targets {
+			r, err := run([]GenOpts{t}, useCache)
+			if err != nil {
+				results[i].err = err
+				continue
+			}
+			results[i] = r[0]
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !useCache {
+		defer os.RemoveAll(filepath.Dir(bin))
+	}
+	in := new(bytes.Buffer)
+	enc := json.NewEncoder(in)
+	for _, t := range targets {
+		if err := enc.Encode(job{Inter: t.Inter, Existing: t.Existing, Adapt: t.Adapt, Opts: t}); err != nil {
+			return nil, err
+		}
+	}
+	out := new(bytes.Buffer)
+	cmd := exec.Command(bin)
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return readResults(out, len(targets))
+}
+
+func sameDir(targets []GenOpts) bool {
+	for _, t := range targets {
+		if t.Dir != targets[0].Dir {
+			return false
+		}
+	}
+	return true
+}
+
+// compile returns the path to the compiled bootstrap program for the targets.
+func compile(targets []GenOpts, useCache bool) (string, error) {
+	src, minimal, err := bootstrap(targets)
+	if err != nil {
+		return "", err
+	}
+	dir := targets[0].Dir
+	var inters []string
+	for _, t := range targets {
+		inters = appendNew(inters, t.Inter)
+	}
+	if bytes.Equal(src, minimal) {
+		return build(src, dir, useCache, inters)
+	}
+	bin, err := build(src, dir, useCache, inters)
+	if err != nil {
+		// Some of the other interfaces in the packages might be a problem, retry with just the ones we need.
+		return build(minimal, dir, useCache, inters)
+	}
+	return bin, nil
+}
+
+// readResults parses the output of the bootstrap program.
+func readResults(r io.Reader, n int) ([]result, error) {
+	br := bufio.NewReader(r)
+	results := make([]result, n)
+	for i := range results {
+		var codeLen, errLen int
+		if _, err := fmt.Fscanf(br, "goimpl %d %d\n", &codeLen, &errLen); err != nil {
+			return nil, fmt.Errorf("unexpected output from the bootstrap program: %v", err)
+		}
+		b := make([]byte, codeLen+errLen)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, fmt.Errorf("unexpected output from the bootstrap program: %v", err)
+		}
+		results[i].out = b[:codeLen]
+		if errLen > 0 {
+			results[i].err = decodeError(b[codeLen:])
+		}
+	}
+	return results, nil
+}
+
+// job is what the bootstrap program reads from stdin.
+type job struct {
+	Inter    string
+	Existing string
+	Adapt    string
+	Opts     GenOpts
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestPackagesKey(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.go"), []byte("package local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The packages as go list -json -deps describes them.
+	listed := func(dep, local string) map[string]listedPackage {
+		t.Helper()
+		pkgs := map[string]listedPackage{}
+		for _, src := range []string{`{"ImportPath": "fmt", "Standard": true}`, dep, local} {
+			var p listedPackage
+			if err := json.Unmarshal([]byte(src), &p); err != nil {
+				t.Fatal(err)
+			}
+			pkgs[p.ImportPath] = p
+		}
+		return pkgs
+	}
+	dep := `{"ImportPath": "example.com/dep", "Module": {"Path": "example.com/dep", "Version": "v1.0.0"}}`
+	local := `{"ImportPath": "example.com/app/local", "Dir": ` + strconv.Quote(dir) + `, "GoFiles": ["local.go"], "Module": {"Path": "example.com/app"}}`
+	key := func(src string, pkgs map[string]listedPackage) string {
+		t.Helper()
+		k, err := packagesKey([]byte(src), pkgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key("package main", listed(dep, local))
+	if k := key("package main", listed(dep, local)); k != base {
+		t.Errorf("the key changed without changes: %s, %s", base, k)
+	}
+	for name, k := range map[string]string{
+		"source":  key("package main\n\nfunc main() {}", listed(dep, local)),
+		"version": key("package main", listed(`{"ImportPath": "example.com/dep", "Module": {"Path": "example.com/dep", "Version": "v1.1.0"}}`, local)),
+		"replace": key("package main", listed(`{"ImportPath": "example.com/dep", "Module": {"Path": "example.com/dep", "Version": "v1.0.0",
+			"Replace": {"Path": "example.com/fork", "Version": "v1.0.0"}}}`, local)),
+	} {
+		if k == base {
+			t.Errorf("%s: the key did not change", name)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "local.go"), []byte("package local\n\nconst C = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if k := key("package main", listed(dep, local)); k == base {
+		t.Error("local package: the key did not change")
+	}
+}
+
+func TestSplitVersion(t *testing.T) {
+	for _, c := range []struct{ inter, pkgPath, name, version string }{
+		{"example.com/sdk.Client@v1.4.0", "example.com/sdk", "Client", "v1.4.0"},
+		{"example.com/sdk@v1.4.0.Client", "example.com/sdk", "Client", "v1.4.0"},
+		{"example.com/sdk@v0.0.0-20240101000000-abcdef123456.Client", "example.com/sdk", "Client", "v0.0.0-20240101000000-abcdef123456"},
+		{"example.com/sdk.Client@latest", "example.com/sdk", "Client", "latest"},
+		{"sdk.Client", "", "sdk.Client", ""},
+	} {
+		pkgPath, name, version, err := splitVersion(c.inter)
+		if err != nil {
+			t.Errorf("%s: %v", c.inter, err)
+			continue
+		}
+		if pkgPath != c.pkgPath || name != c.name || version != c.version {
+			t.Errorf("%s: got %q, %q, %q, expected %q, %q, %q", c.inter, pkgPath, name, version, c.pkgPath, c.name, c.version)
+		}
+	}
+	for _, inter := range []string{"example.com/sdk@v1.4.0", "example.com/sdk.Client@", "example.com/sdk.@v1.4.0"} {
+		if _, _, _, err := splitVersion(inter); err == nil {
+			t.Errorf("%s: expected an error", inter)
+		}
+	}
+}
@@ -0,0 +1,1248 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// commands maps the subcommands to their implementations. All of them share the global flags.
+// Without a subcommand, goimpl runs stub.
+var commands = map[string]func(cfg *config, args []string) error{
+	"stub":       stubCmd,
+	"check":      checkCmd,
+	"list":       listCmd,
+	"wrap":       wrapCmd,
+	"mock":       templateCmd(templates["mock"]),
+	"fanout":     fanoutCmd,
+	"fallback":   templateCmd(templates["fallback"]),
+	"tee":        templateCmd(templates["tee"]),
+	"toggle":     templateCmd(templates["toggle"]),
+	"swap":       templateCmd(templates["swap"]),
+	"events":     templateCmd(templates["events"]),
+	"counters":   templateCmd(templates["counters"]),
+	"near":       nearCmd,
+	"interfaces": interfacesCmd,
+	"providers":  providersCmd,
+}
+
+// templates maps the commands generating a type with a template to it.
+var templates = map[string]cmdTemplate{
+	"mock":     {text: mockTemplate},
+	"fallback": {text: fallbackTemplate, locals: []string{"errs", "next"}},
+	"tee":      {text: teeTemplate},
+	"toggle":   {text: toggleTemplate, methods: []string{"pick"}},
+	"swap":     {text: swapTemplate, methods: []string{"Swap", "Load"}},
+	"events":   {text: eventsTemplate, locals: []string{"start", "time"}, methods: []string{"Dropped", "emit"}},
+	"counters": {text: countersTemplate, methods: []string{"Publish", "Stats"}},
+}
+
+// stubCmd generates stub implementations: the legacy positional form.
+func stubCmd(cfg *config, args []string) error {
+	if *pos != "" {
+		if len(args) < 1 {
+			usage()
+		}
+		n := len(args)
+		return implementAt(*pos, args[n-1], append(cfg.Imports, args[:n-1]...), *output)
+	}
+	if len(args) == 0 && len(cfg.Targets) > 0 {
+		return generateAll(cfg)
+	}
+	if len(args) < 2 {
+		usage()
+	}
+	n := len(args)
+	opts, err := newOpts(args[n-2], args[n-1], *existing, cfg.Imports, args[:n-2])
+	if err != nil {
+		return err
+	}
+	return generateOne(opts)
+}
+
+// generateOne generates a single target and writes it to the output.
+func generateOne(opts GenOpts) error {
+	opts.OutDir = filepath.Dir(*output)
+	provenance(&opts, *output)
+	results, err := generate([]GenOpts{opts})
+	if err != nil {
+		return err
+	}
+	if results[0].err != nil {
+		return results[0].err
+	}
+	out, err := convert(results[0].out, *output, opts.Inter)
+	if err != nil {
+		return err
+	}
+	return write(*output, out)
+}
+
+// checkCmd reports the methods of the interface the existing type is missing (or has with a wrong signature).
+// It fails if there are any.
+func checkCmd(cfg *config, args []string) error {
+	if len(args) < 2 {
+		usage()
+	}
+	n := len(args)
+	opts, err := newOpts(args[n-2], args[n-1], true, cfg.Imports, args[:n-2])
+	if err != nil {
+		return err
+	}
+	opts.NoGoImports = true
+	decls, err := generateMethods(opts)
+	if err != nil {
+		return err
+	}
+	if len(decls) == 0 {
+		return nil
+	}
+	for _, d := range decls {
+		if d.Doc != nil {
+			fmt.Printf("%s: wrong signature: %s\n", d.Name.Name, strings.TrimSpace(d.Doc.Text()))
+		} else {
+			fmt.Printf("%s: missing\n", d.Name.Name)
+		}
+	}
+	return fmt.Errorf("%s does not implement %s", args[n-1], args[n-2])
+}
+
+// listCmd prints the methods of the interface, one per line.
+func listCmd(cfg *config, args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	n := len(args)
+	opts, err := newOpts(args[n-1], "T", false, cfg.Imports, args[:n-1])
+	if err != nil {
+		return err
+	}
+	opts.NoGoImports = true
+	decls, err := generateMethods(opts)
+	if err != nil {
+		return err
+	}
+	for _, d := range decls {
+		var b bytes.Buffer
+		if err := printer.Fprint(&b, token.NewFileSet(), d.Type); err != nil {
+			return err
+		}
+		fmt.Println(d.Name.Name + strings.TrimPrefix(b.String(), "func"))
+	}
+	return nil
+}
+
+// generateMethods generates the target and returns the declarations of the methods.
+func generateMethods(opts GenOpts) ([]*ast.FuncDecl, error) {
+	results, err := generate([]GenOpts{opts})
+	if err != nil {
+		return nil, err
+	}
+	if results[0].err != nil {
+		return nil, results[0].err
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", results[0].out, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var decls []*ast.FuncDecl
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			decls = append(decls, fd)
+		}
+	}
+	return decls, nil
+}
+
+// cmdTemplate is the template of a command generating a new type.
+type cmdTemplate struct {
+	text    string
+	locals  []string // The identifiers the method bodies use (see GenOpts.Locals).
+	methods []string // The methods (or fields) declared besides the ones of the interface (see GenOpts.ExtraMethods).
+}
+
+// apply sets the template of opts. interfacePlaceholder in the text is replaced with the (qualified) name of the interface,
+// interfaceNamePlaceholder with its name alone.
+func (t cmdTemplate) apply(opts *GenOpts) {
+	name := interName(*opts)
+	name = name[strings.LastIndex(name, ".")+1:]
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	// interfaceNamePlaceholder goes first: interfacePlaceholder is its prefix.
+	opts.Template = strings.NewReplacer(interfaceNamePlaceholder, name, interfacePlaceholder, interName(*opts)).Replace(t.text)
+	opts.Locals = t.locals
+	opts.ExtraMethods = t.methods
+}
+
+// templateCmd returns a command generating a type with the template (instead of -template).
+func templateCmd(t cmdTemplate) func(cfg *config, args []string) error {
+	return func(cfg *config, args []string) error {
+		opts, err := commandOpts(cfg, args)
+		if err != nil {
+			return err
+		}
+		t.apply(&opts)
+		return generateOne(opts)
+	}
+}
+
+var kind = flag.String("kind", "forward", "With wrap, the kind of decorator: forward (call next), logging (also log every call with log/slog), prometheus (also record the calls, the errors and the latencies), tracing (also start an OpenTelemetry span per call), retry (retry the failed calls with backoff), breaker (a circuit breaker per method), cache (cache the results of the lookups), ratelimit (wait for a rate.Limiter), mutex (lock a sync.Mutex around the calls), rwmutex (lock a sync.RWMutex, for reading around the read-only methods), hooks (call the BeforeCall and AfterCall funcs around the calls) or chaos (inject errors and panics at run time).")
+
+// wrapCmd generates a type forwarding the calls to the wrapped implementation, held in the field next:
+// a starting point for decorators (see goimpl.GenOpts.Forward), or a decorator logging the calls (-kind logging),
+// recording their metrics (-kind prometheus), tracing them (-kind tracing), retrying them (-kind retry)
+// failing them fast after repeated failures (-kind breaker), caching their results (-kind cache)
+// limiting their rate (-kind ratelimit), serializing them (-kind mutex and rwmutex), hooking them (-kind hooks)
+// or injecting faults (-kind chaos).
+func wrapCmd(cfg *config, args []string) error {
+	if t, ok := wrapKinds[*kind]; ok {
+		return templateCmd(t)(cfg, args)
+	}
+	if *kind != "forward" {
+		return fmt.Errorf("unknown -kind %q: use forward, logging, prometheus, tracing, retry, breaker, cache, ratelimit, mutex, rwmutex, hooks or chaos.", *kind)
+	}
+	opts, err := commandOpts(cfg, args)
+	if err != nil {
+		return err
+	}
+	opts.Forward = true
+	opts.NoNamedReturnValues = !opts.SmartReturnValues // The bodies return the results of the calls.
+	return generateOne(opts)
+}
+
+// wrapKinds maps the kinds of wrap, but forward, to their templates.
+var wrapKinds = map[string]cmdTemplate{
+	"logging":    {text: loggingTemplate, locals: []string{"start", "time"}, methods: []string{"log"}},
+	"prometheus": {text: prometheusTemplate, locals: []string{"start", "time"}, methods: []string{"observe"}},
+	"tracing":    {text: tracingTemplate, locals: []string{"span", "codes"}, methods: []string{"start"}},
+	"retry":      {text: retryTemplate, locals: []string{"attempt"}, methods: []string{"wait"}},
+	"breaker":    {text: breakerTemplate, methods: []string{"allow", "done"}},
+	"cache":      {text: cacheTemplate, locals: []string{"key", "v", "ok"}, methods: []string{"get", "put"}},
+	"ratelimit":  {text: rateLimitTemplate, methods: []string{"wait"}},
+	"mutex":      {text: strings.Replace(mutexTemplate, mutexPlaceholder, "sync.Mutex", -1)},
+	"rwmutex":    {text: strings.Replace(mutexTemplate, mutexPlaceholder, "sync.RWMutex", -1)},
+	"hooks":      {text: hooksTemplate, methods: []string{"BeforeCall", "AfterCall"}},
+	"chaos":      {text: chaosTemplate, methods: []string{"Inject", "fail"}},
+}
+
+// commandOpts returns the options of the commands generating a new type from the imports, the interface and the type.
+func commandOpts(cfg *config, args []string) (GenOpts, error) {
+	if len(args) < 2 {
+		usage()
+	}
+	if *existing || *pos != "" {
+		return GenOpts{}, errors.New("-existing and -pos can not be used with wrap and mock.")
+	}
+	n := len(args)
+	opts, err := newOpts(args[n-2], args[n-1], false, cfg.Imports, args[:n-2])
+	if err != nil {
+		return GenOpts{}, err
+	}
+	opts.BlankArgs = false // The methods forward the arguments.
+	return opts, nil
+}
+
+// interName returns the name of the interface as seen from the generated package.
+func interName(opts GenOpts) string {
+	pkg := strings.SplitN(opts.Inter, ".", 2)[0]
+	if pkg == opts.PkgName || opts.PkgName == "" {
+		return strings.TrimPrefix(opts.Inter, pkg+".")
+	}
+	return opts.Inter
+}
+
+const (
+	interfacePlaceholder     = "INTERFACE"
+	interfaceNamePlaceholder = "INTERFACE_NAME"
+)
+
+// mockTemplate generates a type with a func field per method. The methods call the funcs if they are set.
+const mockTemplate = `
+{{$R := .}}
+package {{.PkgName}}
+
+import (
+	{{range .BodyImports}}"{{.}}"
+	{{end}}{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} is a mock of ` + interfacePlaceholder + `.
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	{{range $R.Methods .Inter}}{{.Name}}Func func({{range .Inputs}}{{$R.GetName .}}{{.Sep}}{{end}}) ({{range .Outputs}}{{$R.GetName .}}{{.Sep}}{{end}})
+	{{end}}
+}
+
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	if {{$rec}}.{{.Name}}Func != nil {
+		{{if .Outputs}}return {{end}}{{$rec}}.{{.Name}}Func({{.CallArgs}}){{if not .Outputs}}
+		return{{end}}
+	}
+	{{$R.Body $rec .}} }
+{{end}}{{.Sentinel}}
+`
+
+// fanoutTemplate generates a type calling every implementation in next for each method:
+// concurrently (with errgroup) for the methods taking a context, in order otherwise.
+// The errors are joined, the other results are picked per resultsPlaceholder (see -results).
+const fanoutTemplate = `
+{{$R := .}}
+{{$policy := "` + resultsPlaceholder + `"}}
+package {{.PkgName}}
+
+import (
+	"errors"
+
+	"golang.org/x/sync/errgroup"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} calls every implementation of ` + interfacePlaceholder + ` in next.
+// The errors are joined, the other results come from the first implementation{{if eq $policy "ok"}} that succeeds (or the first one){{end}}.
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	next []` + interfacePlaceholder + `
+	{{if eq $policy "collect"}}// collect, if set, is called with the name of the method and the results (but the trailing error)
+	// of every implementation, in the order of next.
+	collect func(method string, results [][]interface{})
+	{{end}}
+}
+
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}{{$m := .}}{{$err := $R.ReturnsError .}}{{$res := $R.Results .}}{{$i := or $err $res}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $res}}results := make([]struct{ {{range $res}}{{.ArgName}} {{$R.GetName .}}; {{end}} }, len({{$rec}}.next))
+	{{end}}{{if $err}}errs := make([]error, len({{$rec}}.next))
+	{{end}}{{if .HasContext}}var g errgroup.Group
+	{{end}}for {{if $i}}idx{{else}}_{{end}}, next := range {{$rec}}.next {
+		{{if .HasContext}}{{if $i}}idx, {{end}}next := {{if $i}}idx, {{end}}next
+		g.Go(func() error {
+		{{end}}{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}next.{{.Name}}({{.CallArgs}})
+		{{range $res}}results[idx].{{.ArgName}} = {{.ArgName}}
+		{{end}}{{if $err}}errs[idx] = {{(index $m.Outputs (len $res)).ArgName}}
+		{{end}}{{if .HasContext}}return nil
+		})
+	{{end}}}
+	{{if .HasContext}}g.Wait()
+	{{end}}{{if $res}}{{if eq $policy "collect"}}if {{$rec}}.collect != nil {
+		all := make([][]interface{}, len({{$rec}}.next))
+		for idx := range all {
+			all[idx] = []interface{}{ {{range $res}}results[idx].{{.ArgName}}, {{end}} }
+		}
+		{{$rec}}.collect({{printf "%q" .Name}}, all)
+	}
+	{{end}}{{range $res}}var {{.ArgName}} {{$R.GetName .}}
+	{{end}}pick := 0
+	{{if and $err (eq $policy "ok")}}for idx := range errs {
+		if errs[idx] == nil {
+			pick = idx
+			break
+		}
+	}
+	{{end}}if pick < len({{$rec}}.next) {
+		{{range $res}}{{.ArgName}} = results[pick].{{.ArgName}}
+		{{end}}}
+	{{end}}{{if .Outputs}}return {{range $j, $o := $res}}{{if $j}}, {{end}}{{$o.ArgName}}{{end}}{{if $err}}{{if $res}}, {{end}}errors.Join(errs...){{end}}{{end}} }
+{{end}}
+`
+
+const resultsPlaceholder = "RESULTS"
+
+var results = flag.String("results", "first", "With fanout, the results of the methods (but the error): first (of the first implementation), ok (of the first implementation that succeeds) or collect (first, and all of them are passed to the collect field).")
+
+// fanoutCmd generates a type calling every implementation in a slice (see fanoutTemplate).
+func fanoutCmd(cfg *config, args []string) error {
+	switch *results {
+	case "first", "ok", "collect":
+	default:
+		return fmt.Errorf("unknown -results %q: use first, ok or collect.", *results)
+	}
+	return templateCmd(fanout(*results))(cfg, args)
+}
+
+// fanout returns fanoutTemplate with the results policy.
+func fanout(policy string) cmdTemplate {
+	return cmdTemplate{
+		text:   strings.Replace(fanoutTemplate, resultsPlaceholder, policy, -1),
+		locals: []string{"errgroup", "results", "errs", "g", "idx", "next", "all", "pick"},
+	}
+}
+
+// fallbackTemplate generates a type trying the implementations in next in order for the methods returning an error.
+const fallbackTemplate = `
+{{$R := .}}
+package {{.PkgName}}
+
+import (
+	"errors"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} tries the implementations of ` + interfacePlaceholder + ` in next in order.
+// The methods returning an error return the results of the first implementation that succeeds, or all the errors joined.
+// An error fallThrough returns false for stops the fallback, and so does a call ending with the context argument done:
+// the errors so far are returned, joined. The other methods call the first implementation (if any).
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	next        []` + interfacePlaceholder + `
+	fallThrough func(error) bool // Falls through on any error if nil.
+}
+
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $R.ReturnsError .}}{{$err := (index .Outputs (len $res)).ArgName}}var errs []error
+	for _, next := range {{$rec}}.next {
+		{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}} := next.{{.Name}}({{.CallArgs}})
+		if {{$err}} == nil {
+			return {{range $res}}{{.ArgName}}, {{end}}nil
+		}
+		errs = append(errs, {{$err}})
+		if {{$rec}}.fallThrough != nil && !{{$rec}}.fallThrough({{$err}}) {
+			break
+		}
+		{{if .HasContext}}if {{(index .Inputs 0).ArgName}}.Err() != nil {
+			break
+		}
+		{{end}}}
+	return {{range $res}}{{$R.Zero .}}, {{end}}errors.Join(errs...)
+	{{else}}if len({{$rec}}.next) == 0 {
+		return {{range .Outputs}}{{$R.Zero .}}{{.Sep}}{{end}}
+	}
+	{{if .Outputs}}return {{end}}{{$rec}}.next[0].{{.Name}}({{.CallArgs}})
+	{{end}} }
+{{end}}
+`
+
+// teeTemplate generates a type calling a primary and a secondary implementation for each method,
+// returning the results of the primary and reporting the errors of the secondary.
+const teeTemplate = `
+{{$R := .}}
+package {{.PkgName}}
+
+import (
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} calls primary, then secondary (e.g. shadowing the traffic during a migration), for each method.
+// The results come from primary. The errors of secondary are passed to secondaryErr if it is set.
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	primary      ` + interfacePlaceholder + `
+	secondary    ` + interfacePlaceholder + `
+	secondaryErr func(method string, err error)
+}
+
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.primary.{{.Name}}({{.CallArgs}})
+	{{if $R.ReturnsError .}}if {{range $res}}_, {{end}}err := {{$rec}}.secondary.{{.Name}}({{.CallArgs}}); err != nil && {{$rec}}.secondaryErr != nil {
+		{{$rec}}.secondaryErr({{printf "%q" .Name}}, err)
+	}
+	{{else}}{{$rec}}.secondary.{{.Name}}({{.CallArgs}})
+	{{end}}{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// toggleTemplate generates a type routing each call to the old or the new implementation, per a feature flag.
+const toggleTemplate = `
+{{$R := .}}
+package {{.PkgName}}
+
+import (
+	"context"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} routes each call to newImpl if useNew returns true for the method, to oldImpl otherwise (or if useNew is nil).
+// useNew gets the context argument of the method, or context.Background() if it has none.
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	oldImpl ` + interfacePlaceholder + `
+	newImpl ` + interfacePlaceholder + `
+	useNew  func(ctx context.Context, method string) bool
+}
+
+{{$rec := .Receiver}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) pick(ctx context.Context, method string) ` + interfacePlaceholder + ` {
+	if {{$rec}}.useNew != nil && {{$rec}}.useNew(ctx, method) {
+		return {{$rec}}.newImpl
+	}
+	return {{$rec}}.oldImpl
+}
+
+{{range $R.Methods .Inter}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if .Outputs}}return {{end}}{{$rec}}.pick({{if .HasContext}}{{(index .Inputs 0).ArgName}}{{else}}context.Background(){{end}}, {{printf "%q" .Name}}).{{.Name}}({{.CallArgs}}) }
+{{end}}
+`
+
+// swapTemplate generates a type forwarding the calls to an implementation that can be swapped at run time.
+// The methods have pointer receivers: the type holds an atomic.Pointer.
+const swapTemplate = `
+{{$R := .}}
+package {{.PkgName}}
+
+import (
+	"sync/atomic"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{.Clean .ImplName}} forwards the calls to the current implementation of ` + interfacePlaceholder + `, which can be swapped at any time.
+type {{.Clean .ImplName}}{{.TypeParams}} struct {
+	cur atomic.Pointer[` + interfacePlaceholder + `]
+}
+
+{{$rec := .Receiver}}
+// Swap sets the current implementation and returns the previous one (nil if there was none).
+func ({{$rec}} *{{.Clean .ImplName}}{{$R.TypeArgs}}) Swap(impl ` + interfacePlaceholder + `) ` + interfacePlaceholder + ` {
+	if old := {{$rec}}.cur.Swap(&impl); old != nil {
+		return *old
+	}
+	return nil
+}
+
+// Load returns the current implementation (nil if none was set).
+func ({{$rec}} *{{.Clean .ImplName}}{{$R.TypeArgs}}) Load() ` + interfacePlaceholder + ` {
+	if cur := {{$rec}}.cur.Load(); cur != nil {
+		return *cur
+	}
+	return nil
+}
+
+{{range $R.Methods .Inter}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$R.Clean $R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if .Outputs}}return {{end}}{{$rec}}.Load().{{.Name}}({{.CallArgs}}) }
+{{end}}
+`
+
+// eventsTemplate generates a type forwarding the calls to the wrapped implementation and publishing a record of every call.
+const eventsTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}}Event records a call to {{$T}}.
+type {{$T}}Event struct {
+	Method   string
+	Args     string // The arguments, formatted with %v.
+	Err      error
+	Duration time.Duration
+}
+
+// {{$T}} forwards the calls to next and publishes a {{$T}}Event after each of them:
+// to callback if it is set, and to events if it is set. Sending to events never blocks:
+// the events that do not fit are dropped and counted.
+type {{$T}}{{.TypeParams}} struct {
+	next     ` + interfacePlaceholder + `
+	events   chan<- {{$T}}Event
+	callback func({{$T}}Event)
+	dropped  atomic.Uint64
+}
+
+{{$rec := .Receiver}}
+// Dropped returns the number of events that did not fit into the channel.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) Dropped() uint64 {
+	return {{$rec}}.dropped.Load()
+}
+
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) emit(method string, args []interface{}, err error, start time.Time) {
+	e := {{$T}}Event{Method: method, Args: fmt.Sprintf("%v", args), Err: err, Duration: time.Since(start)}
+	if {{$rec}}.callback != nil {
+		{{$rec}}.callback(e)
+	}
+	if {{$rec}}.events == nil {
+		return
+	}
+	select {
+	case {{$rec}}.events <- e:
+	default:
+		{{$rec}}.dropped.Add(1)
+	}
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	start := time.Now()
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{$rec}}.emit({{printf "%q" .Name}}, []interface{}{ {{range .Inputs}}{{.ArgName}}{{.Sep}}{{end}} }, {{if $R.ReturnsError .}}{{(index .Outputs (len $res)).ArgName}}{{else}}nil{{end}}, start)
+	{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// loggingTemplate generates a type forwarding the calls to the wrapped implementation and logging each of them with slog:
+// the method, the arguments, the results, the error and the duration.
+const loggingTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next and logs each of them to logger (slog.Default() if nil):
+// at the Info level, or the Error level if the call fails.
+type {{$T}}{{.TypeParams}} struct {
+	next   ` + interfacePlaceholder + `
+	logger *slog.Logger
+}
+
+{{$rec := .Receiver}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) log(ctx context.Context, method string, args, results []interface{}, err error, start time.Time) {
+	logger := {{$rec}}.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	level := slog.LevelInfo
+	attrs := []slog.Attr{slog.Any("args", args), slog.Any("results", results), slog.Duration("duration", time.Since(start))}
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	logger.LogAttrs(ctx, level, method, attrs...)
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	start := time.Now()
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{$rec}}.log({{if .HasContext}}{{(index .Inputs 0).ArgName}}{{else}}context.Background(){{end}}, {{printf "%q" .Name}},
+		[]interface{}{ {{range .Inputs}}{{.ArgName}}, {{end}} }, []interface{}{ {{range $res}}{{.ArgName}}, {{end}} },
+		{{if $R.ReturnsError .}}{{(index .Outputs (len $res)).ArgName}}{{else}}nil{{end}}, start)
+	{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// prometheusTemplate generates a type forwarding the calls to the wrapped implementation and recording the calls,
+// the errors and the latencies per method with prometheus, and its constructor registering the metrics.
+const prometheusTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"time"
+	"github.com/prometheus/client_golang/prometheus"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next and records the calls, the errors and the latencies, labeled by method.
+type {{$T}}{{.TypeParams}} struct {
+	next     ` + interfacePlaceholder + `
+	calls    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+{{$rec := .Receiver}}
+// {{.Constructor}} returns a {{$T}} forwarding the calls to next. The metrics, prefix_calls_total, prefix_errors_total
+// and prefix_duration_seconds, are registered with reg (prometheus.DefaultRegisterer if nil).
+func {{.Constructor}}{{.TypeParams}}(next ` + interfacePlaceholder + `, reg prometheus.Registerer, prefix string) (*{{$T}}{{$R.TypeArgs}}, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	{{$rec}} := &{{$T}}{{$R.TypeArgs}}{
+		next:     next,
+		calls:    prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: prefix, Name: "calls_total", Help: "The number of calls."}, []string{"method"}),
+		errors:   prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: prefix, Name: "errors_total", Help: "The number of failed calls."}, []string{"method"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: prefix, Name: "duration_seconds", Help: "The latency of the calls."}, []string{"method"}),
+	}
+	for _, c := range []prometheus.Collector{ {{$rec}}.calls, {{$rec}}.errors, {{$rec}}.duration} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return {{$rec}}, nil
+}
+
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) observe(method string, err error, start time.Time) {
+	{{$rec}}.calls.WithLabelValues(method).Inc()
+	if err != nil {
+		{{$rec}}.errors.WithLabelValues(method).Inc()
+	}
+	{{$rec}}.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	start := time.Now()
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{$rec}}.observe({{printf "%q" .Name}}, {{if $R.ReturnsError .}}{{(index .Outputs (len $res)).ArgName}}{{else}}nil{{end}}, start)
+	{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// tracingTemplate generates a type forwarding the calls to the wrapped implementation, each in an OpenTelemetry span
+// named Interface.Method. The span is a child of the span in the context argument, if any.
+const tracingTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next, each in a span started with tracer (otel.Tracer({{printf "%q" .PkgName}}) if nil).
+// The errors are recorded on the spans.
+type {{$T}}{{.TypeParams}} struct {
+	next   ` + interfacePlaceholder + `
+	tracer trace.Tracer
+}
+
+{{$rec := .Receiver}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) start(ctx context.Context, name string) (context.Context, trace.Span) {
+	tracer := {{$rec}}.tracer
+	if tracer == nil {
+		tracer = otel.Tracer({{printf "%q" .PkgName}})
+	}
+	return tracer.Start(ctx, name)
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if .HasContext}}{{(index .Inputs 0).ArgName}}, span := {{$rec}}.start({{(index .Inputs 0).ArgName}}, "` + interfaceNamePlaceholder + `.{{.Name}}")
+	{{else}}// {{.Name}} takes no context: the span is a root span.
+	_, span := {{$rec}}.start(context.Background(), "` + interfaceNamePlaceholder + `.{{.Name}}")
+	{{end}}defer span.End()
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{if $R.ReturnsError .}}if {{(index .Outputs (len $res)).ArgName}} != nil {
+		span.RecordError({{(index .Outputs (len $res)).ArgName}})
+		span.SetStatus(codes.Error, {{(index .Outputs (len $res)).ArgName}}.Error())
+	}
+	{{end}}{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// retryTemplate generates a type forwarding the calls to the wrapped implementation and retrying the calls failing
+// with an error, with exponential backoff, and its constructor. The methods without an error are only forwarded.
+const retryTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"context"
+	"math/rand"
+	"time"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next and retries the failed ones.
+type {{$T}}{{.TypeParams}} struct {
+	next     ` + interfacePlaceholder + `
+	attempts int
+	delay    time.Duration
+	jitter   float64
+}
+
+{{$rec := .Receiver}}
+// {{.Constructor}} returns a {{$T}} making up to attempts calls to next. The n-th retry waits for delay<<(n-1),
+// plus up to jitter times that (e.g. 0.1 for 10%). The retries stop early when the context argument is done.
+func {{.Constructor}}{{.TypeParams}}(next ` + interfacePlaceholder + `, attempts int, delay time.Duration, jitter float64) *{{$T}}{{$R.TypeArgs}} {
+	return &{{$T}}{{$R.TypeArgs}}{next: next, attempts: attempts, delay: delay, jitter: jitter}
+}
+
+// wait waits before retrying the failed attempt (counting from 0) and reports whether to retry:
+// not after the last attempt, nor if ctx is done.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) wait(ctx context.Context, attempt int) bool {
+	if attempt+1 >= {{$rec}}.attempts {
+		return false
+	}
+	d := {{$rec}}.delay << attempt
+	if {{$rec}}.jitter > 0 {
+		d += time.Duration(rand.Float64() * {{$rec}}.jitter * float64(d))
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $R.ReturnsError .}}{{range .Outputs}}var {{.ArgName}} {{$R.GetName .}}
+	{{end}}for attempt := 0; ; attempt++ {
+		{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}} = {{$rec}}.next.{{.Name}}({{.CallArgs}})
+		if {{(index .Outputs (len $res)).ArgName}} == nil || !{{$rec}}.wait({{if .HasContext}}{{(index .Inputs 0).ArgName}}{{else}}context.Background(){{end}}, attempt) {
+			return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}
+		}
+	}
+	{{else}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{end}} }
+{{end}}
+`
+
+// breakerTemplate generates a type forwarding the calls to the wrapped implementation through a circuit breaker
+// per method, and its constructor. The methods without an error are only forwarded.
+const breakerTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+{{$open := .Prefixed "Err"}}
+package {{.PkgName}}
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$open}}Open is returned (wrapped) by the methods of {{$T}} while the breaker of the method is open.
+var {{$open}}Open = errors.New("circuit breaker is open")
+
+// {{$T}} forwards the calls to next through a circuit breaker per method.
+// A breaker opens after threshold consecutive failures: the calls fail fast with {{$open}}Open.
+// After cooldown it lets a single call through: the breaker closes if it succeeds, and opens again if it fails.
+type {{$T}}{{.TypeParams}} struct {
+	next      ` + interfacePlaceholder + `
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures map[string]int       // Consecutive failures by method.
+	opened   map[string]time.Time // When the breaker of the method opened (or let the last call through).
+}
+
+{{$rec := .Receiver}}
+// {{.Constructor}} returns a {{$T}} opening the breaker of a method after threshold consecutive failures, for cooldown.
+func {{.Constructor}}{{.TypeParams}}(next ` + interfacePlaceholder + `, threshold int, cooldown time.Duration) *{{$T}}{{$R.TypeArgs}} {
+	return &{{$T}}{{$R.TypeArgs}}{next: next, threshold: threshold, cooldown: cooldown, failures: map[string]int{}, opened: map[string]time.Time{}}
+}
+
+// allow returns an error if the breaker of the method is open.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) allow(method string) error {
+	{{$rec}}.mu.Lock()
+	defer {{$rec}}.mu.Unlock()
+	if {{$rec}}.failures[method] < {{$rec}}.threshold {
+		return nil
+	}
+	if time.Since({{$rec}}.opened[method]) < {{$rec}}.cooldown {
+		return fmt.Errorf("%s: %w", method, {{$open}}Open)
+	}
+	// Half-open: let this call through, keeping the others out for another cooldown.
+	{{$rec}}.opened[method] = time.Now()
+	return nil
+}
+
+// done records the result of a call.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) done(method string, err error) {
+	{{$rec}}.mu.Lock()
+	defer {{$rec}}.mu.Unlock()
+	if err == nil {
+		delete({{$rec}}.failures, method)
+		return
+	}
+	if {{$rec}}.failures[method]++; {{$rec}}.failures[method] >= {{$rec}}.threshold {
+		{{$rec}}.opened[method] = time.Now()
+	}
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $R.ReturnsError .}}if err := {{$rec}}.allow({{printf "%q" .Name}}); err != nil {
+		return {{range $res}}{{$R.Zero .}}, {{end}}err
+	}
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}} := {{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{$rec}}.done({{printf "%q" .Name}}, {{(index .Outputs (len $res)).ArgName}})
+	return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}
+	{{else}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{end}} }
+{{end}}
+`
+
+// cacheTemplate generates a type forwarding the calls to the wrapped implementation and caching the results
+// of the lookups (the methods returning a value and an error), keyed by the arguments but the context, and its constructor.
+// The other methods are only forwarded.
+const cacheTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}}Backend stores the results cached by {{$T}}, e.g. a *sync.Map.
+type {{$T}}Backend interface {
+	Load(key interface{}) (value interface{}, ok bool)
+	Store(key, value interface{})
+}
+
+// {{$T}} forwards the calls to next and caches the successful results of the lookups for ttl (forever if 0).
+type {{$T}}{{.TypeParams}} struct {
+	next  ` + interfacePlaceholder + `
+	cache {{$T}}Backend
+	ttl   time.Duration
+}
+
+{{$rec := .Receiver}}
+// {{.Constructor}} returns a {{$T}} caching the results in cache (a new sync.Map if nil) for ttl (forever if 0).
+func {{.Constructor}}{{.TypeParams}}(next ` + interfacePlaceholder + `, cache {{$T}}Backend, ttl time.Duration) *{{$T}}{{$R.TypeArgs}} {
+	if cache == nil {
+		cache = new(sync.Map)
+	}
+	return &{{$T}}{{$R.TypeArgs}}{next: next, cache: cache, ttl: ttl}
+}
+
+// get returns the cached result for the key, unless it expired.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) get(key string) (interface{}, bool) {
+	v, ok := {{$rec}}.cache.Load(key)
+	if !ok {
+		return nil, false
+	}
+	e := v.(struct {
+		value   interface{}
+		expires time.Time
+	})
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) put(key string, value interface{}) {
+	e := struct {
+		value   interface{}
+		expires time.Time
+	}{value: value}
+	if {{$rec}}.ttl > 0 {
+		e.expires = time.Now().Add({{$rec}}.ttl)
+	}
+	{{$rec}}.cache.Store(key, e)
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}{{$m := .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if and ($R.ReturnsError .) (eq (len $res) 1)}}key := fmt.Sprintf({{printf "%q" (printf "%s%%#v" .Name)}}, []interface{}{ {{range $i, $in := .Inputs}}{{if or $i (not $m.HasContext)}}{{.ArgName}}, {{end}}{{end}} })
+	{{$v := (index .Outputs 0).ArgName}}{{$err := (index .Outputs 1).ArgName}}if v, ok := {{$rec}}.get(key); ok {
+		{{$v}}, _ := v.({{$R.GetName (index $res 0)}})
+		return {{$v}}, nil
+	}
+	{{$v}}, {{$err}} := {{$rec}}.next.{{.Name}}({{.CallArgs}})
+	if {{$err}} == nil {
+		{{$rec}}.put(key, {{$v}})
+	}
+	return {{$v}}, {{$err}}
+	{{else}}// {{.Name}} is not a lookup (returning a value and an error): it is not cached.
+	{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{end}} }
+{{end}}
+`
+
+// rateLimitTemplate generates a type forwarding the calls to the wrapped implementation once a rate.Limiter allows them,
+// and its constructor.
+const rateLimitTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next once the limiter of the method allows them.
+// The methods with a context wait until it is done at most, and return its error if they return an error.
+type {{$T}}{{.TypeParams}} struct {
+	next      ` + interfacePlaceholder + `
+	shared    *rate.Limiter
+	perMethod map[string]*rate.Limiter
+}
+
+{{$rec := .Receiver}}
+// {{.Constructor}} returns a {{$T}} limiting the calls of the methods in perMethod with their limiters,
+// and the calls of the other methods with shared (not limited if nil).
+func {{.Constructor}}{{.TypeParams}}(next ` + interfacePlaceholder + `, shared *rate.Limiter, perMethod map[string]*rate.Limiter) *{{$T}}{{$R.TypeArgs}} {
+	return &{{$T}}{{$R.TypeArgs}}{next: next, shared: shared, perMethod: perMethod}
+}
+
+// wait waits until the limiter of the method allows a call (or ctx is done).
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) wait(ctx context.Context, method string) error {
+	l, ok := {{$rec}}.perMethod[method]
+	if !ok {
+		l = {{$rec}}.shared
+	}
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx)
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $R.ReturnsError .}}if err := {{$rec}}.wait({{if .HasContext}}{{(index .Inputs 0).ArgName}}{{else}}context.Background(){{end}}, {{printf "%q" .Name}}); err != nil {
+		return {{range $res}}{{$R.Zero .}}, {{end}}err
+	}
+	{{else}}// {{.Name}} can not return the error: the call goes ahead once the limiter allows it{{if .HasContext}} or the context is done{{end}}.
+	_ = {{$rec}}.wait({{if .HasContext}}{{(index .Inputs 0).ArgName}}{{else}}context.Background(){{end}}, {{printf "%q" .Name}})
+	{{end}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}}) }
+{{end}}
+`
+
+// hooksTemplate generates a type forwarding the calls to the wrapped implementation and calling hooks around them.
+const hooksTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next, calling BeforeCall before each of them and AfterCall after, if they are set.
+type {{$T}}{{.TypeParams}} struct {
+	next ` + interfacePlaceholder + `
+	// BeforeCall is called with the name of the method and the arguments.
+	BeforeCall func(method string, args []interface{})
+	// AfterCall is called with the name of the method, the results but the trailing error, and the error (if the method returns one).
+	AfterCall func(method string, results []interface{}, err error)
+}
+
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	if {{$rec}}.BeforeCall != nil {
+		{{$rec}}.BeforeCall({{printf "%q" .Name}}, []interface{}{ {{range .Inputs}}{{.ArgName}}, {{end}} })
+	}
+	{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{if .Outputs}} := {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	if {{$rec}}.AfterCall != nil {
+		{{$rec}}.AfterCall({{printf "%q" .Name}}, []interface{}{ {{range $res}}{{.ArgName}}, {{end}} }, {{if $R.ReturnsError .}}{{(index .Outputs (len $res)).ArgName}}{{else}}nil{{end}})
+	}
+	{{if .Outputs}}return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}{{end}} }
+{{end}}
+`
+
+// chaosTemplate generates a type forwarding the calls to the wrapped implementation, unless a fault injected
+// at run time fails them.
+const chaosTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}}Fault is a fault injected into the calls of {{$T}}.
+type {{$T}}Fault struct {
+	Err         error   // Returned by the failed calls (an "injected fault" error if nil). The methods without an error ignore it.
+	Panic       bool    // The failed calls panic with the error instead.
+	Probability float64 // Of a call to fail: 0 never, 1 always.
+}
+
+// {{$T}} forwards the calls to next, failing them per the faults injected with Inject. The zero faults fail no calls.
+type {{$T}}{{.TypeParams}} struct {
+	next ` + interfacePlaceholder + `
+
+	mu     sync.Mutex
+	faults map[string]{{$T}}Fault // By method, "" for the methods without a fault.
+}
+
+{{$rec := .Receiver}}
+// Inject injects the fault into the calls of the method ("" for all the methods without a fault of their own),
+// replacing the previous one. A fault with zero Probability removes it.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) Inject(method string, f {{$T}}Fault) {
+	{{$rec}}.mu.Lock()
+	defer {{$rec}}.mu.Unlock()
+	if f.Probability <= 0 {
+		delete({{$rec}}.faults, method)
+		return
+	}
+	if {{$rec}}.faults == nil {
+		{{$rec}}.faults = map[string]{{$T}}Fault{}
+	}
+	{{$rec}}.faults[method] = f
+}
+
+// fail returns the error a call to the method fails with, if it does. It panics if the fault says so.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) fail(method string) error {
+	{{$rec}}.mu.Lock()
+	f, ok := {{$rec}}.faults[method]
+	if !ok {
+		f, ok = {{$rec}}.faults[""]
+	}
+	{{$rec}}.mu.Unlock()
+	if !ok || rand.Float64() >= f.Probability {
+		return nil
+	}
+	err := f.Err
+	if err == nil {
+		err = errors.New(method + ": injected fault")
+	}
+	if f.Panic {
+		panic(err)
+	}
+	return err
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if $R.ReturnsError .}}if err := {{$rec}}.fail({{printf "%q" .Name}}); err != nil {
+		return {{range $res}}{{$R.Zero .}}, {{end}}err
+	}
+	{{else}}// {{.Name}} can not return the error: only the panics are injected.
+	_ = {{$rec}}.fail({{printf "%q" .Name}})
+	{{end}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}}) }
+{{end}}
+`
+
+const mutexPlaceholder = "MUTEX"
+
+// mutexTemplate generates a type forwarding the calls to the wrapped implementation holding a lock (mutexPlaceholder).
+// With a sync.RWMutex, the read-only methods (see goimpl.Method.ReadOnly) hold the read lock.
+const mutexTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"sync"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next holding the lock, so next is safe for concurrent use.
+type {{$T}}{{.TypeParams}} struct {
+	mu   ` + mutexPlaceholder + `
+	next ` + interfacePlaceholder + `
+}
+
+{{$rec := .Receiver}}
+{{$rw := eq "` + mutexPlaceholder + `" "sync.RWMutex"}}
+{{range $R.Methods .Inter}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{if and $rw .ReadOnly}}{{$rec}}.mu.RLock()
+	defer {{$rec}}.mu.RUnlock()
+	{{else}}{{$rec}}.mu.Lock()
+	defer {{$rec}}.mu.Unlock()
+	{{end}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}}) }
+{{end}}
+`
+
+// countersTemplate generates a type forwarding the calls to the wrapped implementation and counting the calls and errors
+// per method, with expvar.
+const countersTemplate = `
+{{$R := .}}
+{{$T := .Clean .ImplName}}
+package {{.PkgName}}
+
+import (
+	"expvar"
+	{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
+	{{end}})
+
+// {{$T}} forwards the calls to next and counts the calls and the errors per method.
+// It is safe for concurrent use (as long as next is).
+type {{$T}}{{.TypeParams}} struct {
+	next   ` + interfacePlaceholder + `
+	calls  expvar.Map
+	errors expvar.Map
+}
+
+{{$rec := .Receiver}}
+// Publish publishes the counters as the expvar name: {"calls": {"Method": n...}, "errors": {...}}.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) Publish(name string) {
+	m := new(expvar.Map)
+	m.Set("calls", &{{$rec}}.calls)
+	m.Set("errors", &{{$rec}}.errors)
+	expvar.Publish(name, m)
+}
+
+// Stats returns the numbers of calls and errors by method.
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) Stats() (calls, errs map[string]int64) {
+	calls, errs = map[string]int64{}, map[string]int64{}
+	{{$rec}}.calls.Do(func(kv expvar.KeyValue) { calls[kv.Key] = kv.Value.(*expvar.Int).Value() })
+	{{$rec}}.errors.Do(func(kv expvar.KeyValue) { errs[kv.Key] = kv.Value.(*expvar.Int).Value() })
+	return calls, errs
+}
+
+{{range $R.Methods .Inter}}{{$res := $R.Results .}}
+{{if .Comment}}// {{ .Comment}} {{end}}
+func ({{$rec}} *{{$T}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{$rec}}.calls.Add({{printf "%q" .Name}}, 1)
+	{{if $R.ReturnsError .}}{{$err := (index .Outputs (len $res)).ArgName}}{{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}} := {{$rec}}.next.{{.Name}}({{.CallArgs}})
+	if {{$err}} != nil {
+		{{$rec}}.errors.Add({{printf "%q" .Name}}, 1)
+	}
+	return {{range .Outputs}}{{.ArgName}}{{.Sep}}{{end}}
+	{{else}}{{if .Outputs}}return {{end}}{{$rec}}.next.{{.Name}}({{.CallArgs}})
+	{{end}} }
+{{end}}
+`
+
+// isCommand reports whether the first argument is a subcommand.
+func isCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, ok := commands[args[0]]
+	return ok
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// kvSrc has an interface with the shapes the templates handle differently: a context, variadic arguments,
+// several results, an error alone and no results. The parameters and the types are named like the locals
+// of the templates: with ParamNames, the names are taken as is; without, they are derived from the types.
+const kvSrc = `package kv
+
+import "context"
+
+type (
+	Key   string
+	Graph struct{}
+	Start int
+)
+
+type KV interface {
+	// Get returns the value of the key.
+	Get(ctx context.Context, key Key) (value string, ok bool, err error)
+	Lookup(key Key, v, ok string) (string, error)
+	Put(ctx context.Context, g Graph, start Start, values ...string) error
+	Walk(next func(Key) bool, errs []error, results map[Key]int, codes []int, attempt int) (n int, err error)
+	Len(idx, pick, all, v, time, span int) int
+	Close() error
+	Reset()
+}
+`
+
+// stubs are the packages the templates import but the standard library: enough of their API for the bodies
+// to type-check.
+var stubs = map[string]string{
+	"golang.org/x/sync/errgroup": `package errgroup
+
+type Group struct{}
+
+func (g *Group) Go(f func() error) {}
+func (g *Group) Wait() error       { return nil }
+`,
+	"github.com/prometheus/client_golang/prometheus": `package prometheus
+
+type Collector interface{}
+
+type Registerer interface {
+	Register(Collector) error
+}
+
+var DefaultRegisterer Registerer
+
+type Opts struct{ Namespace, Name, Help string }
+
+type (
+	CounterOpts   Opts
+	HistogramOpts Opts
+)
+
+type Counter interface{ Inc() }
+
+type Observer interface{ Observe(float64) }
+
+type CounterVec struct{}
+
+func NewCounterVec(opts CounterOpts, labels []string) *CounterVec { return nil }
+func (v *CounterVec) WithLabelValues(values ...string) Counter { return nil }
+
+type HistogramVec struct{}
+
+func NewHistogramVec(opts HistogramOpts, labels []string) *HistogramVec { return nil }
+func (v *HistogramVec) WithLabelValues(values ...string) Observer { return nil }
+`,
+	"go.opentelemetry.io/otel": `package otel
+
+import "go.opentelemetry.io/otel/trace"
+
+func Tracer(name string) trace.Tracer { return nil }
+`,
+	"go.opentelemetry.io/otel/codes": `package codes
+
+type Code uint32
+
+const Error Code = 1
+`,
+	"go.opentelemetry.io/otel/trace": `package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	End()
+	RecordError(err error)
+	SetStatus(code codes.Code, description string)
+}
+`,
+	"golang.org/x/time/rate": `package rate
+
+import "context"
+
+type Limiter struct{}
+
+func (l *Limiter) Wait(ctx context.Context) error { return nil }
+`,
+}
+
+// stubImporter imports the stubs, and the other packages from source.
+type stubImporter struct {
+	fset *token.FileSet
+	pkgs map[string]*types.Package
+	std  types.Importer
+}
+
+func (imp *stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.pkgs[path]; ok {
+		return pkg, nil
+	}
+	src, ok := stubs[path]
+	if !ok {
+		return imp.std.Import(path)
+	}
+	f, err := parser.ParseFile(imp.fset, path+"/stub.go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(path, imp.fset, []*ast.File{f}, nil)
+	if err != nil {
+		return nil, err
+	}
+	imp.pkgs[path] = pkg
+	return pkg, nil
+}
+
+// checkTemplate generates a type implementing KV of kvSrc with the template, type-checks it and returns it.
+func checkTemplate(t *testing.T, name string, tmpl cmdTemplate, paramNames bool) string {
+	t.Helper()
+	return checkTemplateImpl(t, name, tmpl, paramNames, "gen")
+}
+
+// checkTemplateImpl is checkTemplate for the type named impl.
+func checkTemplateImpl(t *testing.T, name string, tmpl cmdTemplate, paramNames bool, impl string) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	imp := &stubImporter{fset: fset, pkgs: map[string]*types.Package{}, std: importer.ForCompiler(fset, "source", nil)}
+	f, err := parser.ParseFile(fset, "kv.go", kvSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: imp}).Check("example.com/kv", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := GenOpts{Inter: "kv.KV", PkgName: "kv", ImplName: impl, ParamNames: paramNames, NoGoImports: true}
+	tmpl.apply(&opts)
+	lib := opts.lib()
+	var out bytes.Buffer
+	if err := goimpl.GenerateFromTypes(&lib, pkg.Scope().Lookup("KV").Type().Underlying().(*types.Interface), pkg, &out); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	checkKV(t, name, out.Bytes())
+	return out.String()
+}
+
+// checkKV type-checks the generated code with kvSrc.
+func checkKV(t *testing.T, name string, code []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	imp := &stubImporter{fset: fset, pkgs: map[string]*types.Package{}, std: importer.ForCompiler(fset, "source", nil)}
+	var files []*ast.File
+	for _, src := range [][]byte{[]byte(kvSrc), code} {
+		f, err := parser.ParseFile(fset, "", src, 0)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, src)
+		}
+		files = append(files, f)
+	}
+	if _, err := (&types.Config{Importer: imp}).Check("example.com/kv", fset, files, nil); err != nil {
+		t.Errorf("%s: %v\n%s", name, err, code)
+	}
+}
+
+// allTemplates are the templates of the commands and of the kinds of wrap.
+var allTemplates = []struct {
+	name string
+	tmpl cmdTemplate
+}{
+	{"mock", templates["mock"]},
+	{"fanout first", fanout("first")},
+	{"fanout ok", fanout("ok")},
+	{"fanout collect", fanout("collect")},
+	{"fallback", templates["fallback"]},
+	{"swap", templates["swap"]},
+	{"events", templates["events"]},
+	{"tee", templates["tee"]},
+	{"toggle", templates["toggle"]},
+	{"counters", templates["counters"]},
+	{"wrap logging", wrapKinds["logging"]},
+	{"wrap prometheus", wrapKinds["prometheus"]},
+	{"wrap tracing", wrapKinds["tracing"]},
+	{"wrap retry", wrapKinds["retry"]},
+	{"wrap breaker", wrapKinds["breaker"]},
+	{"wrap cache", wrapKinds["cache"]},
+	{"wrap ratelimit", wrapKinds["ratelimit"]},
+	{"wrap hooks", wrapKinds["hooks"]},
+	{"wrap mutex", wrapKinds["mutex"]},
+	{"wrap rwmutex", wrapKinds["rwmutex"]},
+	{"wrap chaos", wrapKinds["chaos"]},
+}
+
+func TestTemplates(t *testing.T) {
+	for _, c := range allTemplates {
+		checkTemplate(t, c.name, c.tmpl, true)
+		checkTemplate(t, c.name+" (names from the types)", c.tmpl, false)
+	}
+}
+
+func TestTemplatesDigitsInImplName(t *testing.T) {
+	for _, c := range allTemplates {
+		gen := checkTemplateImpl(t, c.name, c.tmpl, true, "*genV2")
+		if !strings.Contains(gen, "type genV2 ") {
+			t.Errorf("%s: expected type genV2 in\n%s", c.name, gen)
+		}
+	}
+}
+
+func TestFallbackWithoutImplementations(t *testing.T) {
+	gen := checkTemplate(t, "fallback", templates["fallback"], true)
+	if want := "if len(g.next) == 0 {\n\t\treturn 0\n\t}\n\treturn g.next[0].Len(idx, pick, all, v, time, span)"; !strings.Contains(gen, want) {
+		t.Errorf("expected %q in\n%s", want, gen)
+	}
+}
+
+// fallbackTest runs the fallback generated for kvSrc: the implementations after the one failing with the context done,
+// or with an error that does not fall through, are not called.
+const fallbackTest = `package kv
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type failing struct {
+	KV
+	err    error
+	cancel context.CancelFunc
+	calls  *int
+}
+
+func (f failing) Get(ctx context.Context, key Key) (string, bool, error) {
+	*f.calls++
+	if f.cancel != nil {
+		f.cancel()
+	}
+	return "", false, f.err
+}
+
+func TestFallback(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	var calls int
+	for name, c := range map[string]struct {
+		g      gen
+		cancel bool
+		want   int
+	}{
+		"all":          {g: gen{}, want: 2},
+		"fall through": {g: gen{fallThrough: func(err error) bool { return err != errFirst }}, want: 1},
+		"context done": {g: gen{}, cancel: true, want: 1},
+	} {
+		calls = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		first := failing{err: errFirst, calls: &calls}
+		if c.cancel {
+			first.cancel = cancel
+		}
+		c.g.next = []KV{first, failing{err: errSecond, calls: &calls}}
+		_, _, err := c.g.Get(ctx, "k")
+		cancel()
+		if !errors.Is(err, errFirst) || calls != c.want || (c.want == 2) != errors.Is(err, errSecond) {
+			t.Errorf("%s: %d calls, %v", name, calls, err)
+		}
+	}
+}
+`
+
+func TestFallbackStops(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/kv\n\ngo 1.21\n",
+		"kv.go":       kvSrc,
+		"gen.go":      checkTemplate(t, "fallback", templates["fallback"], true),
+		"gen_test.go": fallbackTest,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "test", ".")
+	cmd.Dir = dir
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go test: %v\n%s", err, b)
+	}
+}
+
+func TestTemplateMethods(t *testing.T) {
+	all := map[string]cmdTemplate{}
+	for name, tmpl := range templates {
+		all[name] = tmpl
+	}
+	for kind, tmpl := range wrapKinds {
+		all["wrap "+kind] = tmpl
+	}
+	for name, tmpl := range all {
+		for _, m := range tmpl.methods {
+			// An interface with a method the template declares too.
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "kv.go", "package kv\n\ntype KV interface {\n\t"+m+"()\n}\n", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg, err := (&types.Config{}).Check("example.com/kv", fset, []*ast.File{f}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := GenOpts{Inter: "kv.KV", PkgName: "kv", ImplName: "gen", NoGoImports: true}
+			tmpl.apply(&opts)
+			lib := opts.lib()
+			err = goimpl.GenerateFromTypes(&lib, pkg.Scope().Lookup("KV").Type().Underlying().(*types.Interface), pkg, &bytes.Buffer{})
+			if d, ok := err.(*goimpl.Diagnostic); !ok || d.Code != goimpl.NameCollision {
+				t.Errorf("%s of an interface with %s: got %v, expected a NameCollision diagnostic", name, m, err)
+			}
+		}
+	}
+}
+
+func TestTemplatesRegion(t *testing.T) {
+	for _, c := range allTemplates {
+		code := checkTemplate(t, c.name, c.tmpl, true)
+		p, err := goimpl.SplitCode([]byte(code))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		region, err := goimpl.ReplaceRegion([]byte("package kv\n"), "kv.KV", p)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		checkKV(t, c.name+" (region)", region)
+		if _, err := goimpl.Snippet(goimpl.SnippetVSCode, "kv", []byte(code)); err != nil {
+			t.Errorf("%s (snippet): %v", c.name, err)
+		}
+	}
+}
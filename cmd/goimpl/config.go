@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const defaultConfig = "goimpl.json"
+
+// config holds the defaults read from the config file.
+// Flags set on the command line take precedence.
+type config struct {
+	Named            *bool             `json:"named,omitempty"`             // Same as -named.
+	GoImports        *bool             `json:"goimports,omitempty"`         // Same as -goimports.
+	Source           *bool             `json:"source,omitempty"`            // Same as -source.
+	Any              *bool             `json:"any,omitempty"`               // Same as -any.
+	Template         string            `json:"template,omitempty"`          // Same as -template. Relative to the config file.
+	Plugin           string            `json:"plugin,omitempty"`            // Same as -plugin. Relative to the config file.
+	Workfile         string            `json:"workfile,omitempty"`          // Same as -workfile. Relative to the config file.
+	Sidecar          string            `json:"sidecar,omitempty"`           // Same as -sidecar. Relative to the config file.
+	Unimplemented    string            `json:"unimplemented,omitempty"`     // Same as -unimplemented.
+	UnimplementedFor map[string]string `json:"unimplemented_for,omitempty"` // Same as -unimplemented-for, merged with the flags.
+	Bodies           map[string]string `json:"bodies,omitempty"`            // Same as -body-for, merged with the flags.
+	Rewrites         []string          `json:"rewrites,omitempty"`          // Rewrite rules, applied before the ones from -rules and -r.
+	ImportMap        map[string]string `json:"import_map,omitempty"`        // Same as -import-map, merged with the flags.
+	ArgNames         map[string]string `json:"arg_names,omitempty"`         // Same as -arg-names, merged with the flags.
+	Imports          []string          `json:"imports,omitempty"`           // Extra imports, added to the ones from the command line.
+	Header           string            `json:"header,omitempty"`            // Same as -header.
+	TypeDoc          string            `json:"type_doc,omitempty"`          // Same as -type-doc.
+	Targets          []target          `json:"targets,omitempty"`           // Generated when goimpl is run without arguments.
+
+	dir string // Directory of the config file.
+}
+
+// target describes a single implementation to generate.
+type target struct {
+	Interface string   `json:"interface"`          // package.interfaceTypeName.
+	Type      string   `json:"type"`               // [(*|&)][package.]typeName.
+	Output    string   `json:"output"`             // Relative to the config file. Stdout if empty.
+	Existing  bool     `json:"existing,omitempty"` // Same as -existing.
+	Imports   []string `json:"imports,omitempty"`  // Extra imports for this target.
+	Sidecar   string   `json:"sidecar,omitempty"`  // Per-method options for this target. Relative to the config file.
+}
+
+// path resolves the file name relative to the config file.
+func (cfg *config) path(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(cfg.dir, file)
+}
+
+// loadConfig reads the config file.
+// A missing file is not an error unless it was requested explicitly.
+func loadConfig(file string) (*config, error) {
+	cfg := &config{dir: filepath.Dir(file)}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) && file == defaultConfig {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	return cfg, nil
+}
+
+// apply sets the flags that were not set on the command line.
+func (cfg *config) apply() error {
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	values := map[string]string{}
+	if cfg.Named != nil {
+		values["named"] = strconv.FormatBool(*cfg.Named)
+	}
+	if cfg.GoImports != nil {
+		values["goimports"] = strconv.FormatBool(*cfg.GoImports)
+	}
+	if cfg.Source != nil {
+		values["source"] = strconv.FormatBool(*cfg.Source)
+	}
+	if cfg.Any != nil {
+		values["any"] = strconv.FormatBool(*cfg.Any)
+	}
+	if cfg.Template != "" {
+		values["template"] = cfg.path(cfg.Template)
+	}
+	if cfg.Plugin != "" {
+		values["plugin"] = cfg.path(cfg.Plugin)
+	}
+	if cfg.Unimplemented != "" {
+		values["unimplemented"] = cfg.Unimplemented
+	}
+	if cfg.Header != "" {
+		values["header"] = cfg.Header
+	}
+	if cfg.TypeDoc != "" {
+		values["type-doc"] = cfg.TypeDoc
+	}
+	configRewrites = cfg.Rewrites
+	for old, path := range cfg.ImportMap {
+		if _, ok := importPaths[old]; !ok {
+			importPaths[old] = path
+		}
+	}
+	for t, name := range cfg.ArgNames {
+		if _, ok := argNames[t]; !ok {
+			argNames[t] = name
+		}
+	}
+	for name, mode := range cfg.UnimplementedFor {
+		if _, ok := unimplementedFor[name]; !ok {
+			unimplementedFor[name] = mode
+		}
+	}
+	for name, body := range cfg.Bodies {
+		if _, ok := bodies[name]; !ok {
+			bodies[name] = body
+		}
+	}
+	if cfg.Sidecar != "" {
+		values["sidecar"] = cfg.path(cfg.Sidecar)
+	}
+	if cfg.Workfile == "off" {
+		values["workfile"] = "off"
+	} else if cfg.Workfile != "" {
+		values["workfile"] = cfg.path(cfg.Workfile)
+	}
+	for name, v := range values {
+		if set[name] {
+			continue
+		}
+		if err := flag.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
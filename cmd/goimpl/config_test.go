@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigRewritesOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		defaultConfig: `{"rewrites": ["a -> b"]}`,
+		"rules.txt":   "# From the file.\nb -> c\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(cfgRules, flagRules []string, file string) {
+		configRewrites, rewrites, *rulesFile = cfgRules, flagRules, file
+	}(configRewrites, rewrites, *rulesFile)
+	cfg, err := loadConfig(filepath.Join(dir, defaultConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.apply(); err != nil {
+		t.Fatal(err)
+	}
+	rewrites, *rulesFile = stringList{"c -> d"}, filepath.Join(dir, "rules.txt")
+	opts, err := newOpts("io.Reader", "pkg.T", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a -> b", "b -> c", "c -> d"}; !reflect.DeepEqual(opts.Rewrites, want) {
+		t.Errorf("got %q, expected %q", opts.Rewrites, want)
+	}
+}
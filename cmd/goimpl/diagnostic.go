@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// compileErrors classifies the errors of the go compiler, in the order of precedence.
+var compileErrors = []struct {
+	re   *regexp.Regexp
+	code goimpl.Code
+	hint string
+}{
+	{regexp.MustCompile(`without instantiation|not a generic type|type arguments`), goimpl.GenericInterface,
+		"generic interfaces can not be implemented without the type arguments."},
+	{regexp.MustCompile(`not exported|unexported`), goimpl.UnexportedInterface,
+		"only the exported interfaces can be implemented from another package."},
+	{regexp.MustCompile(`use of internal package`), goimpl.UnexportedInterface,
+		"the interfaces of internal packages can only be implemented within the tree of the parent of internal."},
+	{regexp.MustCompile(`is not a type|is not an interface|invalid composite literal`), goimpl.NotAnInterface,
+		"check the name of the interface (and the order of the arguments)."},
+	{regexp.MustCompile(`undefined|no required module|cannot find package|is not in std|could not import|not in GOROOT`), goimpl.UnresolvableType,
+		"check the name of the interface and pass its import path as an extra argument (or go get the module)."},
+}
+
+// bootstrapPos is the position prefix of the errors in the bootstrap program.
+var bootstrapPos = regexp.MustCompile(`(?m)^\S*bootsrap\.go:\d+(:\d+)?: `)
+
+// compileError converts the errors of compiling the bootstrap program to a diagnostic about the interfaces.
+func compileError(out string, inters []string) error {
+	msg := strings.TrimSpace(bootstrapPos.ReplaceAllString(out, ""))
+	lines := strings.Split(msg, "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+		// The package name.
+		msg = strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	}
+	d := &goimpl.Diagnostic{Code: goimpl.Other, Message: msg, Location: strings.Join(inters, ", ")}
+	for _, c := range compileErrors {
+		if c.re.MatchString(msg) {
+			d.Code, d.Hint = c.code, c.hint
+			break
+		}
+	}
+	if d.Code == goimpl.UnresolvableType && allUnexported(inters) {
+		// goimports does not import a package for an unexported name.
+		d.Code, d.Hint = goimpl.UnexportedInterface, compileErrors[1].hint
+	}
+	return d
+}
+
+func allUnexported(inters []string) bool {
+	for _, inter := range inters {
+		name := inter[strings.LastIndex(inter, ".")+1:]
+		if name == "" || !unicode.IsLower([]rune(name)[0]) {
+			return false
+		}
+	}
+	return len(inters) > 0
+}
+
+// decodeError decodes an error written by the bootstrap program.
+func decodeError(b []byte) error {
+	d := new(goimpl.Diagnostic)
+	if len(b) > 0 && b[0] == '{' && json.Unmarshal(b, d) == nil {
+		return d
+	}
+	return errors.New(string(b))
+}
+
+// report writes the error in the format selected by -diagnostics.
+// Errors other than diagnostics are reported with the code Other.
+func report(w io.Writer, err error) {
+	var d *goimpl.Diagnostic
+	if !errors.As(err, &d) {
+		d = &goimpl.Diagnostic{Code: goimpl.Other, Message: err.Error()}
+	}
+	if *diagnostics == "json" {
+		b, _ := json.Marshal(d)
+		fmt.Fprintf(w, "%s\n", b)
+		return
+	}
+	if d != err {
+		// Keep the context added by wrapping.
+		fmt.Fprintln(w, err)
+	} else {
+		fmt.Fprintf(w, "%s [%s]\n", d.Error(), d.Code)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(w, "hint: %s\n", d.Hint)
+	}
+}
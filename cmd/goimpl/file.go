@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// Interfaces can be given as file.go:Interface (or directory:Interface) instead of package.Interface,
+// for scratch files and packages that do not compile yet: the file (or the package in the directory) is type checked
+// on its own, ignoring the errors.
+
+// splitFileInterface splits file.go:Interface or directory:Interface.
+// ok is false if the interface is not given that way.
+func splitFileInterface(inter string) (file, name string, ok bool) {
+	i := strings.LastIndex(inter, ":")
+	if i <= 0 || i == len(inter)-1 {
+		return "", "", false
+	}
+	file, name = inter[:i], inter[i+1:]
+	if strings.HasSuffix(file, ".go") {
+		return file, name, true
+	}
+	fi, err := os.Stat(file)
+	return file, name, err == nil && fi.IsDir()
+}
+
+// packageClause returns the name of the package of the file (or of the first file in the directory).
+func packageClause(file string) (string, error) {
+	if fi, err := os.Stat(file); err != nil {
+		return "", err
+	} else if fi.IsDir() {
+		files, _ := filepath.Glob(filepath.Join(file, "*.go"))
+		if len(files) == 0 {
+			return "", fmt.Errorf("no Go files in %s", file)
+		}
+		file = files[0]
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	return f.Name.Name, nil
+}
+
+// generateFromFile generates the target from the interface declared in t.InterFile.
+func generateFromFile(t GenOpts) (r result) {
+	if t.Existing != "" || t.Adapt != "" {
+		r.err = errors.New("-existing and -adapt can not be used with file.go:Interface.")
+		return r
+	}
+	named, err := goimpl.ParseInterface(t.InterFile, t.Inter[strings.Index(t.Inter, ".")+1:])
+	if err != nil {
+		r.err = err
+		return r
+	}
+	pkgPath := named.Obj().Pkg().Path()
+	if pkgPath == named.Obj().Pkg().Name() {
+		// Outside of a module: there is nothing to import (goimports might still find it).
+		pkgPath = ""
+	}
+	return generateNamed(t, pkgPath, named)
+}
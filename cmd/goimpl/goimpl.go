@@ -3,47 +3,313 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
-	"text/template"
+	"time"
 
-	"golang.org/x/tools/imports"
+	"github.com/sasha-s/goimpl"
 )
 
 func usage() {
-	fmt.Fprintln(os.Stderr, `Usage: goimpl [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
-This would generate empty implementation of the interfaceTypeName.`)
+	fmt.Fprintln(os.Stderr, `Usage: goimpl [stub] [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName[[typeParams]]
+       goimpl [stub] [flags]
+       goimpl [stub] -pos file.go:#offset [import1] [import2...] package.interfaceTypeName
+       goimpl [stub] [flags] importpath.interfaceTypeName@version [(*|&)][package2.]typeName
+       goimpl [stub] [flags] importpath@version.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl [stub] [flags] file.go:interfaceTypeName [(*|&)][package2.]typeName
+       goimpl check [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl list [flags] [import1] [import2...] package.interfaceTypeName
+       goimpl wrap [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl mock [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl fanout [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl fallback [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl tee [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl toggle [flags] [import1] [import2...] package.interfaceTypeName [(*|&)][package2.]typeName
+       goimpl swap [flags] [import1] [import2...] package.interfaceTypeName [package2.]typeName
+       goimpl events [flags] [import1] [import2...] package.interfaceTypeName [package2.]typeName
+       goimpl counters [flags] [import1] [import2...] package.interfaceTypeName [package2.]typeName
+       goimpl near [flags] importpath.interfaceTypeName [packages]
+       goimpl interfaces [flags] importpath.typeName [packages]
+       goimpl providers [flags] [import1] [import2...] [package.interfaceTypeName=[*]typeName...]
+       goimpl init [-dir directory]
+       goimpl clean-cache
+stub (the default) generates empty implementation of the interfaceTypeName.
+Without arguments, generates all the targets from the config file.
+check reports the methods the existing type is missing, list prints the methods of the interface,
+wrap generates a type forwarding the calls to a wrapped implementation (-kind logging also logs them, -kind prometheus records their metrics, -kind tracing traces them, -kind retry retries them, -kind breaker adds circuit breakers, -kind cache caches the lookups, -kind ratelimit limits their rate, -kind mutex and rwmutex serialize them, -kind hooks hooks them, -kind chaos fails them) and mock a type with a func field per method.
+fanout generates a type calling every implementation in a slice, joining the errors.
+fallback generates a type trying the implementations in a slice in order until one succeeds.
+tee generates a type calling a primary and a secondary implementation, returning the results of the primary.
+toggle generates a type routing each call to an old or a new implementation, per a feature flag.
+swap generates a type forwarding the calls to an implementation held in an atomic.Pointer, with Swap and Load methods.
+events generates a type forwarding the calls and publishing a record of each to a channel or a callback.
+counters generates a type forwarding the calls and counting the calls and errors per method with expvar.
+near lists the types of the packages (./... by default) that implement the interface or miss at most -max-missing methods.
+interfaces lists the interfaces of the packages (./... by default) the type implements or misses at most -max-missing methods of.
+providers generates the constructors of the implementations (the config targets written to the package of -o by default)
+and a wire.ProviderSet or an fx Module binding them to their interfaces.
+The flags are shared by all the commands.`)
 	flag.PrintDefaults()
 	os.Exit(1)
 }
 
 var named = flag.Bool("named", false, "Generate named return values.")
-var goimports = flag.Bool("goimports", true, "Run goimports on the generated code.")
+var smartReturns = flag.Bool("smart-returns", false, "Only name the results of the methods returning several values of the same type. Overrides -named.")
+var goimports = flag.Bool("goimports", true, "Run goimports on the generated code. Without it, the imports are computed from the types (faster, not grouped).")
 var existing = flag.Bool("existing", false, "Would trigger generation of missing method for the existing type(struct). Note, that if you want to use a pointer receiver prefix the type with '&'.")
+var delegate = flag.Bool("delegate", false, "With -existing, forward the missing methods to a field (embedded or named) that has them instead of generating stubs.")
+var adapt = flag.String("adapt", "", "Implement the interface by calling the methods of this one (package.interfaceTypeName), held in the field next. A context.Context only one of them has is dropped or filled with context.Background().")
+var getters = flag.Bool("getters", false, "With -existing, the missing getters (Name() T or GetName() T) return the field of the same name (name or Name) instead of being stubs.")
+var funcType = flag.Bool("func", false, "Declare the type as a func with the signature of the single method of the interface, which calls it (like http.HandlerFunc).")
+var underlying = flag.String("underlying", "", "Declare the type with this underlying type instead of struct{}, e.g. 'map[string]string'.")
+var sidecar = flag.String("sidecar", "", "Read the per-method options (comment, skip, unimplemented, body) from this YAML or JSON file.")
+var unimplemented = flag.String("unimplemented", "panic", "What the generated methods do: panic, error (return an error), zero (return zero values), log, logfatal (log and exit), logerror (log and return an error), sentinel (return ErrNotImplemented) or grpc (return a codes.Unimplemented status).")
+var unimplementedFor = methodModes{}
+var bodies = methodBodies{}
+
+var importPaths = importMap{}
+var argNames = typeNames{}
+var rewrites stringList
+var configRewrites []string // The rewrite rules of the config, applied before the ones from -rules and -r.
+var rulesFile = flag.String("rules", "", "Read rewrite rules (like -r, one per line, # starts a comment) from this file.")
+
+func init() {
+	flag.Var(unimplementedFor, "unimplemented-for", "Override -unimplemented for a method: Method=mode. Can be repeated or comma separated.")
+	flag.Var(bodies, "body-for", "Custom body of a method: 'Method=statements', e.g. 'Close=return nil'. Can be repeated.")
+	flag.Var(importPaths, "import-map", "Use a fork of a package: old/path=new/path or old/path='name new/path'. Can be repeated or comma separated.")
+	flag.Var(argNames, "arg-names", "Preferred name of the arguments of a type, e.g. '*http.Request=req' (an entry for http.Request applies to *http.Request too). Can be repeated or comma separated.")
+	flag.Var(&rewrites, "r", "Rewrite rule applied to the generated code, like gofmt -r: 'pattern -> replacement'. Can be repeated.")
+}
+
+var structuredErrors = flag.Bool("structured-errors", false, "The not implemented errors are *notimpl.Error (github.com/sasha-s/goimpl/notimpl) with the names of the interface, type and method.")
+var blank = flag.Bool("blank", false, "Name the parameters of the stubs _, as their bodies do not use them.")
+var group = flag.Bool("group", false, "Collapse the adjacent parameters (and named results) of the same type: (from, to time.Time).")
+var lint = flag.Bool("lint", false, "Generate code passing the common linters: the stubs return errors (unless -unimplemented is set) and blank the parameters, the delegated errors are wrapped, the exported methods and type are documented.")
+var maxLineLen = flag.Int("max-line-len", 0, "Put the parameters of the methods one per line if the declaration is longer than this. No limit if 0.")
+var receiver = flag.String("receiver", "", "Name of the receiver of the generated methods. The first letter of the type if empty (with -pos, the receiver of its methods).")
+var useAny = flag.Bool("any", false, "Write the empty interfaces as any instead of interface{} (Go 1.18+).")
+var helper = flag.String("helper", "", "Function the stubs call with the not implemented message instead of -unimplemented, as importpath.Func (e.g. example.com/report.NotImplemented) or Func of the generated package. It returns an error, which the methods returning an error return.")
+var argValues = flag.Bool("arg-values", false, "Include the values of the arguments in the not implemented messages (panics, errors, logs and -helper).")
+var panicFormat = flag.String("panic-format", "", "Template of the not implemented messages, e.g. '{{.Impl}}.{{.Method}} is not implemented yet (TODO: JIRA-123)'. Also has {{.Interface}}.")
+var callerLocation = flag.Bool("caller", false, "Include the file:line of the caller in the not implemented errors and logs.")
+var internalPlaceholders = flag.Bool("internal-placeholders", false, "Comment out the methods referring to the internal packages of other modules (which can not be implemented here) instead of failing.")
+var wrapErrors = flag.Bool("wrap-errors", false, "With -delegate, wrap the errors returned by the fields with the name of the method.")
 var verbose = flag.Bool("verbose", false, "print the generated code on error.")
+var output = flag.String("o", "", "Write the generated code to this file instead of stdout.")
+var templateFile = flag.String("template", "", "Use the template from this file instead of the default one.")
+var cache = flag.Bool("cache", true, "Cache the compiled bootstrap programs. See 'goimpl clean-cache'.")
+var fragment = flag.Bool("fragment", false, "Only print the methods: no package clause, no imports, no type declaration.")
+var parts = flag.Bool("parts", false, "Print the imports, the type, the other declarations and the methods separately, as JSON.")
+var region = flag.Bool("region", false, "Replace the '// goimpl:begin interface' ... '// goimpl:end' region of the output file (appended if missing) instead of overwriting the file.")
+var snippet = flag.String("snippet", "", "Print the methods as an editor snippet: vscode or ultisnips.")
+var snippetPrefix = flag.String("snippet-prefix", "impl", "The trigger of the snippet.")
+var pos = flag.String("pos", "", "Implement the interface for the type declared at this position (file.go:#offset or file.go:line[:column]) and insert the methods after the declaration.")
+var workfile = flag.String("workfile", "", "Resolve the packages through this go.work file ('off' disables the workspace mode). By default go.work is looked up starting from the directory of the output.")
+var pluginFile = flag.String("plugin", "", "Resolve the types using the goimpl.Descriptor exported by this plugin (built with -buildmode=plugin) instead of compiling a bootstrap program.")
+var diagnostics = flag.String("diagnostics", "text", "Report the errors as text or json (one object per line with the code, message, location and hint).")
+var configFile = flag.String("config", defaultConfig, "Read default flag values from this file. Flags set explicitly take precedence.")
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "init":
+			check(initCmd(os.Args[2:]))
+			return
+		case "clean-cache":
+			check(cleanCache())
+			return
+		}
+	}
+	cmd, args := "stub", os.Args[1:]
+	if isCommand(args) {
+		cmd, args = args[0], args[1:]
+	}
 	flag.Usage = usage
-	flag.Parse()
-	if flag.NArg() < 2 {
-		usage()
-	}
-	args := flag.Args()
-	n := len(args)
-	extras := args[:n-2]
-	a := args[n-2:]
-	inter, typeName := a[0], a[1]
-	opts := GenOpts{Inter: inter, NoGoImports: !*goimports, NoNamedReturnValues: !*named, Extra: extras}
-	if !*existing {
+	flag.CommandLine.Parse(args)
+	check(setupLogger())
+	check(startProfiling())
+	defer stopProfiling()
+	done := timed("config", "file", *configFile)
+	cfg, err := loadConfig(*configFile)
+	check(err)
+	check(cfg.apply())
+	done(nil, "targets", len(cfg.Targets))
+	logger.Debug("command", "name", cmd, "args", flag.Args())
+	dir := "."
+	if *output != "" {
+		dir = filepath.Dir(*output)
+	}
+	check(setupWorkspace(*workfile, dir))
+	if *diagnostics != "text" && *diagnostics != "json" {
+		check(fmt.Errorf("unknown -diagnostics %q, want text or json", *diagnostics))
+	}
+	check(commands[cmd](cfg, flag.Args()))
+}
+
+func countTrue(bs ...bool) int {
+	n := 0
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// convert converts the generated code to the requested output format.
+// With -region, the code replaces the region named inter of the output file instead.
+func convert(code []byte, file, inter string) ([]byte, error) {
+	if *region {
+		return replaceRegion(code, file, inter)
+	}
+	if *snippet != "" {
+		return goimpl.Snippet(*snippet, *snippetPrefix, code)
+	}
+	if !*parts {
+		return code, nil
+	}
+	p, err := goimpl.SplitCode(code)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(p, "", "\t")
+	return append(b, '\n'), err
+}
+
+// generate generates the targets either using a plugin or a bootstrap program.
+// The interfaces from the standard library (and, with -source, the rest of them) do not need either.
+func generate(targets []GenOpts) ([]result, error) {
+	if *pluginFile != "" {
+		done := timed("generate", "via", "plugin", "plugin", *pluginFile, "targets", len(targets))
+		results, err := runPlugin(*pluginFile, targets)
+		done(err)
+		return results, err
+	}
+	results := make([]result, len(targets))
+	var rest []GenOpts
+	var idx []int
+	for i, t := range targets {
+		var ok bool
+		// Keep the names of the parameters and the aliases from the declaration of the interface.
+		// The templates of the commands reserve their locals, so the parameters do not clash with them.
+		t.ParamNames, t.Aliases = true, true
+		done := timed("generate", "via", "std", "interface", t.Inter)
+		if t.InterFile != "" {
+			done = timed("generate", "via", "file", "interface", t.Inter, "file", t.InterFile)
+			results[i], ok = generateFromFile(t), true
+		} else if results[i], ok = generateStd(t); !ok && *source {
+			done = timed("generate", "via", "source", "interface", t.Inter)
+			results[i], ok = generateSource(t)
+		}
+		if !ok {
+			rest = append(rest, t)
+			idx = append(idx, i)
+			continue
+		}
+		done(results[i].err)
+	}
+	if len(rest) == 0 {
+		return results, nil
+	}
+	done := timed("generate", "via", "bootstrap", "targets", len(rest))
+	r, err := run(rest, *cache)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range idx {
+		results[i] = r[j]
+	}
+	return results, nil
+}
+
+// newOpts returns the options to generate an implementation of inter.
+// typeName is either the type to generate or the existing type (if existing is set).
+func newOpts(inter, typeName string, existing bool, extras ...[]string) (GenOpts, error) {
+	if n := countTrue(*fragment, *parts, *snippet != "", *region); n > 1 {
+		return GenOpts{}, errors.New("only one of -fragment, -parts, -snippet and -region can be set.")
+	}
+	opts := GenOpts{Inter: inter, NoGoImports: !*goimports, NoNamedReturnValues: !*named, Fragment: *fragment, Delegate: *delegate,
+		Unimplemented: *unimplemented, UnimplementedFor: unimplementedFor, Bodies: bodies, StructuredErrors: *structuredErrors, WrapErrors: *wrapErrors,
+		Getters: *getters, CallerLocation: *callerLocation, PanicFormat: *panicFormat, ArgValues: *argValues, Helper: *helper, FuncType: *funcType, Underlying: *underlying,
+		ImportMap: importPaths, Adapt: *adapt, Header: *header, TypeDoc: *typeDoc, UseAny: *useAny, SmartReturnValues: *smartReturns,
+		ReceiverName: *receiver, BlankArgs: *blank, GroupParams: *group, MaxLineLen: *maxLineLen, Lint: *lint,
+		InternalPlaceholders: *internalPlaceholders, ArgNames: argNames}
+	if *lint {
+		// Unless -unimplemented is set, -lint picks what the stubs do.
+		opts.Unimplemented = ""
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "unimplemented" {
+				opts.Unimplemented = *unimplemented
+			}
+		})
+	}
+	for _, e := range extras {
+		opts.Extra = append(opts.Extra, e...)
+	}
+	if file, name, ok := splitFileInterface(inter); ok {
+		pkgName, err := packageClause(file)
+		if err != nil {
+			return opts, err
+		}
+		opts.Inter, opts.InterFile = pkgName+"."+name, file
+	}
+	pkgPath, name, version, err := splitVersion(opts.Inter)
+	if err != nil {
+		return opts, err
+	}
+	if version != "" {
+		if existing {
+			return opts, errors.New("versioned interfaces can not be used with existing types.")
+		}
+		dir, pkgName, err := versionedModule(pkgPath, version)
+		if err != nil {
+			return opts, err
+		}
+		opts.Inter, opts.Dir = pkgName+"."+name, dir
+		opts.Extra = append(opts.Extra, pkgPath)
+	}
+	if *sidecar != "" {
+		if opts.Sidecar, err = filepath.Abs(*sidecar); err != nil {
+			return opts, err
+		}
+	}
+	// The rules of the config come first, then the ones from -rules and -r.
+	opts.Rewrites = append([]string(nil), configRewrites...)
+	if *rulesFile != "" {
+		rules, err := readRules(*rulesFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Rewrites = append(opts.Rewrites, rules...)
+	}
+	opts.Rewrites = append(opts.Rewrites, rewrites...)
+	if *templateFile != "" {
+		t, err := ioutil.ReadFile(*templateFile)
+		if err != nil {
+			return opts, err
+		}
+		// An untouched copy of the default template (e.g. the one goimpl init extracts) is the default template:
+		// it follows the changes of the default instead of freezing it.
+		if string(t) != goimpl.DefaultTemplate {
+			opts.Template = string(t)
+		}
+	}
+	if !existing {
 		pi, err := parse(typeName)
-		check(err)
+		if err != nil {
+			return opts, err
+		}
 		opts.ImplName = pi.ptr + pi.name
 		opts.PkgName = pi.pkg
+		opts.TypeParams = pi.typeParams
 	} else {
 		opts.Existing = typeName
 		if !strings.HasSuffix(typeName, "}") && !strings.HasSuffix(typeName, ")") {
@@ -52,20 +318,197 @@ func main() {
 			opts.Existing += "{}"
 		}
 	}
+	return opts, nil
+}
 
-	buf := new(bytes.Buffer)
-	check(tm.Execute(buf, opts))
+// generateAll generates all the targets from the config file, running the bootstrap program once.
+func generateAll(cfg *config) error {
+	targets := make([]GenOpts, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		opts, err := newOpts(t.Interface, t.Type, t.Existing, cfg.Imports, t.Imports)
+		if err != nil {
+			return err
+		}
+		if t.Sidecar != "" {
+			if opts.Sidecar, err = filepath.Abs(cfg.path(t.Sidecar)); err != nil {
+				return err
+			}
+		}
+		opts.OutDir = filepath.Dir(cfg.path(t.Output))
+		provenance(&opts, cfg.path(t.Output))
+		targets[i] = opts
+	}
+	results, err := generate(targets)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, r := range results {
+		t := cfg.Targets[i]
+		if r.err != nil {
+			report(os.Stderr, fmt.Errorf("%s %s: %w", t.Interface, t.Type, r.err))
+			failed++
+			continue
+		}
+		out, err := convert(r.out, cfg.path(t.Output), t.Interface)
+		if err != nil {
+			return err
+		}
+		if err := write(cfg.path(t.Output), out); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(results))
+	}
+	return nil
+}
 
-	src, err := imports.Process("", buf.Bytes(), nil)
-	check(err, "imports:", buf.String())
+// write writes the generated code to the file or to stdout if the file name is empty.
+// A file that already has this content is left alone (so is its modification time).
+func write(file string, b []byte) (err error) {
+	unchanged := false
+	done := timed("write", "file", file, "bytes", len(b))
+	defer func() { done(err, "unchanged", unchanged) }()
+	if file == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	if old, err := ioutil.ReadFile(file); err == nil && bytes.Equal(old, b) {
+		unchanged = true
+		fmt.Fprintf(os.Stderr, "%s is up to date.\n", file)
+		return nil
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
 
-	check(run(src), "run:", string(src))
+// replaceRegion puts the generated code in the region named inter of the file.
+func replaceRegion(code []byte, file, inter string) ([]byte, error) {
+	if file == "" {
+		return nil, errors.New("-region needs an output file.")
+	}
+	p, err := goimpl.SplitCode(code)
+	if err != nil {
+		return nil, err
+	}
+	src, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		src, err = []byte("package "+p.Package+"\n"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return goimpl.ReplaceRegion(src, inter, p)
+}
+
+// stringList is a flag.Value for flags that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// readRules reads the rewrite rules from the file: one per line, # starts a comment.
+func readRules(file string) ([]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []string
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "#") {
+			rules = append(rules, l)
+		}
+	}
+	return rules, nil
+}
+
+// methodModes is a flag.Value for Method=mode pairs.
+type methodModes map[string]string
+
+func (m methodModes) String() string {
+	var pairs []string
+	for name, mode := range m {
+		pairs = append(pairs, name+"="+mode)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m methodModes) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("expected Method=mode, got %q", pair)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return nil
+}
+
+// methodBodies is a flag.Value for Method=statements pairs.
+// Unlike methodModes, the values are not split on commas (return nil, err).
+type methodBodies map[string]string
+
+func (m methodBodies) String() string {
+	return methodModes(m).String()
+}
+
+func (m methodBodies) Set(s string) error {
+	name, body, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected Method=statements, got %q", s)
+	}
+	m[strings.TrimSpace(name)] = body
+	return nil
+}
+
+// importMap is a flag.Value for old/path=new/path pairs.
+type importMap map[string]string
+
+func (m importMap) String() string {
+	return methodModes(m).String()
+}
+
+func (m importMap) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("expected old/path=new/path, got %q", pair)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return nil
+}
+
+// typeNames is a flag.Value for type=name pairs.
+type typeNames map[string]string
+
+func (m typeNames) String() string {
+	return methodModes(m).String()
+}
+
+func (m typeNames) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("expected type=name, got %q", pair)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return nil
 }
 
 type parsedType struct {
-	ptr  string
-	pkg  string
-	name string
+	ptr        string
+	pkg        string
+	name       string
+	typeParams string // [K comparable, V any] for generic types.
 }
 
 func parse(tp string) (parsedType, error) {
@@ -75,6 +518,10 @@ func parse(tp string) (parsedType, error) {
 	if len(t) != len(tp) {
 		ptr = "*"
 	}
+	typeParams := ""
+	if i := strings.Index(t, "["); i >= 0 {
+		t, typeParams = t[:i], t[i:]
+	}
 
 	parts := strings.Split(t, ".")
 	var pkg, name string
@@ -88,45 +535,125 @@ func parse(tp string) (parsedType, error) {
 	default:
 		return parsedType{}, fmt.Errorf("failed to parse ``%s`. Expected [package.]type.", t)
 	}
-	return parsedType{ptr, pkg, name}, nil
+	return parsedType{ptr, pkg, name, typeParams}, nil
 }
 
 // GenOpts: code generation options.
 type GenOpts struct {
-	PkgName             string   // target package.
-	ImplName            string   // type (struct) that would implement the interface.
-	Inter               string   // Interface to implement.
-	Existing            string   // Existing type that we want to implement the interface.
-	NoNamedReturnValues bool     // Do not generate named return values. The generated code might not compiple if this is set.
-	NoGoImports         bool     // No goimports if set. Faster. The generated code might not compile.
-	Extra               []string // Extra imports.
+	PkgName              string              // target package.
+	ImplName             string              // type (struct) that would implement the interface.
+	ReceiverName         string              // Name of the receiver of the generated methods.
+	Inter                string              `json:"-"` // Interface to implement.
+	Dir                  string              `json:"-"` // Module to build the bootstrap program in. The current one if empty.
+	Existing             string              `json:"-"` // Existing type that we want to implement the interface.
+	Adapt                string              `json:"-"` // Interface to implement Inter with.
+	Forward              bool                // Forward the calls to the field next (the wrap command).
+	OutDir               string              `json:"-"` // Directory of the output.
+	InterFile            string              `json:"-"` // File (or directory) declaring Inter, if given as file.go:Interface.
+	NoNamedReturnValues  bool                // Do not generate named return values.
+	SmartReturnValues    bool                // Only name the results of the same type.
+	NoGoImports          bool                // No goimports if set. Faster. The generated code might not compile.
+	Extra                []string            // Extra imports.
+	Template             string              // Custom template.
+	Locals               []string            // Identifiers the method bodies of the template use.
+	ExtraMethods         []string            // Methods the template declares besides the ones of the interface.
+	Fragment             bool                // Only generate the methods.
+	Delegate             bool                // Forward the missing methods to the fields that have them.
+	Sidecar              string              // File with the per-method options.
+	Unimplemented        string              // What the generated methods do.
+	UnimplementedFor     map[string]string   // Overrides Unimplemented for those methods.
+	Bodies               map[string]string   // Custom bodies of those methods.
+	Declared             []string            // Variables declared in the package outside of the output.
+	StructuredErrors     bool                // The not implemented errors are *notimpl.Error.
+	WrapErrors           bool                // Wrap the errors returned by the fields the methods are delegated to.
+	Getters              bool                // The missing getters return the fields.
+	CallerLocation       bool                // Include the location of the caller in the not implemented errors.
+	PanicFormat          string              // Template of the not implemented messages.
+	ArgValues            bool                // Include the values of the arguments in the not implemented messages.
+	Helper               string              // Function the stubs call.
+	FuncType             bool                // Declare the type as a func.
+	Underlying           string              // Underlying type of the generated type.
+	TypeParams           string              // Type parameters of the generated type.
+	ParamNames           bool                // Keep the names of the parameters.
+	Aliases              bool                // Keep the aliases of the types.
+	UseAny               bool                // Write the empty interfaces as any.
+	BlankArgs            bool                // Name the parameters of the stubs _.
+	GroupParams          bool                // Collapse the adjacent parameters of the same type.
+	MaxLineLen           int                 // Put the parameters one per line in the longer declarations.
+	Lint                 bool                // Generate code passing the common linters.
+	Rewrites             []string            // Rewrite rules.
+	ImportMap            map[string]string   // Import paths to use instead of the ones of the types.
+	ArgNames             map[string]string   // Preferred names of the arguments by type.
+	MethodBlacklist      map[string]struct{} // Would not generate the code for those methods.
+	Header               string              // Template of the comment before the package clause.
+	TypeDoc              string              // Template of the doc comment of the type.
+	ModulePath           string              // Module the code is generated in.
+	PkgPath              string              // Import path of the package the code is generated in.
+	InternalPlaceholders bool                // Comment out the methods referring to the internal packages of other modules.
+	Revision             string              // VCS revision of the code the code is generated from.
 }
 
-func run(src []byte) error {
-	tempDir, err := ioutil.TempDir("", "goimpl_")
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Generate a bootsrap.go that would generate the final code using reflection.
-	tempFile := filepath.Join(tempDir, "bootsrap.go")
-	err = ioutil.WriteFile(tempFile, src, 0600)
-	if err != nil {
-		return err
+// lib returns the library options (without the types).
+func (t GenOpts) lib() goimpl.GenOpts {
+	return goimpl.GenOpts{
+		PkgName:              t.PkgName,
+		ImplName:             t.ImplName,
+		ReceiverName:         t.ReceiverName,
+		NoNamedReturnValues:  t.NoNamedReturnValues,
+		SmartReturnValues:    t.SmartReturnValues,
+		NoGoImports:          t.NoGoImports,
+		Extra:                t.Extra,
+		Template:             t.Template,
+		Locals:               t.Locals,
+		ExtraMethods:         t.ExtraMethods,
+		Fragment:             t.Fragment,
+		Delegate:             t.Delegate,
+		Sidecar:              t.Sidecar,
+		Unimplemented:        t.Unimplemented,
+		UnimplementedFor:     t.UnimplementedFor,
+		Bodies:               t.Bodies,
+		Declared:             t.Declared,
+		StructuredErrors:     t.StructuredErrors,
+		WrapErrors:           t.WrapErrors,
+		Getters:              t.Getters,
+		CallerLocation:       t.CallerLocation,
+		PanicFormat:          t.PanicFormat,
+		ArgValues:            t.ArgValues,
+		Helper:               t.Helper,
+		FuncType:             t.FuncType,
+		Underlying:           t.Underlying,
+		TypeParams:           t.TypeParams,
+		ParamNames:           t.ParamNames,
+		Aliases:              t.Aliases,
+		UseAny:               t.UseAny,
+		BlankArgs:            t.BlankArgs,
+		GroupParams:          t.GroupParams,
+		MaxLineLen:           t.MaxLineLen,
+		Lint:                 t.Lint,
+		Forward:              t.Forward,
+		Rewrites:             t.Rewrites,
+		ImportMap:            t.ImportMap,
+		ArgNames:             t.ArgNames,
+		MethodBlacklist:      t.MethodBlacklist,
+		Header:               t.Header,
+		TypeDoc:              t.TypeDoc,
+		ModulePath:           t.ModulePath,
+		PkgPath:              t.PkgPath,
+		InternalPlaceholders: t.InternalPlaceholders,
+		Revision:             t.Revision,
+		OnPhase: func(phase string, d time.Duration) {
+			logger.Debug(phase, "interface", t.Inter, "duration", d)
+		},
 	}
-
-	cmd := exec.Command("go", "run", tempFile) // Maybe add `-a`?.
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
 }
 
 func check(err error, extra ...interface{}) {
 	if err != nil {
 		m := err.Error()
-		if strings.Contains(m, "bootsrap.go") {
+		var d *goimpl.Diagnostic
+		if errors.As(err, &d) {
+			// Already explained.
+		} else if strings.Contains(m, "bootsrap.go") {
 			err = fmt.Errorf("Could not find the target interface : %v", err)
 		} else if strings.Contains(m, "dummy.go") {
 			err = fmt.Errorf("Something went wrong with the generated code: %v", err)
@@ -136,49 +663,11 @@ func check(err error, extra ...interface{}) {
 				extra = extra[:len(extra)-1]
 			}
 		}
-		if len(extra) > 0 {
-			fmt.Fprintln(os.Stderr, err, extra)
-		} else {
-			fmt.Fprintln(os.Stderr, err)
+		report(os.Stderr, err)
+		if len(extra) > 0 && *diagnostics != "json" {
+			fmt.Fprintln(os.Stderr, extra)
 		}
+		stopProfiling()
 		os.Exit(1)
 	}
 }
-
-const templateS = `
-package main
-
-import (
-	"reflect"
-	"github.com/sasha-s/goimpl"
-	{{range .Extra}}"{{.}}"
-	{{end}}
-)
-
-func main() {
-	err := goimpl.Generate(
-		&goimpl.GenOpts{
-			Inter: reflect.TypeOf((*{{.Inter}})(nil)).Elem(),
-			PkgName: "{{.PkgName}}",
-			ImplName: "{{.ImplName}}",
-			{{if .Existing}}Existing: {{.Existing}},{{end}}
-			NoNamedReturnValues: {{.NoNamedReturnValues}},
-			NoGoImports: {{.NoGoImports}},
-			Extra : []string{ {{range .Extra}} "{{.}}", {{end}} },
-		},
-		os.Stdout)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(-1)
-	}
-}
-`
-
-var tm = template.New("bootstrap")
-
-func init() {
-	_, err := tm.Parse(templateS)
-	if err != nil {
-		panic(err)
-	}
-}
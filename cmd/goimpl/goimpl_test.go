@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sasha-s/goimpl"
+)
+
+func TestGenerateCustomTemplateParamNames(t *testing.T) {
+	results, err := generate([]GenOpts{{Inter: "io.Reader", PkgName: "r", ImplName: "T", Template: goimpl.DefaultTemplate, NoGoImports: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].err != nil {
+		t.Fatal(results[0].err)
+	}
+	if want := "Read(p []byte)"; !strings.Contains(string(results[0].out), want) {
+		t.Errorf("expected %q in\n%s", want, results[0].out)
+	}
+}
+
+func TestWriteUnchanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "gen.go")
+	if err := write(file, []byte("package p\n")); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(file, []byte("package p\n")); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(file); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("the unchanged file was rewritten: %v, %v", fi.ModTime(), err)
+	}
+	if err := write(file, []byte("package q\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(file); err != nil || string(b) != "package q\n" {
+		t.Errorf("got %q, %v", b, err)
+	}
+}
+
+func TestGenerateStd(t *testing.T) {
+	r, ok := generateStd(GenOpts{Inter: "io.ReadCloser", PkgName: "pkg", ImplName: "T", ParamNames: true, NoGoImports: true})
+	if !ok || r.err != nil {
+		t.Fatalf("io.ReadCloser: %v, %v", ok, r.err)
+	}
+	for _, want := range []string{"package pkg", "func (t T) Read(p []byte)", "func (t T) Close() "} {
+		if !strings.Contains(string(r.out), want) {
+			t.Errorf("expected %q in\n%s", want, r.out)
+		}
+	}
+	// Not from the standard library, not an interface, ambiguous, or needing the bootstrap program.
+	for _, opts := range []GenOpts{
+		{Inter: "store.Store", ImplName: "T"},
+		{Inter: "bytes.Buffer", ImplName: "T"},
+		{Inter: "rand.Source", ImplName: "T"},
+		{Inter: "io.Reader", Existing: "pkg.T{}"},
+	} {
+		if _, ok := generateStd(opts); ok {
+			t.Errorf("%s: generated in-process", opts.Inter)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+const (
+	templateDir  = "goimpl_templates"
+	templateName = "stub.tmpl"
+	generateFile = "goimpl_generate.go"
+)
+
+const starterConfig = `{
+	"named": false,
+	"goimports": true,
+	"template": "` + templateDir + `/` + templateName + `",
+	"imports": []
+}
+`
+
+// initCmd scaffolds the configuration in a directory:
+// the config file, the default template and go:generate directives for the interfaces of the package.
+// Existing files are left alone.
+func initCmd(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory to initialize.")
+	fs.Parse(args)
+
+	if err := writeNew(filepath.Join(*dir, defaultConfig), []byte(starterConfig)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(*dir, templateDir), 0755); err != nil {
+		return err
+	}
+	if err := writeNew(filepath.Join(*dir, templateDir, templateName), []byte(goimpl.DefaultTemplate)); err != nil {
+		return err
+	}
+	src, err := generateDirectives(*dir)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return nil
+	}
+	return writeNew(filepath.Join(*dir, generateFile), src)
+}
+
+// writeNew writes the file unless it already exists.
+func writeNew(file string, b []byte) error {
+	if _, err := os.Stat(file); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists, skipping.\n", file)
+		return nil
+	}
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", file)
+	return nil
+}
+
+// generateDirectives returns a go file with go:generate directives for all exported interfaces in the package in dir.
+// Returns nil if there is nothing to generate.
+func generateDirectives(dir string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != generateFile
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, nil
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+	if pkg.Name == "main" {
+		fmt.Fprintln(os.Stderr, "interfaces of a main package can not be imported, no go:generate directives written.")
+		return nil, nil
+	}
+	var inters []string
+	for _, f := range pkg.Files {
+		inters = append(inters, interfaceNames(f)...)
+	}
+	if len(inters) == 0 {
+		return nil, nil
+	}
+	sort.Strings(inters)
+	importPath := ""
+	if out, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", dir).Output(); err == nil {
+		importPath = strings.TrimSpace(string(out)) + " "
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "package %s\n\n// Stubs for the interfaces of the package. Run `go generate` to (re)generate them.\n", pkg.Name)
+	for _, name := range inters {
+		impl := strings.ToLower(name[:1]) + name[1:] + "Stub"
+		fmt.Fprintf(buf, "//go:generate goimpl -o %s_stub.go %s%s.%s *%s.%s\n", strings.ToLower(name), importPath, pkg.Name, name, pkg.Name, impl)
+	}
+	return buf.Bytes(), nil
+}
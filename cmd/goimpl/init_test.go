@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestInit generates the stubs the way the go:generate directives written by init do, with its config.
+func TestInit(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":   "module example.com/st\n\ngo 1.21\n",
+		"store.go": "package st\n\ntype Store interface {\n\tGet(key string) (string, error)\n}\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := initCmd([]string{"-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(filepath.Join(dir, generateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, directive, ok := strings.Cut(string(src), "//go:generate goimpl ")
+	if !ok {
+		t.Fatalf("no go:generate directive in\n%s", src)
+	}
+	args := strings.Fields(strings.SplitN(directive, "\n", 2)[0])
+	if len(args) != 5 || args[0] != "-o" {
+		t.Fatalf("unexpected directive %q", directive)
+	}
+	cfg, err := loadConfig(filepath.Join(dir, defaultConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Template == "" {
+		t.Fatal("no template in the config")
+	}
+
+	gen := func(template string) []byte {
+		t.Helper()
+		defer func(old string) { *templateFile = old }(*templateFile)
+		*templateFile = template
+		opts, err := newOpts(args[3], args[4], false, cfg.Imports, args[2:3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := generate([]GenOpts{opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[0].err != nil {
+			t.Fatal(results[0].err)
+		}
+		return results[0].out
+	}
+	out := gen(cfg.path(cfg.Template))
+	if def := gen(""); !bytes.Equal(out, def) {
+		t.Errorf("the extracted template generates\n%s\nexpected the default\n%s", out, def)
+	}
+	if want := "Get(key string)"; !bytes.Contains(out, []byte(want)) {
+		t.Errorf("expected %q in\n%s", want, out)
+	}
+	if err := os.WriteFile(filepath.Join(dir, args[1]), out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := exec.Command("go", "vet", ".").CombinedOutput(); err != nil {
+		t.Errorf("go vet: %v\n%s", err, b)
+	}
+}
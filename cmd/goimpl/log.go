@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var logVerbose = flag.Bool("v", false, "Log the phases (config, resolution, build, generation, write) with their timings to stderr.")
+var logFormat = flag.String("log-format", "text", "Format of the -v logs: text or json.")
+
+// logger logs the phases of the command. It discards everything unless -v is set.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// setupLogger configures the logger from the flags.
+func setupLogger() error {
+	if !*logVerbose {
+		return nil
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch *logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("unknown -log-format %q, want text or json", *logFormat)
+	}
+	return nil
+}
+
+// timed starts a phase. The returned func logs it with its duration (and the error, if any).
+func timed(phase string, attrs ...any) func(err error, more ...any) {
+	start := time.Now()
+	return func(err error, more ...any) {
+		attrs := append(append(attrs, more...), "duration", time.Since(start))
+		if err != nil {
+			logger.Error(phase, append(attrs, "err", err)...)
+			return
+		}
+		logger.Info(phase, attrs...)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Interfaces from the modules the current module does not require are given as
+// importpath.Interface@version or importpath@version.Interface,
+// e.g. github.com/some/mod/pkg.Client@v1.4.0 or github.com/some/mod/pkg@v1.4.0.Client.
+// The bootstrap program for them is built in a temporary module requiring the package at that version
+// (and the goimpl the current module uses), with the replace directives of the current module.
+// The temporary modules are cached.
+
+// splitVersion splits importpath.Interface@version or importpath@version.Interface.
+// The version is empty if the interface is not versioned.
+func splitVersion(inter string) (pkgPath, name, version string, err error) {
+	i := strings.LastIndex(inter, "@")
+	if i < 0 {
+		return "", inter, "", nil
+	}
+	inter, version = inter[:i], inter[i+1:]
+	// The versions do not end with an exported identifier (e.g. v1.4.0, v0.0.0-20240101000000-abcdef123456, latest).
+	if dot := strings.LastIndex(version, "."); dot > 0 && token.IsExported(version[dot+1:]) && token.IsIdentifier(version[dot+1:]) {
+		return inter, version[dot+1:], version[:dot], nil
+	}
+	slash := strings.LastIndex(inter, "/")
+	dot := strings.LastIndex(inter, ".")
+	if version == "" || dot <= slash || dot == len(inter)-1 {
+		return "", "", "", fmt.Errorf("failed to parse %s@%s. Expected importpath.Interface@version.", inter, version)
+	}
+	return inter[:dot], inter[dot+1:], version, nil
+}
+
+// versionedModule returns the directory of the temporary module requiring pkgPath at the version,
+// and the name of the package.
+func versionedModule(pkgPath, version string) (dir, pkgName string, err error) {
+	out, err := goOutput("", "list", "-m", "-f", "{{.GoVersion}} {{.Dir}}", "github.com/sasha-s/goimpl")
+	goVersion, goimplDir := splitFirst(out)
+	if err != nil || goimplDir == "" {
+		return "", "", errors.New("github.com/sasha-s/goimpl must be required by the current module to implement versioned interfaces.")
+	}
+	cache, err := cacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	replaces, err := currentReplaces()
+	if err != nil {
+		return "", "", err
+	}
+	replaces = append(replaces, "github.com/sasha-s/goimpl => "+goimplDir)
+	h := sha256.Sum256([]byte(pkgPath + "@" + version + "\n" + strings.Join(replaces, "\n")))
+	dir = filepath.Join(cache, "modules", hex.EncodeToString(h[:]))
+	if _, err := os.Stat(filepath.Join(dir, "go.sum")); err != nil {
+		if err := newModule(dir, goVersion, replaces, pkgPath, version); err != nil {
+			return "", "", err
+		}
+	}
+	pkgName, err = goOutput(dir, "list", "-f", "{{.Name}}", pkgPath)
+	return dir, pkgName, err
+}
+
+func splitFirst(s string) (string, string) {
+	parts := strings.SplitN(s, " ", 2)
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// currentReplaces returns the replace directives of the current module (if any), as old => new.
+// The local replacements are made absolute.
+func currentReplaces() ([]string, error) {
+	gomod, err := goOutput("", "env", "GOMOD")
+	if err != nil || gomod == "" || gomod == os.DevNull {
+		return nil, err
+	}
+	out, err := goOutput("", "mod", "edit", "-json", gomod)
+	if err != nil {
+		return nil, err
+	}
+	var mod struct {
+		Replace []struct {
+			Old, New struct {
+				Path    string
+				Version string
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &mod); err != nil {
+		return nil, err
+	}
+	var replaces []string
+	for _, r := range mod.Replace {
+		if r.Old.Path == "github.com/sasha-s/goimpl" {
+			continue
+		}
+		old, new := r.Old.Path, r.New.Path
+		if r.Old.Version != "" {
+			old += " " + r.Old.Version
+		}
+		if r.New.Version != "" {
+			new += " " + r.New.Version
+		} else if !filepath.IsAbs(new) {
+			new = filepath.Join(filepath.Dir(gomod), new)
+		}
+		replaces = append(replaces, old+" => "+new)
+	}
+	return replaces, nil
+}
+
+// newModule creates the module in dir.
+// The go version is the one of goimpl, so the module graph is pruned the same way.
+func newModule(dir, goVersion string, replaces []string, pkgPath, version string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	tempDir, err := ioutil.TempDir(filepath.Dir(dir), "new_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	mod := fmt.Sprintf("module goimpl_bootstrap\n\ngo %s\n\nrequire github.com/sasha-s/goimpl v0.0.0\n", goVersion)
+	for _, r := range replaces {
+		mod += "\nreplace " + r + "\n"
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "go.mod"), []byte(mod), 0644); err != nil {
+		return err
+	}
+	// Keeps the requirements of both goimpl and the package when tidying.
+	deps := fmt.Sprintf("package main\n\nimport (\n\t_ %q\n\t_ %q\n)\n", "github.com/sasha-s/goimpl", pkgPath)
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "deps.go"), []byte(deps), 0644); err != nil {
+		return err
+	}
+	if _, err := goOutput(tempDir, "get", pkgPath+"@"+version); err != nil {
+		return err
+	}
+	if _, err := goOutput(tempDir, "mod", "tidy"); err != nil {
+		return err
+	}
+	// Rename is atomic, so concurrent invocations do not see partially written modules.
+	if err := os.Rename(tempDir, dir); err != nil && !os.IsExist(err) {
+		if _, serr := os.Stat(filepath.Join(dir, "go.sum")); serr != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// goOutput runs the go command in dir (the current directory if empty) and returns the trimmed output.
+// The errors include the stderr of the command.
+func goOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("go %s: %s", strings.Join(args, " "), strings.TrimSpace(string(ee.Stderr)))
+	}
+	return strings.TrimSpace(string(out)), err
+}
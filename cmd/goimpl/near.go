@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+var maxMissing = flag.Int("max-missing", 1, "With near and interfaces, the most methods a type can miss (or have with another signature) to be listed.")
+
+// nearCmd lists the types of the packages (./... by default) that implement the interface or nearly do,
+// with what they miss. The packages are type checked from source.
+func nearCmd(cfg *config, args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	imp, dir, err := sourceImporter()
+	if err != nil {
+		return err
+	}
+	tn, err := importType(imp, args[0], dir)
+	if err != nil {
+		return err
+	}
+	inter, ok := tn.Type().Underlying().(*types.Interface)
+	if !ok {
+		return fmt.Errorf("%s is not an interface.", args[0])
+	}
+	pkgs, err := loadPackages(imp, dir, args[1:])
+	if err != nil {
+		return err
+	}
+	for _, c := range goimpl.FindCandidates(inter, pkgs, *maxMissing) {
+		fmt.Printf("%s: %s\n", c.Type, problems(c))
+	}
+	return nil
+}
+
+// interfacesCmd lists the interfaces of the packages (./... by default) the type implements or nearly does,
+// with what it misses.
+func interfacesCmd(cfg *config, args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	imp, dir, err := sourceImporter()
+	if err != nil {
+		return err
+	}
+	tn, err := importType(imp, strings.TrimLeft(args[0], "*&"), dir)
+	if err != nil {
+		return err
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return fmt.Errorf("%s is not a named concrete type.", args[0])
+	}
+	pkgs, err := loadPackages(imp, dir, args[1:])
+	if err != nil {
+		return err
+	}
+	for _, m := range goimpl.FindInterfaces(named, pkgs, *maxMissing) {
+		fmt.Printf("%s.%s (%s): %s\n", m.Interface.Pkg().Path(), m.Interface.Name(), m.Type, problems(m.Candidate))
+	}
+	return nil
+}
+
+// problems describes what the candidate misses.
+func problems(c goimpl.Candidate) string {
+	var ps []string
+	for _, m := range c.Missing {
+		ps = append(ps, "missing "+m)
+	}
+	ps = append(ps, c.Wrong...)
+	if len(ps) == 0 {
+		return "implements"
+	}
+	return strings.Join(ps, "; ")
+}
+
+// sourceImporter returns an importer type checking the packages from source, as seen from the current directory.
+func sourceImporter() (types.ImporterFrom, string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	return importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom), dir, nil
+}
+
+// importType type checks the package of the type (importpath.typeName) from source.
+func importType(imp types.ImporterFrom, spec, dir string) (*types.TypeName, error) {
+	i := strings.LastIndex(spec, ".")
+	if i <= strings.LastIndex(spec, "/") {
+		return nil, fmt.Errorf("%s: use importpath.typeName.", spec)
+	}
+	pkg, err := imp.ImportFrom(spec[:i], dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	tn, ok := pkg.Scope().Lookup(spec[i+1:]).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a type.", spec)
+	}
+	return tn, nil
+}
+
+// loadPackages type checks the packages matching the patterns (./... if there are none).
+// The packages that fail to type check are skipped.
+func loadPackages(imp types.ImporterFrom, dir string, patterns []string) ([]*types.Package, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	done := timed("resolve", "packages", patterns)
+	listed, err := goList(dir, false, patterns...)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*types.Package
+	for _, path := range sortedKeys(listed) {
+		done := timed("load", "package", path)
+		pkg, err := imp.ImportFrom(path, dir, 0)
+		done(err)
+		if err == nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
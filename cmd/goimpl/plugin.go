@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// runPlugin generates the targets in-process, resolving the types via the descriptor exported by the plugin.
+func runPlugin(file string, targets []GenOpts) ([]result, error) {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(goimpl.DescriptorSymbol)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := sym.(*goimpl.Descriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s in %s is %T, want goimpl.Descriptor", goimpl.DescriptorSymbol, file, sym)
+	}
+	return generateDescribed(d, file, targets), nil
+}
+
+// generateDescribed generates the targets with the types of the descriptor (from the plugin file).
+func generateDescribed(d *goimpl.Descriptor, file string, targets []GenOpts) []result {
+	results := make([]result, len(targets))
+	var ok bool
+	for i, t := range targets {
+		opts := t.lib()
+		if opts.Inter, ok = d.Interfaces[t.Inter]; !ok {
+			results[i].err = fmt.Errorf("%s is not in the %s of %s", t.Inter, goimpl.DescriptorSymbol, file)
+			continue
+		}
+		if t.Existing != "" {
+			name := strings.TrimSuffix(t.Existing, "{}")
+			if opts.Existing, ok = d.Existing[name]; !ok {
+				results[i].err = fmt.Errorf("%s is not in the %s of %s", name, goimpl.DescriptorSymbol, file)
+				continue
+			}
+		}
+		if t.Adapt != "" {
+			if opts.Adapt, ok = d.Interfaces[t.Adapt]; !ok {
+				results[i].err = fmt.Errorf("%s is not in the %s of %s", t.Adapt, goimpl.DescriptorSymbol, file)
+				continue
+			}
+		}
+		results[i].out, results[i].err = goimpl.GenerateBytes(&opts)
+	}
+	return results
+}
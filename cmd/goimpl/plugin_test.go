@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// reader is an existing type implementing io.ReadCloser in part.
+type reader struct{}
+
+func (reader) Read(p []byte) (int, error) { return 0, io.EOF }
+
+func TestGenerateDescribed(t *testing.T) {
+	d := &goimpl.Descriptor{
+		Interfaces: map[string]reflect.Type{"io.ReadCloser": reflect.TypeOf((*io.ReadCloser)(nil)).Elem()},
+		Existing:   map[string]interface{}{"main.reader": reader{}},
+	}
+	results := generateDescribed(d, "types.so", []GenOpts{
+		{Inter: "io.ReadCloser", PkgName: "pkg", ImplName: "T", NoGoImports: true},
+		{Inter: "io.ReadCloser", Existing: "main.reader{}", NoGoImports: true},
+		{Inter: "io.Writer", PkgName: "pkg", ImplName: "T", NoGoImports: true},
+		{Inter: "io.ReadCloser", Existing: "main.writer{}", NoGoImports: true},
+	})
+	for i, want := range []string{"func (t T) Read(", "func (r reader) Close() ", "", ""} {
+		r := results[i]
+		switch {
+		case want == "" && r.err == nil:
+			t.Errorf("%d: expected an error, got\n%s", i, r.out)
+		case want != "" && r.err != nil:
+			t.Errorf("%d: %v", i, r.err)
+		case !strings.Contains(string(r.out), want):
+			t.Errorf("%d: expected %q in\n%s", i, want, r.out)
+		}
+	}
+	if out := string(results[1].out); strings.Contains(out, "Read(") {
+		t.Errorf("the existing Read is generated again:\n%s", out)
+	}
+}
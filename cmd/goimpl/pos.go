@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// implementAt generates the methods of inter for the type declared at pos (file.go:#offset or file.go:line[:column])
+// and inserts them after the declaration, adding the imports they need.
+// Methods the type already has (declared anywhere in the package) are skipped.
+// The updated file is written to out, or back to the file if out is empty.
+func implementAt(pos, inter string, extras []string, out string) error {
+	file, offset, err := parsePos(pos)
+	if err != nil {
+		return err
+	}
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		tf := fset.File(f.Pos())
+		if -offset > tf.LineCount() {
+			return fmt.Errorf("%s has only %d lines", file, tf.LineCount())
+		}
+		offset = tf.Offset(tf.LineStart(-offset))
+	}
+	decl, spec := typeAt(fset, f, offset)
+	if spec == nil {
+		return fmt.Errorf("no type declaration at %s", pos)
+	}
+	if spec.Assign.IsValid() {
+		return fmt.Errorf("%s at %s is an alias: implement the interface with the type it stands for", spec.Name.Name, pos)
+	}
+	if _, isInter := spec.Type.(*ast.InterfaceType); isInter {
+		return fmt.Errorf("%s at %s is an interface: interfaces can not have methods declared", spec.Name.Name, pos)
+	}
+	name := spec.Name.Name
+	existing, ptr, recv, err := methodsOf(filepath.Dir(file), name)
+	if err != nil {
+		return err
+	}
+	if _, isStruct := spec.Type.(*ast.StructType); ptr == nil && isStruct {
+		t := true
+		ptr = &t
+	}
+	opts, err := newOpts(inter, name, false, extras)
+	if err != nil {
+		return err
+	}
+	opts.PkgName = f.Name.Name
+	if ptr != nil && *ptr {
+		opts.ImplName = "*" + name
+	}
+	opts.MethodBlacklist = existing
+	if opts.ReceiverName == "" {
+		opts.ReceiverName = recv
+	}
+	opts.Fragment = false
+	results, err := generate([]GenOpts{opts})
+	if err != nil {
+		return err
+	}
+	if results[0].err != nil {
+		return results[0].err
+	}
+	p, err := goimpl.SplitCode(results[0].out)
+	if err != nil {
+		return err
+	}
+	if p.Methods == "" {
+		fmt.Fprintf(os.Stderr, "%s already implements %s.\n", name, inter)
+		return nil
+	}
+	// The declarations a template adds to the type (e.g. its errors) go before the methods.
+	missing, err := p.Missing(src)
+	if err != nil {
+		return err
+	}
+	end := fset.Position(decl.End()).Offset
+	updated := new(bytes.Buffer)
+	updated.Write(src[:end])
+	if missing != "" {
+		updated.WriteString("\n\n" + missing)
+	}
+	updated.WriteString("\n\n" + p.Methods)
+	updated.Write(src[end:])
+
+	// Add the imports.
+	fset = token.NewFileSet()
+	if f, err = parser.ParseFile(fset, file, updated.Bytes(), parser.ParseComments); err != nil {
+		return err
+	}
+	for _, imp := range p.Imports {
+		astutil.AddNamedImport(fset, f, imp.Name, imp.Path)
+	}
+	updated.Reset()
+	if err := format.Node(updated, fset, f); err != nil {
+		return err
+	}
+	if out == "" {
+		out = file
+	}
+	return write(out, updated.Bytes())
+}
+
+// parsePos parses file.go:#offset or file.go:line[:column].
+// A line is returned as a negative offset.
+func parsePos(pos string) (string, int, error) {
+	i := strings.LastIndex(pos, ":#")
+	if i >= 0 {
+		offset, err := strconv.Atoi(pos[i+2:])
+		if err != nil {
+			return "", 0, fmt.Errorf("bad offset in %s: %v", pos, err)
+		}
+		return pos[:i], offset, nil
+	}
+	parts := strings.Split(pos, ":")
+	for len(parts) > 2 {
+		// Ignore the column.
+		if _, err := strconv.Atoi(parts[len(parts)-2]); err != nil {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) < 2 {
+		return "", 0, errors.New("expected file.go:#offset or file.go:line[:column], got " + pos)
+	}
+	line, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("bad line in %s", pos)
+	}
+	return strings.Join(parts[:len(parts)-1], ":"), -line, nil
+}
+
+// typeAt finds the type declaration at the offset.
+func typeAt(fset *token.FileSet, f *ast.File, offset int) (*ast.GenDecl, *ast.TypeSpec) {
+	in := func(n ast.Node) bool {
+		return fset.Position(n.Pos()).Offset <= offset && offset <= fset.Position(n.End()).Offset
+	}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE || !in(gd) {
+			continue
+		}
+		if len(gd.Specs) == 1 {
+			return gd, gd.Specs[0].(*ast.TypeSpec)
+		}
+		for _, spec := range gd.Specs {
+			if in(spec) {
+				return gd, spec.(*ast.TypeSpec)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// methodsOf returns the names of the methods declared for the type in the package in dir,
+// whether they have pointer receivers (nil if there are no methods) and the name of the receiver.
+func methodsOf(dir, name string) (map[string]struct{}, *bool, string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	methods := map[string]struct{}{}
+	var ptr *bool
+	recv := ""
+	for _, p := range pkgs {
+		for _, f := range p.Files {
+			for _, d := range f.Decls {
+				fd, ok := d.(*ast.FuncDecl)
+				if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 {
+					continue
+				}
+				t := fd.Recv.List[0].Type
+				star, isPtr := t.(*ast.StarExpr)
+				if isPtr {
+					t = star.X
+				}
+				if id, ok := t.(*ast.Ident); ok && id.Name == name {
+					methods[fd.Name.Name] = struct{}{}
+					ptr = &isPtr
+					if names := fd.Recv.List[0].Names; len(names) > 0 && names[0].Name != "_" {
+						recv = names[0].Name
+					}
+				}
+			}
+		}
+	}
+	return methods, ptr, recv, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePos(t *testing.T) {
+	for _, c := range []struct {
+		pos    string
+		file   string
+		offset int
+	}{
+		{"a.go:#42", "a.go", 42},
+		{"a.go:7", "a.go", -7},
+		{"a.go:7:3", "a.go", -7},
+		{`C:\src\a.go:7:3`, `C:\src\a.go`, -7},
+		{"dir:x/a.go:#0", "dir:x/a.go", 0},
+	} {
+		file, offset, err := parsePos(c.pos)
+		if err != nil {
+			t.Errorf("%s: %v", c.pos, err)
+			continue
+		}
+		if file != c.file || offset != c.offset {
+			t.Errorf("%s: got %s, %d, expected %s, %d", c.pos, file, offset, c.file, c.offset)
+		}
+	}
+	for _, pos := range []string{"a.go", "a.go:#x", "a.go:0", "a.go:x"} {
+		if _, _, err := parsePos(pos); err == nil {
+			t.Errorf("%s: expected an error", pos)
+		}
+	}
+}
+
+const posSrc = `package p
+
+type (
+	A struct{}
+	B int
+)
+
+type C struct {
+	n int
+}
+
+func f() {}
+`
+
+func TestTypeAt(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", posSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		at   string // The offset is that of the first occurrence.
+		name string
+	}{
+		{"A struct", "A"},
+		{"B int", "B"},
+		{"n int", "C"},
+		{"type C", "C"},
+		{"func f", ""},
+	} {
+		_, spec := typeAt(fset, f, strings.Index(posSrc, c.at))
+		name := ""
+		if spec != nil {
+			name = spec.Name.Name
+		}
+		if name != c.name {
+			t.Errorf("at %q: got %q, expected %q", c.at, name, c.name)
+		}
+	}
+}
+
+func TestImplementAtRejects(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "p.go")
+	src := "package p\n\ntype Store interface{ Get() }\n\ntype ID = string\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ pos, want string }{
+		{file + ":3", "is an interface"},
+		{file + ":5", "is an alias"},
+		{file + ":1", "no type declaration"},
+	} {
+		err := implementAt(c.pos, "io.Reader", nil, "")
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, expected %q", c.pos, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var cpuProfile = flag.String("cpuprofile", "", "Write a CPU profile to this file.")
+var memProfile = flag.String("memprofile", "", "Write a heap profile to this file on exit.")
+var traceFile = flag.String("trace", "", "Write an execution trace to this file.")
+
+// stopProfiling stops the profiles started by startProfiling and writes them. Safe to call more than once.
+var stopProfiling = func() {}
+
+// startProfiling starts the profiles requested with the flags.
+func startProfiling() error {
+	var stops []func()
+	stopProfiling = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+		stops = nil
+	}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if *memProfile != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				logger.Error("memprofile", "err", err)
+				return
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				logger.Error("memprofile", "err", err)
+			}
+		})
+	}
+	return nil
+}
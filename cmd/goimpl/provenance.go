@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+var header = flag.String("header", "", "Template of the comment before the package clause, e.g. 'Code generated by goimpl {{.GoimplVersion}}. DO NOT EDIT.' See the README for the variables.")
+var typeDoc = flag.String("type-doc", "", "Template of the doc comment of the generated type.")
+
+// provenance fills in the module and package the code is generated in (the package is also used to report the methods
+// referring to the internal packages of other modules) and the revision, if the header or type doc templates use it.
+// out is the output file (stdout if empty).
+func provenance(opts *GenOpts, out string) {
+	uses := func(field string) bool {
+		return strings.Contains(opts.Header, "."+field) || strings.Contains(opts.TypeDoc, "."+field)
+	}
+	dir, err := filepath.Abs(filepath.Dir(out))
+	if err != nil {
+		return
+	}
+	opts.ModulePath, opts.PkgPath = modulePaths(dir)
+	if uses("Revision") {
+		cmd := exec.Command("git", "rev-parse", "HEAD")
+		cmd.Dir = dir
+		if b, err := cmd.Output(); err == nil {
+			opts.Revision = strings.TrimSpace(string(b))
+		}
+	}
+	if usesSentinel(opts) {
+		// ErrNotImplemented might be declared by the other generated files of the package.
+		_, _, opts.Declared, _ = declared(dir, out)
+	}
+}
+
+// usesSentinel reports whether some of the methods return ErrNotImplemented.
+func usesSentinel(opts *GenOpts) bool {
+	if opts.Unimplemented == goimpl.UnimplementedSentinel {
+		return true
+	}
+	for _, mode := range opts.UnimplementedFor {
+		if mode == goimpl.UnimplementedSentinel {
+			return true
+		}
+	}
+	return false
+}
+
+// modulePaths returns the path of the module dir is in and the import path of the package in dir.
+// In the workspace mode, the module is the innermost one.
+func modulePaths(dir string) (modulePath, pkgPath string) {
+	out, err := goOutput(dir, "list", "-m", "-f", "{{.Path}} {{.Dir}}")
+	if err != nil {
+		return "", ""
+	}
+	root := ""
+	for _, line := range strings.Split(out, "\n") {
+		path, mdir := splitFirst(line)
+		rel, err := filepath.Rel(mdir, dir)
+		if err != nil || strings.HasPrefix(rel, "..") || len(mdir) < len(root) {
+			continue
+		}
+		root, modulePath, pkgPath = mdir, path, path
+		if rel != "." {
+			pkgPath += "/" + filepath.ToSlash(rel)
+		}
+	}
+	return modulePath, pkgPath
+}
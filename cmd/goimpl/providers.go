@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+var di = flag.String("di", goimpl.ProvidersWire, "With providers, the dependency injection framework: wire (a ProviderSet) or fx (a Module).")
+
+// providersCmd generates the constructors of the implementations and a wire.ProviderSet (or an fx Module) binding them
+// to their interfaces. The implementations are given as package.interfaceTypeName=[*]typeName,
+// the other arguments are imports. Without implementations, the config targets written to the package of -o are used.
+func providersCmd(cfg *config, args []string) error {
+	opts := goimpl.ProvidersOpts{Framework: *di, NoGoImports: !*goimports, Extra: cfg.Imports}
+	var types []string
+	for _, arg := range args {
+		inter, typeName, ok := strings.Cut(arg, "=")
+		if !ok {
+			opts.Extra = append(opts.Extra, arg)
+			continue
+		}
+		opts.Bindings = append(opts.Bindings, goimpl.Binding{Interface: inter})
+		types = append(types, typeName)
+	}
+	dir, err := filepath.Abs(filepath.Dir(*output))
+	if err != nil {
+		return err
+	}
+	if len(opts.Bindings) == 0 {
+		for _, t := range cfg.Targets {
+			out, err := filepath.Abs(cfg.path(t.Output))
+			if err != nil || t.Output == "" || filepath.Dir(out) != dir {
+				continue
+			}
+			opts.Bindings = append(opts.Bindings, goimpl.Binding{Interface: t.Interface})
+			types = append(types, t.Type)
+			opts.Extra = append(opts.Extra, t.Imports...)
+		}
+	}
+	if len(opts.Bindings) == 0 {
+		return errors.New("no implementations: give them as package.interfaceTypeName=[*]typeName or list the targets in the config.")
+	}
+	for i, typeName := range types {
+		// &T implements with the pointer receiver, like *T. The values of existing types ({} or (nil)) are dropped.
+		typeName = strings.TrimSuffix(strings.TrimSuffix(typeName, "{}"), "(nil)")
+		pi, err := parse(strings.Replace(typeName, "&", "*", 1))
+		if err != nil {
+			return err
+		}
+		if pi.typeParams != "" {
+			return errors.New("providers of generic types are not supported: " + typeName)
+		}
+		opts.Bindings[i].Type = pi.ptr + pi.name
+		if opts.PkgName == "" {
+			opts.PkgName = pi.pkg
+		}
+	}
+	pkgName, funcs, _, err := declared(dir, *output)
+	if err != nil {
+		return err
+	}
+	opts.Declared = funcs
+	if pkgName != "" {
+		opts.PkgName = pkgName
+	}
+	if opts.PkgName == "" {
+		return errors.New("unknown package: qualify the types (package.typeName).")
+	}
+	b, err := goimpl.Providers(opts)
+	if err != nil {
+		return err
+	}
+	return write(*output, b)
+}
+
+// declared returns the name of the package in dir and the top level functions and variables declared in it,
+// skipping the tests and the output file (which is about to be regenerated).
+func declared(dir, output string) (pkgName string, funcs, vars []string, err error) {
+	out := ""
+	if output != "" {
+		if out, err = filepath.Abs(output); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if file == out || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		pkgName = f.Name.Name
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					funcs = append(funcs, d.Name.Name)
+				}
+			case *ast.GenDecl:
+				if d.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range d.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						vars = append(vars, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return pkgName, funcs, vars, nil
+}
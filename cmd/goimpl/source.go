@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"path"
+	"strings"
+
+	"github.com/sasha-s/goimpl"
+)
+
+var source = flag.Bool("source", true, "Resolve the interfaces by loading their packages from source (go/packages) instead of compiling a bootstrap program. -existing, -adapt and the interfaces that fail to load use the bootstrap program.")
+var exportData = importMap{}
+
+func init() {
+	flag.Var(exportData, "export-data", "Load the package from this export data file (.a) if its source is not available: import/path=file.a. Can be repeated or comma separated.")
+}
+
+// generateSource generates the target from its interface, loaded from source with go/packages.
+// ok is false if the target needs the bootstrap program: with -existing or -adapt, or if the interface can not be loaded.
+func generateSource(t GenOpts) (r result, ok bool) {
+	if t.Existing != "" || t.Adapt != "" {
+		return r, false
+	}
+	qualifier, name, _ := strings.Cut(t.Inter, ".")
+	dir := t.Dir
+	if dir == "" {
+		dir = "."
+	}
+	pkgPath, err := sourcePackage(qualifier, dir, t.OutDir, t.Extra)
+	if err != nil {
+		logger.Debug("source", "interface", t.Inter, "err", err)
+		return r, false
+	}
+	named, err := goimpl.LoadInterface(pkgPath+"."+name, dir)
+	if err != nil {
+		logger.Debug("source", "interface", t.Inter, "err", err)
+		if named, err = loadExport(pkgPath, name, dir); err != nil {
+			logger.Debug("export", "interface", t.Inter, "err", err)
+			return r, false
+		}
+	}
+	if pkg := named.Obj().Pkg(); !named.Obj().Exported() && t.PkgName != "" && t.PkgName != pkg.Name() {
+		r.err = &goimpl.Diagnostic{Code: goimpl.UnexportedInterface, Location: t.Inter, Hint: "generate the implementation in package " + pkg.Name() + ".",
+			Message: fmt.Sprintf("unexported interface %s can only be implemented in package %s", name, pkg.Name())}
+		return r, true
+	}
+	return generateNamed(t, pkgPath, named), true
+}
+
+// loadExport loads the interface from the export data of its package, for the packages without source.
+func loadExport(pkgPath, name, dir string) (*types.Named, error) {
+	pkg, err := goimpl.LoadExport(pkgPath, dir, exportData)
+	if err != nil {
+		return nil, err
+	}
+	return goimpl.InterfaceOf(pkg, name)
+}
+
+// sourcePackage returns the import path of the package named name:
+// one of the extra imports, the package of the output (in outDir) or a standard library package.
+func sourcePackage(name, dir, outDir string, extras []string) (string, error) {
+	if len(extras) > 0 {
+		pkgs, err := goList(dir, false, extras...)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range extras {
+			if p, ok := pkgs[e]; ok && p.Name == name {
+				return e, nil
+			}
+		}
+		for _, e := range extras {
+			// Without source (see -export-data), the name of the package is not known.
+			if p := pkgs[e]; p.Name == "" && path.Base(e) == name {
+				return e, nil
+			}
+		}
+	}
+	if outDir != "" {
+		// The interface might be declared next to the output (an unexported one has to be).
+		if pkgs, err := goList(outDir, false, "."); err == nil {
+			for path, p := range pkgs {
+				if p.Name == name {
+					return path, nil
+				}
+			}
+		}
+	}
+	if pkgPath, ok := stdPackage(name, nil); ok {
+		return pkgPath, nil
+	}
+	return "", &goimpl.Diagnostic{Code: goimpl.UnresolvableType, Message: fmt.Sprintf("no package named %s", name),
+		Location: name, Hint: "pass its import path as an extra argument."}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	gobuild "go/build"
+	"go/importer"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sasha-s/goimpl"
+)
+
+// The interfaces from the standard library are resolved from the export data of the installed GOROOT
+// and generated in-process, without compiling a bootstrap program.
+
+// generateStd generates the target if its interface is from the standard library.
+// ok is false if it is not (or can not be resolved that way), so the bootstrap program should be used.
+func generateStd(t GenOpts) (r result, ok bool) {
+	if t.Existing != "" || t.Adapt != "" || t.Dir != "" {
+		return r, false
+	}
+	parts := strings.Split(t.Inter, ".")
+	if len(parts) != 2 {
+		return r, false
+	}
+	pkgPath, ok := stdPackage(parts[0], t.Extra)
+	if !ok {
+		return r, false
+	}
+	pkg, err := importer.Default().Import(pkgPath)
+	if err != nil {
+		return r, false
+	}
+	tn, isType := pkg.Scope().Lookup(parts[1]).(*types.TypeName)
+	if !isType || !tn.Exported() {
+		return r, false
+	}
+	named, isNamed := tn.Type().(*types.Named)
+	if !isNamed || named.TypeParams().Len() > 0 && t.TypeParams == "" {
+		return r, false
+	}
+	if !types.IsInterface(named) {
+		return r, false
+	}
+	return generateNamed(t, pkgPath, named), true
+}
+
+// generateNamed generates the target in-process, given its interface described by go/types.
+func generateNamed(t GenOpts, pkgPath string, named *types.Named) (r result) {
+	inter, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		r.err = fmt.Errorf("%s is not an interface.", t.Inter)
+		return r
+	}
+	opts := t.lib()
+	opts.InterName = named.Obj().Pkg().Name() + "." + named.Obj().Name()
+	if opts.PkgName == "" {
+		opts.PkgName = named.Obj().Pkg().Name()
+	}
+	if opts.PkgName != named.Obj().Pkg().Name() && pkgPath != "" {
+		opts.Extra = appendNew(opts.Extra, pkgPath)
+	}
+	var out strings.Builder
+	r.err = goimpl.GenerateFromTypes(&opts, inter, nil, &out)
+	r.out = []byte(out.String())
+	return r
+}
+
+// stdPackage returns the import path of the standard library package named name.
+// The extra imports take precedence. Ambiguous names (e.g. rand) are not resolved.
+func stdPackage(name string, extras []string) (string, bool) {
+	for _, e := range extras {
+		if path.Base(e) == name {
+			return e, isStd(e)
+		}
+	}
+	paths := stdPackages()[name]
+	if len(paths) != 1 {
+		return "", false
+	}
+	return paths[0], true
+}
+
+func isStd(pkgPath string) bool {
+	if strings.Contains(strings.SplitN(pkgPath, "/", 2)[0], ".") {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(gobuild.Default.GOROOT, "src", filepath.FromSlash(pkgPath)))
+	return err == nil && fi.IsDir()
+}
+
+var stdOnce sync.Once
+var stdByName map[string][]string
+
+// stdPackages returns the import paths of the public standard library packages by name.
+func stdPackages() map[string][]string {
+	stdOnce.Do(func() {
+		stdByName = map[string][]string{}
+		src := filepath.Join(gobuild.Default.GOROOT, "src")
+		filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(src, p)
+			rel = filepath.ToSlash(rel)
+			switch base := path.Base(rel); {
+			case rel == ".":
+				return nil
+			case rel == "cmd" || base == "internal" || base == "vendor" || base == "testdata":
+				return filepath.SkipDir
+			case majorVersion(base):
+				// e.g. math/rand/v2, named rand.
+				base = path.Base(path.Dir(rel))
+				stdByName[base] = append(stdByName[base], rel)
+			default:
+				stdByName[base] = append(stdByName[base], rel)
+			}
+			return nil
+		})
+	})
+	return stdByName
+}
+
+func majorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// setupWorkspace makes the go commands (and goimports) resolve the packages through the go.work file,
+// so the interfaces from the other modules of the workspace can be found.
+// workfile is either a path, "off" to disable the workspace mode or empty.
+// If it is empty and GOWORK is not set, go.work is looked up starting from dir.
+func setupWorkspace(workfile, dir string) error {
+	switch {
+	case workfile == "off":
+		return os.Setenv("GOWORK", "off")
+	case workfile != "":
+		abs, err := filepath.Abs(workfile)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return err
+		}
+		return os.Setenv("GOWORK", abs)
+	case os.Getenv("GOWORK") != "":
+		return nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for {
+		f := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(f); err == nil {
+			return os.Setenv("GOWORK", f)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetupWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "go.work")
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(work, []byte("go 1.21\n\nuse ./a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		workfile, env, want string
+	}{
+		{"", "", work}, // Looked up from the directory.
+		{"", "/elsewhere/go.work", "/elsewhere/go.work"}, // GOWORK is kept.
+		{"off", "", "off"},
+		{work, "/elsewhere/go.work", work},
+	} {
+		t.Setenv("GOWORK", c.env)
+		if err := setupWorkspace(c.workfile, sub); err != nil {
+			t.Errorf("%q: %v", c.workfile, err)
+			continue
+		}
+		if got := os.Getenv("GOWORK"); got != c.want {
+			t.Errorf("%q with GOWORK=%q: got %q, expected %q", c.workfile, c.env, got, c.want)
+		}
+	}
+	if err := setupWorkspace(filepath.Join(dir, "missing.work"), sub); err == nil {
+		t.Error("missing workfile: expected an error")
+	}
+}
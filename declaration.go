@@ -0,0 +1,244 @@
+package goimpl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The reflection knows neither the names of the parameters nor the aliases the interface was declared with.
+// With ParamNames or Aliases, they are read from the source of the package of the interface, when it is available.
+
+// declaredMethod is a method as declared in the source.
+type declaredMethod struct {
+	params, results         []string   // Names of the parameters and the results ("" if unnamed).
+	paramTypes, resultTypes []ast.Expr // Their types.
+}
+
+// declaration is the declaration of the interface (opts.Inter).
+type declaration struct {
+	methods  map[string]declaredMethod // Methods declared directly (not embedded), by name.
+	pkgPath  string
+	imports  map[string]string          // Imports of the file of the interface, by name.
+	aliases  map[string]map[string]bool // Type aliases declared in the packages, by path.
+	pkgNames map[string]string          // Names of the packages, by path.
+}
+
+// loadDeclaration parses the declaration of opts.Inter. The embedded methods and the interfaces without source
+// keep the generated names and the reflected types.
+func (opts *GenOpts) loadDeclaration() {
+	opts.decl = &declaration{methods: map[string]declaredMethod{}, aliases: map[string]map[string]bool{}, pkgNames: map[string]string{}}
+	it := opts.Inter
+	if it == nil || it.PkgPath() == "" {
+		return
+	}
+	opts.decl.pkgPath = it.PkgPath()
+	files := parsePackage(it.PkgPath())
+	for _, f := range files {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts := s.(*ast.TypeSpec)
+				if iface, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == it.Name() {
+					opts.decl.methods = interfaceMethods(iface)
+					opts.decl.imports = fileImports(f)
+				}
+			}
+		}
+	}
+}
+
+// parsePackage parses the non-test files of the package.
+func parsePackage(pkgPath string) (files []*ast.File) {
+	bp, err := build.Import(pkgPath, "", build.FindOnly)
+	if err != nil {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(bp.Dir, "*.go"))
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// interfaceMethods returns the methods declared in the interface.
+func interfaceMethods(it *ast.InterfaceType) map[string]declaredMethod {
+	methods := map[string]declaredMethod{}
+	for _, m := range it.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			continue
+		}
+		var dm declaredMethod
+		dm.params, dm.paramTypes = fields(ft.Params)
+		dm.results, dm.resultTypes = fields(ft.Results)
+		methods[m.Names[0].Name] = dm
+	}
+	return methods
+}
+
+// fields returns a name and a type per parameter: a, b int is "a", "b", an unnamed one is "".
+func fields(fl *ast.FieldList) (names []string, types []ast.Expr) {
+	if fl == nil {
+		return nil, nil
+	}
+	for _, f := range fl.List {
+		if len(f.Names) == 0 {
+			names, types = append(names, ""), append(types, f.Type)
+		}
+		for _, n := range f.Names {
+			names, types = append(names, n.Name), append(types, f.Type)
+		}
+	}
+	return names, types
+}
+
+// fileImports returns the paths of the imports of the file, by the name they are used with.
+func fileImports(f *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+// qualifierRe matches the package qualifiers of a type.
+var qualifierRe = regexp.MustCompile(`([\pL_][\pL\pN_]*)\.`)
+
+// declaredName returns the declared name of the i-th argument (of names) with ParamNames,
+// unless it is taken or reserved (see reservedIdents). It is "" otherwise.
+func (opts *GenOpts) declaredName(names []string, i int, reserved map[string]bool, cur map[string]struct{}) string {
+	if !opts.ParamNames || i >= len(names) {
+		return ""
+	}
+	name := names[i]
+	if _, taken := cur[name]; name == "" || name == "_" || taken || reserved[name] {
+		return ""
+	}
+	cur[name] = struct{}{}
+	return name
+}
+
+// typeNames returns the types of the parameters and the results of the method, as they are rendered.
+func (opts *GenOpts) typeNames(ft reflect.Type) []string {
+	var ts []string
+	for i := 0; i < ft.NumIn(); i++ {
+		ts = append(ts, opts.GetName(ft.In(i)))
+	}
+	for i := 0; i < ft.NumOut(); i++ {
+		ts = append(ts, opts.GetName(ft.Out(i)))
+	}
+	return ts
+}
+
+var (
+	byteType = reflect.TypeOf(byte(0))
+	runeType = reflect.TypeOf(rune(0))
+	anyType  = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// aliasType returns the type t, declared as e, written with the aliases of the declaration.
+// ok reports whether it uses any: otherwise the type is written as by GetName.
+func (opts *GenOpts) aliasType(e ast.Expr, t reflect.Type) (s string, ok bool) {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return opts.aliasType(e.X, t)
+	case *ast.Ident:
+		switch {
+		case e.Name == "byte" && t == byteType, e.Name == "rune" && t == runeType, e.Name == "any" && t == anyType:
+			return e.Name, true
+		case opts.decl.isAlias(opts.decl.pkgPath, e.Name):
+			return opts.aliasName(opts.decl.pkgPath, e.Name), true
+		}
+	case *ast.SelectorExpr:
+		if x, isIdent := e.X.(*ast.Ident); isIdent {
+			if path, imported := opts.decl.imports[x.Name]; imported && opts.decl.isAlias(path, e.Sel.Name) {
+				return opts.aliasName(path, e.Sel.Name), true
+			}
+		}
+	case *ast.StarExpr:
+		if t.Kind() == reflect.Ptr {
+			s, ok := opts.aliasType(e.X, t.Elem())
+			return "*" + s, ok
+		}
+	case *ast.Ellipsis:
+		if t.Kind() == reflect.Slice {
+			s, ok := opts.aliasType(e.Elt, t.Elem())
+			return "..." + s, ok
+		}
+	case *ast.ArrayType:
+		if e.Len == nil && t.Kind() == reflect.Slice {
+			s, ok := opts.aliasType(e.Elt, t.Elem())
+			return "[]" + s, ok
+		}
+		if e.Len != nil && t.Kind() == reflect.Array {
+			s, ok := opts.aliasType(e.Elt, t.Elem())
+			return fmt.Sprintf("[%d]%s", t.Len(), s), ok
+		}
+	case *ast.MapType:
+		if t.Kind() == reflect.Map {
+			k, keyOK := opts.aliasType(e.Key, t.Key())
+			v, valueOK := opts.aliasType(e.Value, t.Elem())
+			return fmt.Sprintf("map[%s]%s", k, v), keyOK || valueOK
+		}
+	}
+	return opts.GetName(t), false
+}
+
+// isAlias reports whether the type name is declared as an alias in the package.
+func (d *declaration) isAlias(pkgPath, name string) bool {
+	aliases, ok := d.aliases[pkgPath]
+	if !ok {
+		aliases = map[string]bool{}
+		files := parsePackage(pkgPath)
+		for _, f := range files {
+			for _, decl := range f.Decls {
+				if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+					for _, s := range gd.Specs {
+						if ts := s.(*ast.TypeSpec); ts.Assign.IsValid() {
+							aliases[ts.Name.Name] = true
+						}
+					}
+				}
+			}
+			d.pkgNames[pkgPath] = f.Name.Name
+		}
+		d.aliases[pkgPath] = aliases
+	}
+	return aliases[name]
+}
+
+// aliasName returns the name of the alias declared in the package, qualified unless it is the package we generate code for.
+func (opts *GenOpts) aliasName(pkgPath, name string) string {
+	pkgName := opts.decl.pkgNames[pkgPath]
+	if pkgName == opts.PkgName {
+		return name
+	}
+	return opts.qualifier(pkgName, pkgPath) + "." + name
+}
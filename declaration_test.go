@@ -0,0 +1,60 @@
+package goimpl
+
+import (
+	"bytes"
+	"io"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParamNames(t *testing.T) {
+	tc := []struct {
+		inter    reflect.Type
+		expected string
+	}{
+		// Named in the source.
+		{reflect.TypeOf((*io.ReaderAt)(nil)).Elem(), "func (i *Impl) ReadAt(p []uint8, off int64) (n int, err error) {"},
+		// Unnamed in the source: the generated names.
+		{reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), "func (i *Impl) WriteRequest(r *rpc.Request, i1 interface{}) (err error) {"},
+	}
+	for _, c := range tc {
+		opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: c.inter, NoGoImports: true, ParamNames: true}
+		b, err := GenerateBytes(&opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), c.expected) {
+			t.Errorf("%s: expected %q in\n%s", c.inter, c.expected, b)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReaderAt)(nil)).Elem(), NoGoImports: true, Aliases: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) ReadAt(u []byte, i1 int64) (i2 int, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true, Aliases: true}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) WriteRequest(r *rpc.Request, i1 any) (err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/users", "package users\n\ntype ID = string\n\ntype Users interface {\n\tName(id ID) (string, error)\n}\n")
+	opts = GenOpts{PkgName: "fakes", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Users"), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Name(id users.ID) (s string, err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
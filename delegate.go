@@ -0,0 +1,62 @@
+package goimpl
+
+import "reflect"
+
+// delegates returns the fields of the existing type et to forward the missing methods to (see GenOpts.Delegate),
+// by method name. The first field (in the declaration order) with a method of the same signature is used.
+// The methods with pointer receivers are only used if the receiver is a pointer too: otherwise they would modify a copy.
+func (opts *GenOpts) delegates(et reflect.Type, missing []reflect.Method) map[string]string {
+	st, ptr := et, et.Kind() == reflect.Ptr
+	if ptr {
+		st = st.Elem()
+	}
+	r := map[string]string{}
+	if st.Kind() != reflect.Struct {
+		return r
+	}
+	for _, m := range missing {
+		for i := 0; i < st.NumField(); i++ {
+			f := st.Field(i)
+			if hasMethod(f.Type, m, ptr) {
+				r[m.Name] = f.Name
+				break
+			}
+		}
+	}
+	return r
+}
+
+// hasMethod reports whether the method can be called on a value of type t.
+func hasMethod(t reflect.Type, m reflect.Method, addressable bool) bool {
+	fm, ok := t.MethodByName(m.Name)
+	if !ok && addressable && t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		fm, ok = reflect.PtrTo(t).MethodByName(m.Name)
+	}
+	if !ok {
+		return false
+	}
+	ft := fm.Type
+	skip := 1 // The receiver.
+	if t.Kind() == reflect.Interface {
+		skip = 0
+	}
+	return sameSignature(ft, skip, m.Type)
+}
+
+// sameSignature reports whether the func type ft (without the first skip inputs) has the signature it.
+func sameSignature(ft reflect.Type, skip int, it reflect.Type) bool {
+	if ft.NumIn()-skip != it.NumIn() || ft.NumOut() != it.NumOut() || ft.IsVariadic() != it.IsVariadic() {
+		return false
+	}
+	for i := 0; i < it.NumIn(); i++ {
+		if ft.In(i+skip) != it.In(i) {
+			return false
+		}
+	}
+	for i := 0; i < it.NumOut(); i++ {
+		if ft.Out(i) != it.Out(i) {
+			return false
+		}
+	}
+	return true
+}
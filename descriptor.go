@@ -0,0 +1,27 @@
+package goimpl
+
+import "reflect"
+
+// DescriptorSymbol is the name of the variable the goimpl command looks up in plugins (see the -plugin flag).
+const DescriptorSymbol = "GoimplDescriptor"
+
+// Descriptor lists the types a plugin makes available to the goimpl command.
+// This allows to generate code without compiling a bootstrap program:
+//
+//	package main // Built with go build -buildmode=plugin.
+//
+//	var GoimplDescriptor = goimpl.Descriptor{
+//		Interfaces: map[string]reflect.Type{
+//			"io.Reader": reflect.TypeOf((*io.Reader)(nil)).Elem(),
+//		},
+//		Existing: map[string]interface{}{
+//			"w12.Writer":  w12.Writer{},
+//			"&w12.Writer": &w12.Writer{},
+//		},
+//	}
+//
+// The plugin must be built against the same version of goimpl as the command.
+type Descriptor struct {
+	Interfaces map[string]reflect.Type // Interfaces, keyed by package.interfaceTypeName.
+	Existing   map[string]interface{}  // Instances of the existing types, keyed by [&]package.typeName.
+}
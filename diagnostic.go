@@ -0,0 +1,300 @@
+package goimpl
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Code identifies the kind of a failure, so the tools wrapping goimpl can branch on it.
+type Code string
+
+// Diagnostic codes.
+const (
+	NotAnInterface      Code = "NotAnInterface"      // The type to implement is not an interface (or is a constraint).
+	UnexportedInterface Code = "UnexportedInterface" // The interface can not be implemented (or referred to) from the target package.
+	GenericInterface    Code = "GenericInterface"    // The interface has type parameters.
+	UnresolvableType    Code = "UnresolvableType"    // The interface or a type it uses can not be found.
+	NameCollision       Code = "NameCollision"       // The existing type has a field named as a method it is missing, or the template declares a method of the interface.
+	ReceiverMismatch    Code = "ReceiverMismatch"    // The existing type has the methods, but with pointer receivers.
+	InvalidOptions      Code = "InvalidOptions"      // The options are inconsistent.
+	InvalidTemplate     Code = "InvalidTemplate"     // The template can not be parsed or executed.
+	InvalidImplName     Code = "InvalidImplName"     // ImplName is not a type name.
+	InvalidCode         Code = "InvalidCode"         // The generated code can not be parsed or its imports fixed.
+	Other               Code = "Other"               // Anything else.
+)
+
+// Diagnostic is the error returned by goimpl.
+type Diagnostic struct {
+	Code     Code   `json:"code"`
+	Message  string `json:"message"`
+	Location string `json:"location,omitempty"` // What the diagnostic is about: an interface, a type or a file position.
+	Hint     string `json:"hint,omitempty"`     // How to fix it.
+	Err      error  `json:"-"`                  // The underlying error, if any.
+}
+
+// Sentinel errors, for errors.Is.
+// The diagnostics with the corresponding codes match ErrNotAnInterface and ErrInvalidImplName.
+var (
+	ErrNotAnInterface  = errors.New("not an interface")
+	ErrInvalidImplName = errors.New("invalid ImplName")
+	ErrImportsFailed   = errors.New("goimports failed") // Wrapped by the diagnostics about the imports that could not be fixed.
+)
+
+var sentinels = map[Code]error{
+	NotAnInterface:  ErrNotAnInterface,
+	InvalidImplName: ErrInvalidImplName,
+}
+
+// ErrFormatFailed is wrapped by the diagnostics about the generated code that can not be parsed or printed.
+// Use errors.As to get the unformatted code, e.g. to debug a template.
+type ErrFormatFailed struct {
+	Output []byte // The code before formatting.
+	Err    error
+}
+
+func (e *ErrFormatFailed) Error() string {
+	return "formatting failed: " + e.Err.Error()
+}
+
+func (e *ErrFormatFailed) Unwrap() error {
+	return e.Err
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Location == "" {
+		return d.Message
+	}
+	return d.Location + ": " + d.Message
+}
+
+// Unwrap returns the underlying error.
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Is reports whether target is the sentinel error for the code of the diagnostic.
+func (d *Diagnostic) Is(target error) bool {
+	sentinel, ok := sentinels[d.Code]
+	return ok && sentinel == target
+}
+
+func diagf(code Code, location, hint, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{Code: code, Message: fmt.Sprintf(format, args...), Location: location, Hint: hint}
+}
+
+// wrap sets the underlying error.
+func (d *Diagnostic) wrap(err error) *Diagnostic {
+	d.Err = err
+	return d
+}
+
+// checkInter reports the interfaces that can not be implemented in opts.PkgName.
+func (opts *GenOpts) checkInter() error {
+	if opts.Inter == nil {
+		return nil
+	}
+	if opts.Inter.Kind() != reflect.Interface {
+		return diagf(NotAnInterface, opts.Inter.String(), "check the name of the interface.",
+			"%s is not an interface", opts.Inter.Kind())
+	}
+	pkg, _ := packageAndName(opts.Inter)
+	for i := 0; i < opts.Inter.NumMethod(); i++ {
+		if m := opts.Inter.Method(i); m.PkgPath != "" && pkg != opts.PkgName {
+			return diagf(UnexportedInterface, opts.Inter.String(), "generate the implementation in package "+pkg+".",
+				"unexported method %s can only be implemented in package %s", m.Name, pkg)
+		}
+	}
+	return nil
+}
+
+// checkTypesMethods is checkInter for an interface described by go/types, generated in pkg (opts.PkgName if nil).
+func (opts *GenOpts) checkTypesMethods(inter *types.Interface, pkg *types.Package) error {
+	for i := 0; i < inter.NumMethods(); i++ {
+		m := inter.Method(i)
+		if m.Exported() || m.Pkg() == nil {
+			continue
+		}
+		if pkg != nil && m.Pkg().Path() != pkg.Path() || pkg == nil && m.Pkg().Name() != opts.PkgName {
+			return diagf(UnexportedInterface, inter.String(), "generate the implementation in package "+m.Pkg().Name()+".",
+				"unexported method %s can only be implemented in package %s", m.Name(), m.Pkg().Name())
+		}
+	}
+	return nil
+}
+
+// checkExtraMethods reports the methods of the interface the template declares too (see ExtraMethods).
+func (opts *GenOpts) checkExtraMethods() error {
+	if len(opts.ExtraMethods) == 0 {
+		return nil
+	}
+	for _, name := range opts.interMethods() {
+		if _, ok := opts.MethodBlacklist[name]; ok {
+			continue
+		}
+		for _, extra := range opts.ExtraMethods {
+			if name == extra {
+				return diagf(NameCollision, name, "blacklist the method, or use another template.",
+					"the template declares %s, a method of the interface, too", name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMethodNames reports the methods named in Bodies, UnimplementedFor and the sidecar (if any)
+// the interface does not have, e.g. misspelled.
+func (opts *GenOpts) checkMethodNames(sidecar *Sidecar) error {
+	methods := opts.interMethods()
+	if methods == nil {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, name := range methods {
+		have[name] = true
+	}
+	var names []string
+	for name := range opts.Bodies {
+		names = append(names, name)
+	}
+	for name := range opts.UnimplementedFor {
+		names = append(names, name)
+	}
+	if sidecar != nil {
+		for name := range sidecar.Methods {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !have[name] {
+			return diagf(InvalidOptions, name, "check the spelling: the methods are "+strings.Join(methods, ", ")+".",
+				"the interface has no method %s", name)
+		}
+	}
+	return nil
+}
+
+// interMethods returns the names of the methods of the interface, nil if it is not known yet.
+func (opts *GenOpts) interMethods() []string {
+	var names []string
+	switch {
+	case opts.Inter != nil && opts.Inter.Kind() == reflect.Interface:
+		for i := 0; i < opts.Inter.NumMethod(); i++ {
+			names = append(names, opts.Inter.Method(i).Name)
+		}
+	case opts.types != nil:
+		for i := 0; i < opts.types.inter.NumMethods(); i++ {
+			names = append(names, opts.types.inter.Method(i).Name())
+		}
+	}
+	return names
+}
+
+// checkFields reports the fields of the existing type et named as the methods to be generated:
+// the methods could not be declared.
+func (opts *GenOpts) checkFields(et reflect.Type, mtds []Method) error {
+	st := et
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		return nil
+	}
+	for _, m := range mtds {
+		if _, ok := opts.MethodBlacklist[m.Name]; ok {
+			continue
+		}
+		if f, ok := st.FieldByName(m.Name); ok && len(f.Index) == 1 {
+			return diagf(NameCollision, st.String()+"."+m.Name, "rename the field, or blacklist the method.",
+				"field %s %s blocks the method %s of %s", f.Name, f.Type, m.Name, opts.Inter)
+		}
+	}
+	return nil
+}
+
+// checkReceivers reports the methods missing from the existing type et that are declared with pointer receivers.
+func (opts *GenOpts) checkReceivers(et reflect.Type, mtds []Method) error {
+	if et.Kind() == reflect.Ptr {
+		return nil
+	}
+	var names []string
+	for _, m := range mtds {
+		if _, ok := opts.MethodBlacklist[m.Name]; ok {
+			continue
+		}
+		if _, ok := et.MethodByName(m.Name); ok {
+			continue
+		}
+		if _, ok := reflect.PtrTo(et).MethodByName(m.Name); ok {
+			names = append(names, m.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	method := "method " + names[0] + " exists"
+	if len(names) > 1 {
+		method = "methods " + strings.Join(names, ", ") + " exist"
+	}
+	return diagf(ReceiverMismatch, et.String(), fmt.Sprintf("pass '&%s' (or &%s{} as Existing) to implement %s with *%s.", et, et, opts.Inter, et),
+		"%s on *%s but you asked for %s", method, et, et)
+}
+
+// checkTypesInter is like checkInter, for an interface described by go/types.
+func checkTypesInter(inter *types.Interface) error {
+	if !inter.IsMethodSet() {
+		return diagf(NotAnInterface, inter.String(), "", "constraint interfaces can not be implemented")
+	}
+	for i := 0; i < inter.NumMethods(); i++ {
+		m := inter.Method(i)
+		if hasTypeParams(m.Type()) {
+			return diagf(GenericInterface, inter.String(), "instantiate the interface, e.g. use the type of a variable of the instantiated type.",
+				"method %s uses type parameters", m.Name())
+		}
+	}
+	return nil
+}
+
+// hasTypeParams reports whether t refers to type parameters.
+func hasTypeParams(t types.Type) bool {
+	found := false
+	visitTypeParams(t, func(*types.TypeParam) { found = true })
+	return found
+}
+
+// visitTypeParams calls visit for every type parameter t refers to.
+func visitTypeParams(t types.Type, visit func(*types.TypeParam)) {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		visit(t)
+	case *types.Pointer:
+		visitTypeParams(t.Elem(), visit)
+	case *types.Slice:
+		visitTypeParams(t.Elem(), visit)
+	case *types.Array:
+		visitTypeParams(t.Elem(), visit)
+	case *types.Chan:
+		visitTypeParams(t.Elem(), visit)
+	case *types.Map:
+		visitTypeParams(t.Key(), visit)
+		visitTypeParams(t.Elem(), visit)
+	case *types.Signature:
+		visitTypeParams(t.Params(), visit)
+		visitTypeParams(t.Results(), visit)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			visitTypeParams(t.At(i).Type(), visit)
+		}
+	case *types.Alias:
+		visitTypeParams(types.Unalias(t), visit)
+	case *types.Named:
+		args := t.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			visitTypeParams(args.At(i), visit)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package goimpl
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type withClose struct {
+	Close func() error
+}
+
+type withPtrClose struct{}
+
+func (*withPtrClose) Close() error { return nil }
+
+type private interface {
+	hidden()
+}
+
+const genericSrc = `package generic
+
+type Number interface{ ~int | ~float64 }
+
+type Getter[T any] interface {
+	Get() T
+}
+`
+
+func TestDiagnostics(t *testing.T) {
+	pkg := typeCheck(t, "example.com/generic", genericSrc)
+	f, err := parser.ParseFile(token.NewFileSet(), "src.go", "package p\n\ntype I interface{ Do(x.T) }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	astInter := f.Scope.Lookup("I").Decl.(*ast.TypeSpec).Type.(*ast.InterfaceType)
+	cases := []struct {
+		name string
+		gen  func() error
+		code Code
+	}{
+		{"struct", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf(struct{}{})}, ioutil.Discard)
+		}, NotAnInterface},
+		{"unexported method", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf((*private)(nil)).Elem()}, ioutil.Discard)
+		}, UnexportedInterface},
+		{"template", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf((*error)(nil)).Elem(), Template: "{{"}, ioutil.Discard)
+		}, InvalidTemplate},
+		{"existing and impl name", func() error {
+			return Generate(&GenOpts{ImplName: "T", Inter: reflect.TypeOf((*error)(nil)).Elem(), Existing: struct{}{}}, ioutil.Discard)
+		}, InvalidOptions},
+		{"field collision", func() error {
+			return Generate(&GenOpts{Inter: reflect.TypeOf((*io.Closer)(nil)).Elem(), Existing: withClose{}}, ioutil.Discard)
+		}, NameCollision},
+		{"template method collision", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf((*io.Closer)(nil)).Elem(), ExtraMethods: []string{"Close"}}, ioutil.Discard)
+		}, NameCollision},
+		{"receiver", func() error {
+			return Generate(&GenOpts{Inter: reflect.TypeOf((*io.ReadCloser)(nil)).Elem(), Existing: withPtrClose{}}, ioutil.Discard)
+		}, ReceiverMismatch},
+		{"constraint", func() error {
+			return GenerateFromTypes(&GenOpts{ImplName: "T"}, lookupInterface(t, pkg, "Number"), pkg, ioutil.Discard)
+		}, NotAnInterface},
+		{"generic", func() error {
+			return GenerateFromTypes(&GenOpts{ImplName: "T"}, lookupInterface(t, pkg, "Getter"), pkg, ioutil.Discard)
+		}, GenericInterface},
+		{"impl name", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "-bad name", Inter: reflect.TypeOf((*error)(nil)).Elem()}, ioutil.Discard)
+		}, InvalidImplName},
+		{"code", func() error {
+			return Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf((*error)(nil)).Elem(), Template: "package p\nfunc {"}, ioutil.Discard)
+		}, InvalidCode},
+		{"unknown package", func() error {
+			return GenerateFromAST(&GenOpts{ImplName: "T"}, astInter, f, ioutil.Discard)
+		}, UnresolvableType},
+	}
+	for _, c := range cases {
+		var d *Diagnostic
+		if err := c.gen(); !errors.As(err, &d) {
+			t.Errorf("%s: expected a diagnostic, got %v", c.name, err)
+		} else if d.Code != c.code {
+			t.Errorf("%s: expected %s, got %s (%v)", c.name, c.code, d.Code, d)
+		}
+	}
+	var buf bytes.Buffer
+	if err := Generate(&GenOpts{Inter: reflect.TypeOf((*io.ReadCloser)(nil)).Elem(), Existing: &withPtrClose{}, NoGoImports: true}, &buf); err != nil {
+		t.Errorf("pointer receiver: %v", err)
+	} else if strings.Contains(buf.String(), ") Close()") {
+		t.Errorf("pointer receiver: Close generated:\n%s", buf.String())
+	}
+	if err := Generate(&GenOpts{PkgName: "goimpl", ImplName: "T", Inter: reflect.TypeOf((*private)(nil)).Elem(), NoGoImports: true}, ioutil.Discard); err != nil {
+		t.Errorf("unexported method in the same package: %v", err)
+	}
+}
+
+func TestSentinels(t *testing.T) {
+	err := Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf(struct{}{})}, ioutil.Discard)
+	if !errors.Is(err, ErrNotAnInterface) || errors.Is(err, ErrInvalidImplName) {
+		t.Errorf("expected ErrNotAnInterface, got %v", err)
+	}
+	err = Generate(&GenOpts{PkgName: "p", ImplName: "*", Inter: reflect.TypeOf((*error)(nil)).Elem()}, ioutil.Discard)
+	if !errors.Is(err, ErrInvalidImplName) {
+		t.Errorf("expected ErrInvalidImplName, got %v", err)
+	}
+	err = Generate(&GenOpts{PkgName: "p", ImplName: "T", Inter: reflect.TypeOf((*error)(nil)).Elem(), Template: "package {{.PkgName}}\nfunc {"}, ioutil.Discard)
+	var ff *ErrFormatFailed
+	if !errors.As(err, &ff) {
+		t.Fatalf("expected ErrFormatFailed, got %v", err)
+	}
+	if string(ff.Output) != "package p\nfunc {" {
+		t.Errorf("expected the unformatted code, got %q", ff.Output)
+	}
+}
@@ -0,0 +1,52 @@
+package goimpl
+
+import (
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// LoadExport loads the package from its compiled export data with go/importer, for the dependencies whose source
+// is not available (binary-only or precompiled packages). files maps import paths to export data files
+// (.a archives or the export files of the build cache); the packages not in it, e.g. the dependencies,
+// are looked up with `go list -export` in dir (the current directory if empty).
+// The export data has to be produced by the same version of Go as goimpl is built with.
+func LoadExport(pkgPath, dir string, files map[string]string) (*types.Package, error) {
+	lookup := func(path string) (io.ReadCloser, error) {
+		file, ok := files[path]
+		if !ok {
+			var err error
+			if file, err = exportFile(path, dir); err != nil {
+				return nil, err
+			}
+		}
+		return os.Open(file)
+	}
+	imp := importer.ForCompiler(token.NewFileSet(), "gc", lookup)
+	pkg, err := imp.Import(pkgPath)
+	if err != nil {
+		return nil, diagf(UnresolvableType, pkgPath, "give the export data of the package (and the dependencies without source).", "%s", err.Error())
+	}
+	return pkg, nil
+}
+
+// exportFile returns the export data file of the package, compiled by the go command if it is not in the build cache yet.
+func exportFile(pkgPath, dir string) (string, error) {
+	cmd := exec.Command("go", "list", "-export", "-f", "{{.Export}}", pkgPath)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		return "", diagf(UnresolvableType, pkgPath, "", "%s", strings.TrimSpace(string(ee.Stderr)))
+	} else if err != nil {
+		return "", err
+	}
+	file := strings.TrimSpace(string(out))
+	if file == "" {
+		return "", diagf(UnresolvableType, pkgPath, "", "no export data for %s", pkgPath)
+	}
+	return file, nil
+}
@@ -0,0 +1,38 @@
+package goimpl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadExport(t *testing.T) {
+	const notimpl = "github.com/sasha-s/goimpl/notimpl"
+	file, err := exportFile(notimpl, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dependencies (errors, fmt...) are looked up with go list.
+	for _, files := range []map[string]string{nil, {notimpl: file}} {
+		pkg, err := LoadExport(notimpl, "", files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Scope().Lookup("Error") == nil {
+			t.Errorf("%v: expected notimpl.Error, got %v", files, pkg.Scope().Names())
+		}
+	}
+
+	pkg, err := LoadExport("io", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InterfaceOf(pkg, "Reader"); err != nil {
+		t.Error(err)
+	}
+	if _, err := InterfaceOf(pkg, "SectionReader"); !errors.Is(err, ErrNotAnInterface) {
+		t.Errorf("io.SectionReader: expected ErrNotAnInterface, got %v", err)
+	}
+	if _, err := LoadExport("example.com/nope", "", nil); err == nil {
+		t.Error("example.com/nope: expected an error")
+	}
+}
@@ -0,0 +1,56 @@
+package goimpl
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// WriteFS is a file system the generated files can be written to (see GenerateFiles).
+type WriteFS interface {
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// Overlay is a WriteFS keeping the files in memory: the content by file name.
+// Tests and build tools can capture the output without touching the disk.
+type Overlay map[string][]byte
+
+// WriteFile stores a copy of data.
+func (o Overlay) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	o[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// DirFS is a WriteFS writing to the directory on the disk.
+type DirFS string
+
+// WriteFile writes the file relative to the directory.
+func (d DirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(filepath.Join(string(d), filepath.FromSlash(name)), data, perm)
+}
+
+// GenerateFiles generates the implementations (options by file name) and writes them to fsys.
+// Nothing is written if any of the generations fails.
+func GenerateFiles(fsys WriteFS, files map[string]*GenOpts) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([][]byte, len(names))
+	for i, name := range names {
+		b, err := GenerateBytes(files[name])
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		out[i] = b
+	}
+	for i, name := range names {
+		if err := fsys.WriteFile(name, out[i], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
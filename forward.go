@@ -0,0 +1,35 @@
+package goimpl
+
+import (
+	"strings"
+)
+
+// checkForward checks the options of Forward and imports the package of the interface, the type of the field next.
+func (opts *GenOpts) checkForward() error {
+	if !opts.Forward {
+		return nil
+	}
+	if opts.Existing != nil || opts.FuncType || opts.Underlying != "" || opts.Adapt != nil {
+		return diagf(InvalidOptions, "", "", "Forward can not be set with Existing, FuncType, Underlying or Adapt.")
+	}
+	if opts.Inter == nil && opts.InterName == "" {
+		return diagf(InvalidOptions, "", "set InterName to the interface (package.Name).", "Forward needs the name of the interface described by go/types")
+	}
+	if opts.Inter != nil {
+		if pkg, _ := packageAndName(opts.Inter); pkg != opts.PkgName && opts.Inter.PkgPath() != "" {
+			opts.Extra = append(opts.Extra, opts.Inter.PkgPath())
+		}
+	}
+	return nil
+}
+
+// forwardType returns the type of the field next with Forward: the interface, as seen from the generated package.
+func (opts *GenOpts) forwardType() string {
+	if opts.Inter != nil {
+		return opts.GetName(opts.Inter)
+	}
+	if pkg, name, ok := strings.Cut(opts.InterName, "."); ok && pkg == opts.PkgName {
+		return name
+	}
+	return opts.InterName
+}
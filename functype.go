@@ -0,0 +1,131 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/parser"
+	"reflect"
+	"strings"
+)
+
+// checkFuncType checks that the interface has a single method to declare the func type with (see GenOpts.FuncType).
+func (opts *GenOpts) checkFuncType() error {
+	if !opts.FuncType {
+		return nil
+	}
+	if opts.Existing != nil {
+		return diagf(InvalidOptions, "", "", "only one of FuncType and Existing should be set.")
+	}
+	if n := len(opts.Methods(opts.Inter)); n != 1 {
+		return diagf(InvalidOptions, "", "implement the other methods on a struct, or blacklist them.",
+			"FuncType needs an interface with a single method, got %d", n)
+	}
+	opts.calls = map[string]bool{opts.Methods(opts.Inter)[0].Name: true}
+	return nil
+}
+
+// checkUnderlying checks that Underlying is a type a named type with methods can be declared with.
+func (opts *GenOpts) checkUnderlying() error {
+	if opts.Underlying == "" {
+		return nil
+	}
+	if opts.FuncType || opts.Existing != nil {
+		return diagf(InvalidOptions, "", "", "Underlying can not be set with FuncType or Existing.")
+	}
+	e, err := parser.ParseExpr(opts.Underlying)
+	if err != nil {
+		return diagf(InvalidOptions, opts.Underlying, "use a type, e.g. map[string]string.", "Error parsing Underlying: %s", err.Error())
+	}
+	switch e.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.IndexExpr, *ast.IndexListExpr:
+		return nil
+	}
+	return diagf(InvalidOptions, opts.Underlying, "use a struct, map, slice, func, chan or a basic type.",
+		"methods can not be declared on a type with underlying %s", opts.Underlying)
+}
+
+// funcCalls returns the missing methods of the existing func type et that can call it:
+// the ones with the same signature as the func.
+func funcCalls(et reflect.Type, missing []reflect.Method) map[string]bool {
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	r := map[string]bool{}
+	if et.Kind() != reflect.Func {
+		return r
+	}
+	for _, m := range missing {
+		if sameSignature(et, 0, m.Type) {
+			r[m.Name] = true
+		}
+	}
+	return r
+}
+
+// TypeDecl returns the underlying type of the generated type: the signature of the func with FuncType
+// (or of the existing func type), Underlying if set, a struct holding the next implementation with Adapt or Forward,
+// struct{} otherwise.
+func (opts *GenOpts) TypeDecl() string {
+	if et := opts.existingFunc(); et != nil {
+		return opts.GetName(reflect.FuncOf(ins(et), outs(et), et.IsVariadic()))
+	}
+	if opts.Underlying != "" {
+		return opts.Underlying
+	}
+	if opts.Adapt != nil {
+		return "struct {\nnext " + opts.GetName(opts.Adapt) + "\n}"
+	}
+	if opts.Forward {
+		return "struct {\nnext " + opts.forwardType() + "\n}"
+	}
+	if !opts.FuncType {
+		return "struct{}"
+	}
+	m := opts.Methods(opts.Inter)[0]
+	params := make([]string, len(m.Inputs))
+	for i, a := range m.Inputs {
+		params[i] = opts.GetName(a)
+	}
+	results := make([]string, len(m.Outputs))
+	for i, a := range m.Outputs {
+		results[i] = opts.GetName(a)
+	}
+	decl := "func(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+		return decl
+	case 1:
+		return decl + " " + results[0]
+	}
+	return decl + " (" + strings.Join(results, ", ") + ")"
+}
+
+// existingFunc returns the existing func type (without the pointer), nil if Existing is not a func.
+func (opts *GenOpts) existingFunc() reflect.Type {
+	if opts.Existing == nil {
+		return nil
+	}
+	et := reflect.TypeOf(opts.Existing)
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Func {
+		return nil
+	}
+	return et
+}
+
+func ins(t reflect.Type) []reflect.Type {
+	r := make([]reflect.Type, t.NumIn())
+	for i := range r {
+		r[i] = t.In(i)
+	}
+	return r
+}
+
+func outs(t reflect.Type) []reflect.Type {
+	r := make([]reflect.Type, t.NumOut())
+	for i := range r {
+		r[i] = t.Out(i)
+	}
+	return r
+}
@@ -0,0 +1,92 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// parseTypeParams parses GenOpts.TypeParams and returns the names of the type parameters.
+func (opts *GenOpts) parseTypeParams() ([]string, error) {
+	if opts.TypeParams == "" {
+		return nil, nil
+	}
+	if opts.Existing != nil {
+		return nil, diagf(InvalidOptions, "", "", "only one of TypeParams and Existing should be set.")
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p; type T"+opts.TypeParams+" struct{}", 0)
+	if err != nil {
+		return nil, diagf(InvalidOptions, opts.TypeParams, "use the syntax of a type declaration, e.g. [K comparable, V any].",
+			"bad type parameters: %s", err.Error())
+	}
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+	if spec.TypeParams == nil {
+		return nil, diagf(InvalidOptions, opts.TypeParams, "use the syntax of a type declaration, e.g. [K comparable, V any].",
+			"bad type parameters")
+	}
+	var names []string
+	for _, f := range spec.TypeParams.List {
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+	}
+	return names, nil
+}
+
+// TypeArgs returns the type parameters of the generated type as used in the receivers, e.g. [K, V].
+// Empty if the type is not generic.
+func (opts *GenOpts) TypeArgs() string {
+	if len(opts.typeParams) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(opts.typeParams, ", ") + "]"
+}
+
+// renameTypeParams maps the type parameters of the generic interface inter to the ones of the generated type, by position.
+func (opts *GenOpts) renameTypeParams(inter *types.Interface) (map[string]string, error) {
+	names, err := opts.parseTypeParams()
+	if err != nil {
+		return nil, err
+	}
+	rename := map[string]string{}
+	var bad error
+	for i := 0; i < inter.NumMethods(); i++ {
+		visitTypeParams(inter.Method(i).Type(), func(tp *types.TypeParam) {
+			if tp.Index() >= len(names) {
+				bad = diagf(GenericInterface, inter.String(), "declare a type parameter of the generated type for every type parameter of the interface.",
+					"type parameter %s of the interface has no counterpart in %s", tp.Obj().Name(), opts.TypeParams)
+				return
+			}
+			rename[tp.Obj().Name()] = names[tp.Index()]
+		})
+	}
+	return rename, bad
+}
+
+// renameIdents renames the identifiers in the type string s. Qualified identifiers are left alone.
+func renameIdents(s string, rename map[string]string) string {
+	var sc scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(s))
+	sc.Init(file, []byte(s), nil, 0)
+	var b strings.Builder
+	last, prev := 0, token.ILLEGAL
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if n, ok := rename[lit]; ok && tok == token.IDENT && prev != token.PERIOD {
+			off := file.Offset(pos)
+			b.WriteString(s[last:off])
+			b.WriteString(n)
+			last = off + len(lit)
+		}
+		prev = tok
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package goimpl
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// getters returns the fields of the existing type et the missing getters return (see GenOpts.Getters), by method name.
+// A getter has no inputs and a single output the field is assignable to. Name returns the field name,
+// GetName returns Name or name.
+func getters(et reflect.Type, missing []reflect.Method) map[string]string {
+	if et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	r := map[string]string{}
+	if et.Kind() != reflect.Struct {
+		return r
+	}
+	for _, m := range missing {
+		if m.Type.NumIn() != 0 || m.Type.NumOut() != 1 {
+			continue
+		}
+		names := []string{lowerFirst(m.Name)}
+		if rest := strings.TrimPrefix(m.Name, "Get"); rest != m.Name && rest != "" {
+			names = []string{rest, lowerFirst(rest)}
+		}
+		for _, name := range names {
+			if f, ok := et.FieldByName(name); ok && len(f.Index) == 1 && f.Type.AssignableTo(m.Type.Out(0)) {
+				r[m.Name] = name
+				break
+			}
+		}
+	}
+	return r
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
@@ -3,57 +3,267 @@ package goimpl
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
+	"go/ast"
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"go/types"
 	"io"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 
 	"golang.org/x/net/context"
 	"golang.org/x/tools/imports"
 )
 
+// Version of goimpl.
+const Version = "0.2.0"
+
 // GenOpts specifies code generation options.
 type GenOpts struct {
-	PkgName             string              // target package.
-	ImplName            string              // type (struct) that would implement the interface.
-	Inter               reflect.Type        // Interface to implement.
-	Existing            interface{}         // Existing type that we want to implement the interface.
-	NoNamedReturnValues bool                // Do not generate named return values. The generated code might not compiple if this is set.
-	MethodBlacklist     map[string]struct{} // Would not generate the code for those methods.
-	Comments            map[string]string   // Add comments to those methods in generated code.
-	NoGoImports         bool                // No goimports if set. Faster. The generated code might not compile.
-	Extra               []string            // Extra imports.
+	PkgName              string              // target package.
+	ImplName             string              // type (struct) that would implement the interface.
+	ReceiverName         string              // Name of the receiver of the generated methods. The first letter of ImplName if empty.
+	FixImplName          bool                // Replace an invalid ImplName with SanitizeImplName(ImplName) instead of failing.
+	Inter                reflect.Type        // Interface to implement.
+	Existing             interface{}         // Existing type that we want to implement the interface.
+	NoNamedReturnValues  bool                // Do not generate named return values. The bodies return the values explicitly, so the code compiles either way.
+	SmartReturnValues    bool                // Only name the results of the methods returning several values of the same type, which the names tell apart. Overrides NoNamedReturnValues.
+	MethodBlacklist      map[string]struct{} // Would not generate the code for those methods.
+	Comments             map[string]string   // Add comments to those methods in generated code.
+	NoGoImports          bool                // No goimports if set. Faster. The packages the types refer to are imported anyway (and the unused imports dropped), the ones a custom template uses need to be in Extra.
+	Extra                []string            // Extra imports.
+	Template             string              // Custom template (text/template syntax). DefaultTemplate is used if empty.
+	Locals               []string            // Identifiers the method bodies of the Template declare or refer to (e.g. the packages): the arguments are not named after them.
+	ExtraMethods         []string            // Methods (or fields) of the type the Template declares besides the ones of the interface: an interface with one of them is a NameCollision.
+	Fragment             bool                // Only generate the methods (and the declarations a template adds): no package clause, no imports, no type declaration.
+	Delegate             bool                // With Existing, forward the missing methods to a field (embedded or named) that has them.
+	Sidecar              string              // YAML or JSON file with per-method options (see Sidecar). Applied before generating.
+	Unimplemented        string              // What the generated methods do: UnimplementedPanic (if empty), UnimplementedError, UnimplementedZero, UnimplementedLog, UnimplementedLogFatal, UnimplementedLogError, UnimplementedSentinel or UnimplementedGRPC.
+	UnimplementedFor     map[string]string   // Overrides Unimplemented for those methods.
+	Bodies               map[string]string   // Custom bodies (the statements, without the braces) of those methods, e.g. "return nil" for Close.
+	BodyGenerator        BodyGenerator       `json:"-"` // Writes the bodies of the stubs instead of Unimplemented (the methods in Bodies keep theirs).
+	Helper               string              // Function the stubs call instead of Unimplemented, with the not implemented message (see PanicFormat): importpath.Func (e.g. example.com/report.NotImplemented) or Func of the generated package. It returns an error, which the methods returning an error return.
+	Declared             []string            // Top level identifiers declared in the package outside of the output, which are not declared again (ErrNotImplemented).
+	StructuredErrors     bool                // The not implemented errors are *notimpl.Error (see the notimpl package) with the names of the interface, type and method.
+	Getters              bool                // With Existing, the missing getters (Name() T or GetName() T) return the field of the same name (name or Name) instead of being stubs.
+	WrapErrors           bool                // With Delegate, wrap the errors returned by the field with the name of the method (fmt.Errorf with %w).
+	CallerLocation       bool                // Include the file:line of the caller in the not implemented errors and logs.
+	PanicFormat          string              // Template of the not implemented messages (panics, errors and logs) with PanicData, e.g. "{{.Impl}}.{{.Method}} is not implemented yet (TODO: JIRA-123)". The structured errors are not affected.
+	ArgValues            bool                // Include the values of the arguments (%#v) in the not implemented messages of the panics, errors, logs and the Helper: *Impl.Get not implemented (key="a"). Not in the structured errors. The arguments are not blank with it.
+	InterName            string              // Name of the interface (package.Name) in the messages and the structured errors, if it is described by go/types.
+	FuncType             bool                // Declare ImplName as a func type with the signature of the single method of the interface, which calls it (like http.HandlerFunc).
+	Underlying           string              // Underlying type of the generated type, e.g. "map[string]string" for a map-backed fake. struct{} if empty.
+	TypeParams           string              // Type parameters of the generated type, e.g. "[K comparable, V any]". Those of a generic interface are mapped to them by position.
+	ParamNames           bool                // Keep the names of the parameters and the results of the interface. With reflection, they are read from the source of its package, if available.
+	Aliases              bool                // Write the types with the aliases of the interface (type ID = string, byte, any). go/types keeps them; with reflection, they are read from the source of its package, if available.
+	UseAny               bool                // Write the empty interfaces as any (Go 1.18+) instead of interface{}.
+	BlankArgs            bool                // Name the inputs of the stubs _, as their bodies do not use them. The methods forwarding the calls (Delegate, Adapt, FuncType) keep the names; so should the custom templates using them.
+	GroupParams          bool                // Collapse the adjacent parameters (and named results) of the same type: (from, to time.Time).
+	MaxLineLen           int                 // Put the parameters of the methods one per line if the first line of the declaration is longer (in runes). No limit if 0.
+	Lint                 bool                // Generate code passing the common linters: the stubs return errors unless Unimplemented is set, BlankArgs, WrapErrors, and doc comments on the exported methods and type.
+	Rewrites             []string            // gofmt -r style rules applied to the generated code, e.g. "interface{} -> any".
+	ImportMap            map[string]string   // Import paths to use instead of the ones of the types: old path -> new path, or "name new/path" to import it with a name.
+	Adapt                reflect.Type        // Implement Inter by calling the methods of this interface, held in the field next. A leading context.Context only one of them has is dropped, or filled with context.Background().
+	Forward              bool                // Forward the calls to the field next, holding an implementation of the interface: a starting point for decorators. With go/types, the field is of type InterName. WrapErrors wraps the errors.
+	Header               string              // Template (text/template, executed with the options) of the comment before the package clause, e.g. "Code generated by goimpl {{.GoimplVersion}}. DO NOT EDIT.".
+	TypeDoc              string              // Template of the doc comment of the generated type (see TypeComment).
+	ModulePath           string              // Module the generated code is in, for the templates.
+	PkgPath              string              // Import path of the generated package, for the templates. If set, the methods referring to the internal packages of other modules are reported.
+	InternalPlaceholders bool                // With PkgPath, comment out the methods referring to the internal packages of other modules (with the reason) instead of failing.
+	Revision             string              // VCS revision the code is generated at, for the templates.
+	OnPhase              PhaseFunc           `json:"-"` // Called with the duration of every phase if set.
+	Naming               NamingStrategy      `json:"-"` // Names the arguments (unless ParamNames keeps them). ShortNames if nil.
+	ArgNames             map[string]string   // Preferred names of the arguments by type, written with the package names: "*http.Request": "req". An entry for http.Request applies to *http.Request and []http.Request too.
+
+	types        *typesInter       // Set by GenerateFromTypes.
+	delegated    map[string]string // Fields the methods are forwarded to, by method name.
+	fields       map[string]string // Fields the getters return, by method name.
+	adapted      map[string]string // Arguments of the calls to the methods of Adapt, by method name.
+	typeDoc      string            // Rendered TypeDoc.
+	decl         *declaration      // Declaration of Inter, with ParamNames or Aliases (reflection only).
+	renamed      map[string]string // Names of the packages imported explicitly (see renameImports), by path.
+	calls        map[string]bool   // Methods calling the receiver, a func type.
+	typeParams   []string          // Names of the type parameters of the generated type.
+	placeholders map[string]string // Reasons the methods can not be implemented (see checkInternal), by method name.
 }
 
 // Generate an empty implementation of the interface as specified in opts and write the result to out.
 func Generate(opts *GenOpts, out io.Writer) error {
+	_, err := GenerateBytes(opts, out)
+	return err
+}
+
+// GenerateBytes generates an empty implementation of the interface as specified in opts and returns the result.
+// The result is also written to every writer in tee, even if writing to some of them fails (the first error is returned).
+// Nothing is written if the generation fails.
+func GenerateBytes(opts *GenOpts, tee ...io.Writer) ([]byte, error) {
+	bts, err := opts.generate()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Fragment {
+		p, err := SplitCode(bts)
+		if err != nil {
+			return nil, err
+		}
+		bts = []byte(p.Methods)
+		if p.Decls != "" {
+			bts = []byte(p.Decls + "\n\n" + p.Methods)
+		}
+	}
+	for _, w := range tee {
+		if _, werr := w.Write(bts); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return bts, err
+}
+
+// PhaseFunc is called with the name of a phase of the generation (PhaseLoad, PhaseRender or PhaseFormat) and its duration.
+type PhaseFunc func(phase string, d time.Duration)
+
+// Phases of the generation, see GenOpts.OnPhase.
+const (
+	PhaseLoad   = "load"   // Loading the sidecar, comparing with the existing type, checking the options.
+	PhaseRender = "render" // Executing the template.
+	PhaseFormat = "format" // Parsing, rewriting, printing and goimports.
+)
+
+// phase starts a phase. The returned func reports its duration to OnPhase.
+func (opts *GenOpts) phase(name string) func() {
+	if opts.OnPhase == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { opts.OnPhase(name, time.Since(start)) }
+}
+
+func (opts *GenOpts) generate() ([]byte, error) {
+	done := opts.phase(PhaseLoad)
 	if opts.MethodBlacklist == nil {
 		opts.MethodBlacklist = map[string]struct{}{}
 	}
 	if opts.Comments == nil {
 		opts.Comments = map[string]string{}
 	}
+	var sidecar *Sidecar
+	if opts.Sidecar != "" {
+		var err error
+		if sidecar, err = LoadSidecar(opts.Sidecar); err != nil {
+			return nil, err
+		}
+		sidecar.Apply(opts)
+	}
+	opts.applyLint()
+	if err := opts.checkUnimplemented(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkArgNames(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkBodies(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkPanicFormat(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkHelper(); err != nil {
+		return nil, err
+	}
+	if opts.ReceiverName != "" && !token.IsIdentifier(opts.ReceiverName) {
+		return nil, diagf(InvalidOptions, opts.ReceiverName, "use an identifier.", "invalid receiver name %q", opts.ReceiverName)
+	}
+	if (opts.ParamNames || opts.Aliases) && opts.types == nil {
+		opts.loadDeclaration()
+	}
+	var err error
+	if opts.typeParams, err = opts.parseTypeParams(); err != nil {
+		return nil, err
+	}
+	rules := make([]rewriteRule, len(opts.Rewrites))
+	for i, r := range opts.Rewrites {
+		if rules[i], err = parseRewrite(r); err != nil {
+			return nil, err
+		}
+	}
 	if err := opts.handleExisting(); err != nil {
-		return err
+		return nil, err
+	}
+	if err := opts.checkImplName(); err != nil {
+		return nil, err
 	}
-	if opts.PkgName == "" {
+	if opts.PkgName == "" && opts.Inter != nil {
 		opts.PkgName, _ = packageAndName(opts.Inter)
 	}
-	buf := new(bytes.Buffer)
-	if err := tm.Execute(buf, opts); err != nil {
-		return err
+	if err := opts.checkInter(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkExtraMethods(); err != nil {
+		return nil, err
 	}
+	if err := opts.checkMethodNames(sidecar); err != nil {
+		return nil, err
+	}
+	if err := opts.checkFuncType(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkUnderlying(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkAdapt(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkForward(); err != nil {
+		return nil, err
+	}
+	if err := opts.checkInternal(); err != nil {
+		return nil, err
+	}
+	opts.renameImports()
+	opts.Extra = opts.unmapped(opts.addTypeImports(opts.Extra))
+	done()
+	done = opts.phase(PhaseRender)
+	t := tm
+	if opts.Template != "" {
+		var err error
+		if t, err = template.New("custom").Parse(opts.Template); err != nil {
+			return nil, diagf(InvalidTemplate, "", "", "Error parsing template: %s", err.Error())
+		}
+	}
+	header, err := opts.renderDocs()
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBufferString(header)
+	if err := t.Execute(buf, opts); err != nil {
+		return nil, diagf(InvalidTemplate, "", "", "%s", err.Error())
+	}
+	done()
+	defer opts.phase(PhaseFormat)()
 	// Parse it back.
 	fset := token.NewFileSet()
-	astFile, err := parser.ParseFile(fset, "dummy.go", buf, parser.ParseComments)
+	astFile, err := parser.ParseFile(fset, "dummy.go", opts.commentPlaceholders(buf.Bytes()), parser.ParseComments)
 	if err != nil {
-		return fmt.Errorf("Error parsing generated code: %s", err.Error())
+		return nil, diagf(InvalidCode, "", "check the template.", "Error parsing generated code: %s", err.Error()).
+			wrap(&ErrFormatFailed{Output: buf.Bytes(), Err: err})
+	}
+	for _, r := range rules {
+		astFile = r.apply(astFile)
+	}
+	if opts.GroupParams {
+		groupParams(astFile)
+	}
+	// Sort the imports and drop the duplicates (e.g. an Extra import a type refers to as well).
+	ast.SortImports(fset, astFile)
+	if opts.NoGoImports {
+		opts.dropUnusedImports(fset, astFile)
 	}
 	b := bytes.NewBuffer([]byte{})
 	// Print.
@@ -62,16 +272,20 @@ func Generate(opts *GenOpts, out io.Writer) error {
 		Tabwidth: 8,
 	}
 	if err = cfg.Fprint(b, fset, astFile); err != nil {
-		return err
+		return nil, diagf(InvalidCode, "", "", "Error printing generated code: %s", err.Error()).
+			wrap(&ErrFormatFailed{Output: buf.Bytes(), Err: err})
 	}
 	var bts []byte
 	if opts.NoGoImports {
 		bts = b.Bytes()
 	} else if bts, err = imports.Process("dummy.go", b.Bytes(), nil); err != nil {
-		return errors.New("Error fixing imports: " + err.Error())
+		return nil, diagf(InvalidCode, "", "add the imports to Extra, or set NoGoImports.", "Error fixing imports: %s", err.Error()).
+			wrap(fmt.Errorf("%w: %v", ErrImportsFailed, err))
 	}
-	_, err = out.Write(bts)
-	return err
+	if opts.MaxLineLen > 0 {
+		bts = wrapSignatures(bts, opts.MaxLineLen)
+	}
+	return bts, nil
 }
 
 func (opts *GenOpts) handleExisting() error {
@@ -79,10 +293,10 @@ func (opts *GenOpts) handleExisting() error {
 		return nil
 	}
 	if opts.ImplName != "" {
-		return errors.New("only one of ImplName and existing should be set.")
+		return diagf(InvalidOptions, "", "", "only one of ImplName and existing should be set.")
 	}
 	if opts.PkgName != "" {
-		return errors.New("only one of PkgName and existing should be set.")
+		return diagf(InvalidOptions, "", "", "only one of PkgName and existing should be set.")
 	}
 	et := reflect.TypeOf(opts.Existing)
 
@@ -110,6 +324,25 @@ func (opts *GenOpts) handleExisting() error {
 			opts.MethodBlacklist[k] = struct{}{}
 		}
 	}
+	if err := opts.checkFields(et, mtds); err != nil {
+		return err
+	}
+	if err := opts.checkReceivers(et, mtds); err != nil {
+		return err
+	}
+	var missing []reflect.Method
+	for _, m := range mtds {
+		if _, ok := eMap[m.Name]; !ok {
+			missing = append(missing, m.Method)
+		}
+	}
+	if opts.Delegate {
+		opts.delegated = opts.delegates(et, missing)
+	}
+	opts.calls = funcCalls(et, missing)
+	if opts.Getters {
+		opts.fields = getters(et, missing)
+	}
 	if et.Kind() == reflect.Ptr {
 		var name string
 		opts.PkgName, name = packageAndName(et.Elem())
@@ -123,16 +356,92 @@ func (opts *GenOpts) handleExisting() error {
 // Arg describes an argument of a method: either in or out.
 type Arg struct {
 	reflect.Type
-	ArgName string // Name for a variable for this arg.
-	Sep     string // Separator - empty if it the last arg in a list, comma otherwise.
+	ArgName string     // Name for a variable for this arg.
+	Sep     string     // Separator - empty if it the last arg in a list, comma otherwise.
+	T       types.Type // Set instead of Type if the interface is described by go/types.
+	// Variadic is set on the last input of a variadic method: the type (a slice) is written as ...T.
+	Variadic bool
+	declared string // Type as declared (with Aliases), if it uses aliases.
 }
 
 // Method.
 type Method struct {
 	reflect.Method
-	Inputs  []Arg
-	Outputs []Arg
-	Comment string
+	Inputs    []Arg
+	Outputs   []Arg
+	Comment   string
+	Delegate  string // Field the method is forwarded to (see GenOpts.Delegate and Forward).
+	Field     string // Field the method returns (see GenOpts.Getters).
+	Adapted   bool   // The method calls the method of GenOpts.Adapt, with AdaptArgs.
+	AdaptArgs string // Arguments of the call to the method of GenOpts.Adapt.
+	Calls     bool   // The method calls the receiver, a func type (see GenOpts.FuncType).
+	// NamedResults is set if the results are named in the declaration (see GenOpts.NoNamedReturnValues and SmartReturnValues).
+	NamedResults bool
+	Variadic     bool // The last input is variadic.
+}
+
+// CallArgs returns the arguments to forward the call to a method with the same signature:
+// the names of the inputs, followed by ... if the method is variadic.
+func (m Method) CallArgs() string {
+	args := make([]string, len(m.Inputs))
+	for i, a := range m.Inputs {
+		args[i] = a.ArgName
+	}
+	s := strings.Join(args, ", ")
+	if m.Variadic {
+		s += "..."
+	}
+	return s
+}
+
+// HasContext reports whether the first input is a context.Context.
+func (m Method) HasContext() bool {
+	if len(m.Inputs) == 0 {
+		return false
+	}
+	if t := m.Inputs[0].T; t != nil {
+		return t.String() == "context.Context"
+	}
+	return m.Inputs[0].Type == contextType
+}
+
+// Annotations in the comments of the interface methods, overriding ReadOnly.
+const (
+	AnnotationRead  = "goimpl:read"
+	AnnotationWrite = "goimpl:write"
+)
+
+// readPrefixes are the prefixes of the names of the methods guessed to be read-only.
+var readPrefixes = []string{"Get", "List", "Len", "Size", "Count", "Has", "Is", "Contains", "Exists", "Find", "Lookup", "Peek", "Keys", "Values", "String"}
+
+// ReadOnly reports whether the method (probably) does not modify the receiver: its comment has AnnotationRead,
+// or it has no AnnotationWrite and its name is a read prefix (Get, List, Has, Is...) followed by a new word, if anything.
+func (m Method) ReadOnly() bool {
+	if strings.Contains(m.Comment, AnnotationRead) {
+		return true
+	}
+	if strings.Contains(m.Comment, AnnotationWrite) {
+		return false
+	}
+	for _, p := range readPrefixes {
+		if rest := strings.TrimPrefix(m.Name, p); rest != m.Name && (rest == "" || !unicode.IsLower([]rune(rest)[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnsError reports whether the last output of the method is an error.
+func (opts *GenOpts) ReturnsError(m Method) bool {
+	return len(m.Outputs) > 0 && opts.isError(m.Outputs[len(m.Outputs)-1])
+}
+
+// Results returns the outputs of the method but the trailing error.
+func (opts *GenOpts) Results(m Method) []Arg {
+	if opts.ReturnsError(m) {
+		return m.Outputs[:len(m.Outputs)-1]
+	}
+	return m.Outputs
 }
 
 func toMap(m []Method) map[string]*Method {
@@ -173,9 +482,13 @@ func diff(n string, a, b []Arg) string {
 }
 
 // Methods populates a list of methods for a given reflect type (which is supposed to be an interface).
+// If the interface is described by go/types (see GenerateFromTypes), it is nil.
 func (opts *GenOpts) Methods(it reflect.Type) []Method {
+	if it == nil && opts.types != nil {
+		return opts.typesMethods()
+	}
 	m := make([]Method, 0, it.NumMethod())
-	rec := opts.First(opts.ImplName)
+	rec := opts.Receiver()
 	for i := 0; i < it.NumMethod(); i++ {
 		name := it.Method(i).Name
 		if _, ok := opts.MethodBlacklist[name]; !ok {
@@ -183,16 +496,63 @@ func (opts *GenOpts) Methods(it reflect.Type) []Method {
 			if c, ok := opts.Comments[name]; ok {
 				mtd.Comment = c
 			}
+			mtd.Delegate = opts.delegated[name]
+			if opts.Forward {
+				mtd.Delegate = "next"
+			}
+			mtd.Field = opts.fields[name]
+			if args, ok := opts.adapted[name]; ok {
+				mtd.Adapted, mtd.AdaptArgs = true, args
+			}
+			mtd.Calls = opts.calls[name]
+			mtd.NamedResults = opts.namedResults(mtd.Outputs)
+			opts.blankArgs(&mtd)
+			opts.lintComment(&mtd)
 			m = append(m, mtd)
 		}
 	}
 	return m
 }
 
+// namedResults reports whether the results (outputs) are named: unless NoNamedReturnValues is set or, with
+// SmartReturnValues, if they all have different types.
+func (opts *GenOpts) namedResults(outputs []Arg) bool {
+	if !opts.SmartReturnValues {
+		return !opts.NoNamedReturnValues
+	}
+	seen := map[string]bool{}
+	for _, a := range outputs {
+		t := opts.GetName(a)
+		if seen[t] {
+			return true
+		}
+		seen[t] = true
+	}
+	return false
+}
+
+// blankArgs names the inputs of the method _ with BlankArgs, unless its body uses them.
+func (opts *GenOpts) blankArgs(m *Method) {
+	if !opts.BlankArgs || opts.ArgValues || !opts.stub(*m) || opts.BodyGenerator != nil {
+		return
+	}
+	for i := range m.Inputs {
+		m.Inputs[i].ArgName = "_"
+	}
+}
+
 // Method populates the Method struct.
 // recName is a name of the receiver in the generated code.
 func (opts *GenOpts) Method(recName string, ft reflect.Method) Method {
 	cur := map[string]struct{}{recName: struct{}{}} // Current names. Start with the name of receiver.
+	var declared declaredMethod
+	reserved := opts.reservedIdents(ft.Type)
+	if opts.decl != nil {
+		// The declaration is ignored if it does not match (e.g. the source is stale).
+		if dm := opts.decl.methods[ft.Name]; len(dm.paramTypes) == ft.Type.NumIn() && len(dm.resultTypes) == ft.Type.NumOut() {
+			declared = dm
+		}
+	}
 	inp := make([]Arg, ft.Type.NumIn())
 	last := len(inp) - 1
 	for i := range inp {
@@ -201,7 +561,14 @@ func (opts *GenOpts) Method(recName string, ft reflect.Method) Method {
 		if i == last {
 			sep = ""
 		}
-		inp[i] = Arg{Type: t, ArgName: opts.Short(t, cur), Sep: sep}
+		name := opts.declaredName(declared.params, i, reserved, cur)
+		if name == "" {
+			name = opts.short(opts.argType(t), cur, reserved)
+		}
+		inp[i] = Arg{Type: t, ArgName: name, Sep: sep, Variadic: i == last && ft.Type.IsVariadic()}
+		if opts.Aliases && declared.paramTypes != nil {
+			inp[i].declared, _ = opts.aliasType(declared.paramTypes[i], t)
+		}
 	}
 	out := make([]Arg, ft.Type.NumOut())
 	last = len(out) - 1
@@ -211,13 +578,87 @@ func (opts *GenOpts) Method(recName string, ft reflect.Method) Method {
 		if i == last {
 			sep = ""
 		}
-		out[i] = Arg{Type: t, ArgName: opts.Short(t, cur), Sep: sep}
+		name := opts.declaredName(declared.results, i, reserved, cur)
+		if name == "" {
+			name = opts.short(opts.argType(t), cur, reserved)
+		}
+		out[i] = Arg{Type: t, ArgName: name, Sep: sep}
+		if opts.Aliases && declared.resultTypes != nil {
+			out[i].declared, _ = opts.aliasType(declared.resultTypes[i], t)
+		}
 	}
-	return Method{Inputs: inp, Outputs: out, Method: ft}
+	return Method{Inputs: inp, Outputs: out, Method: ft, Variadic: ft.Type.IsVariadic()}
+}
+
+// qualifiedRe matches the types qualified by the import path in the names of the instantiated generic types.
+var qualifiedRe = regexp.MustCompile(`([\pL\pN_.~/-]+)\.([\pL_][\pL\pN_]*)`)
+
+// typeArgs rewrites the type arguments of an instantiated generic type, e.g. [example.com/users.User],
+// with the package names as qualifiers: [users.User] (or [User] in the package we are generating code for).
+func (opts *GenOpts) typeArgs(args string) string {
+	return qualifiedRe.ReplaceAllStringFunc(args, func(s string) string {
+		m := qualifiedRe.FindStringSubmatch(s)
+		pkgName := importPathName(m[1])
+		if pkgName == opts.PkgName {
+			return m[2]
+		}
+		return opts.qualifier(pkgName, m[1]) + "." + m[2]
+	})
+}
+
+// importPathName returns the name a package is usually declared with: the last element of its import path,
+// without the major version (example.com/mod/v2, gopkg.in/yaml.v3).
+func importPathName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 && strings.HasPrefix(path, "gopkg.in/") {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(name, "-", "_")
 }
 
-// Clean keeps only letters.
+// signature returns the parameters and the results of the func type, as in a declaration: (T1, T2) (R1, R2).
+func (opts *GenOpts) signature(t reflect.Type) string {
+	inputs := make([]string, t.NumIn())
+	for i := range inputs {
+		inputs[i] = opts.GetName(t.In(i))
+	}
+	if t.IsVariadic() {
+		inputs[len(inputs)-1] = "..." + opts.GetName(t.In(len(inputs)-1).Elem())
+	}
+	outputs := make([]string, t.NumOut())
+	for i := range outputs {
+		outputs[i] = opts.GetName(t.Out(i))
+	}
+	out := strings.Join(outputs, ", ")
+	if len(outputs) > 1 {
+		out = fmt.Sprintf("(%s)", out)
+	}
+	return fmt.Sprintf("(%s) %s", strings.Join(inputs, ", "), out)
+}
+
+// quoteTag quotes the struct tag as it is usually written: a raw string, unless it has a backquote.
+func quoteTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
+// Clean returns the name of the type s: without the * and the type arguments (*store[K] is store).
 func (opts *GenOpts) Clean(s string) string {
+	s = strings.TrimLeft(strings.TrimSpace(s), "*")
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// clean keeps only letters.
+func clean(s string) string {
 	rs := []rune(s)
 	res := make([]rune, 0, len(rs))
 	for _, r := range rs {
@@ -230,30 +671,39 @@ func (opts *GenOpts) Clean(s string) string {
 }
 
 // Short returns a unique (in the current scope) name for the argument of type t.
+// It does not shadow the predeclared identifiers.
 func (opts *GenOpts) Short(t reflect.Type, cur map[string]struct{}) string {
+	return opts.short(opts.argType(t), cur, nil)
+}
+
+// argType describes t for naming.
+func (opts *GenOpts) argType(t reflect.Type) ArgType {
 	tt := t
 	for tt.Kind() == reflect.Ptr || tt.Kind() == reflect.Slice {
 		tt = tt.Elem()
 	}
 	pkg, name := packageAndName(tt)
-	f := opts.First(name) // First letter.
-	// Handle common types.
-	switch {
-	case t.ConvertibleTo(errorType):
-		f = "err"
-	case t.ConvertibleTo(ctxType):
-		f = "ctx"
-	default:
-		n, clean := opts.lowerName(name)
-		// Very short names.
-		if len(n) <= 3 && string(n) != clean && string(n) != pkg {
-			f = string(n)
-		}
+	if tt.Name() == "" && (tt.Kind() == reflect.Struct || tt.Kind() == reflect.Interface) {
+		pkg, name = "", tt.Kind().String() // Not the names in the literal.
+	}
+	return ArgType{Package: pkg, PkgPath: tt.PkgPath(), Name: name, Error: t.ConvertibleTo(errorType), Context: t.ConvertibleTo(ctxType), Type: t}
+}
+
+// short returns a unique name for an argument of type t, named by opts.Naming.
+// The name is neither reserved nor a predeclared identifier (len, string), so it does not shadow what the signature
+// or the body refer to.
+func (opts *GenOpts) short(t ArgType, cur map[string]struct{}, reserved map[string]bool) string {
+	f := opts.argName(t)
+	if f == "" && opts.Naming != nil {
+		f = opts.Naming.ArgName(t)
+	}
+	if !token.IsIdentifier(f) || f == "_" {
+		f = ShortNames{}.ArgName(t)
 	}
 	// Make sure the name is unique.
-	name = f
+	name := f
 	for c := 1; ; c++ {
-		if _, ok := cur[name]; !ok {
+		if _, ok := cur[name]; !ok && !reserved[name] && types.Universe.Lookup(name) == nil {
 			// Update the set of currently used names.
 			cur[name] = struct{}{}
 			return name
@@ -262,12 +712,12 @@ func (opts *GenOpts) Short(t reflect.Type, cur map[string]struct{}) string {
 	}
 }
 
-func (opts *GenOpts) lowerName(s string) ([]rune, string) {
+func lowerName(s string) ([]rune, string) {
 	parts := strings.Split(s, ".")
 	if len(parts) == 0 {
 		return []rune("u"), ""
 	}
-	clean := opts.Clean(parts[len(parts)-1])
+	clean := clean(parts[len(parts)-1])
 	rs := []rune(clean)
 	for i, r := range rs {
 		rs[i] = unicode.ToLower(r)
@@ -275,6 +725,36 @@ func (opts *GenOpts) lowerName(s string) ([]rune, string) {
 	return rs, clean
 }
 
+// Receiver returns the name of the receiver of the generated methods: ReceiverName, or the first letter of ImplName
+// (numbered if it is one of the Locals).
+func (opts *GenOpts) Receiver() string {
+	if opts.ReceiverName != "" {
+		return opts.ReceiverName
+	}
+	// The bodies would shadow a receiver named after one of the Locals.
+	locals := map[string]bool{}
+	for _, id := range opts.Locals {
+		locals[id] = true
+	}
+	first := opts.First(opts.ImplName)
+	rec := first
+	for c := 1; locals[rec]; c++ {
+		rec = fmt.Sprintf("%s%d", first, c)
+	}
+	return rec
+}
+
+// Constructor returns the name of a constructor of the generated type: NewImplName, or newImplName if it is unexported.
+func (opts *GenOpts) Constructor() string {
+	return constructorName(opts.Clean(opts.ImplName))
+}
+
+// Prefixed returns the name of the generated type with the prefix, for the declarations accompanying it:
+// e.g. ErrImplName, or errImplName if the type is unexported.
+func (opts *GenOpts) Prefixed(prefix string) string {
+	return prefixed(prefix, opts.Clean(opts.ImplName))
+}
+
 // First returns a first letter of s in lowercase.
 func (GenOpts) First(s string) string {
 	parts := strings.Split(s, ".")
@@ -291,14 +771,30 @@ func (GenOpts) First(s string) string {
 
 // GetName of a type.
 func (opts *GenOpts) GetName(t reflect.Type) string {
+	if a, ok := t.(Arg); ok && a.declared != "" {
+		return a.declared
+	}
+	if a, ok := t.(Arg); ok && a.Variadic {
+		if s, ok := a.T.(*types.Slice); ok {
+			return "..." + opts.typeString(s.Elem())
+		}
+		return "..." + opts.GetName(a.Type.Elem())
+	}
+	if a, ok := t.(Arg); ok && a.T != nil {
+		return opts.typeString(a.T)
+	}
 	name := t.Name()
 	if name != "" {
 		pkg, _ := packageAndName(t)
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			// An instantiated generic type: the type arguments are qualified by the import paths.
+			name = name[:i] + opts.typeArgs(name[i:])
+		}
 		// Handle the case the type is in the package we are generating code for.
 		if pkg == "" || pkg == opts.PkgName {
 			return name
 		}
-		return fmt.Sprintf("%s.%s", pkg, name)
+		return fmt.Sprintf("%s.%s", opts.qualifier(pkg, t.PkgPath()), name)
 	}
 	switch t.Kind() {
 	case reflect.Ptr:
@@ -311,20 +807,36 @@ func (opts *GenOpts) GetName(t reflect.Type) string {
 		return fmt.Sprintf("%s %s", t.ChanDir().String(), opts.GetName(t.Elem()))
 	case reflect.Array:
 		return fmt.Sprintf("[%d]%s", t.Len(), opts.GetName(t.Elem()))
-	case reflect.Func:
-		inputs := make([]string, t.NumIn())
-		for i := range inputs {
-			inputs[i] = opts.GetName(t.In(i))
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			if opts.UseAny {
+				return "any"
+			}
+			return "interface{}"
 		}
-		outputs := make([]string, t.NumOut())
-		for i := range outputs {
-			outputs[i] = opts.GetName(t.Out(i))
+		methods := make([]string, t.NumMethod())
+		for i := range methods {
+			methods[i] = t.Method(i).Name + opts.signature(t.Method(i).Type)
 		}
-		out := strings.Join(outputs, ", ")
-		if len(outputs) > 1 {
-			out = fmt.Sprintf("(%s)", out)
+		return fmt.Sprintf("interface { %s }", strings.Join(methods, "; "))
+	case reflect.Struct:
+		if t.NumField() == 0 {
+			return "struct{}"
+		}
+		fields := make([]string, t.NumField())
+		for i := range fields {
+			f := t.Field(i)
+			fields[i] = opts.GetName(f.Type)
+			if !f.Anonymous {
+				fields[i] = f.Name + " " + fields[i]
+			}
+			if f.Tag != "" {
+				fields[i] += " " + quoteTag(string(f.Tag))
+			}
 		}
-		return fmt.Sprintf("func (%s) %s", strings.Join(inputs, ", "), out)
+		return fmt.Sprintf("struct { %s }", strings.Join(fields, "; "))
+	case reflect.Func:
+		return "func " + opts.signature(t)
 	default:
 		return t.String()
 	}
@@ -335,7 +847,11 @@ func packageAndName(t reflect.Type) (pkgName, name string) {
 	if pkgPath == "" {
 		return "", t.String()
 	}
-	parts := strings.Split(t.String(), ".")
+	s := t.String()
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i] // The type arguments of an instantiated generic type.
+	}
+	parts := strings.Split(s, ".")
 	if len(parts) == 0 {
 		return
 	}
@@ -348,22 +864,25 @@ func packageAndName(t reflect.Type) (pkgName, name string) {
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
 var ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
 
-const templateS = `
+// DefaultTemplate is the template used to generate the code when GenOpts.Template is not set.
+// The template is executed with *GenOpts as data.
+const DefaultTemplate = `
 {{$R := .}}
 package {{.PkgName}}
 
 import (
-	"errors"
-	{{range .Extra}}"{{.}}"
+	{{range .BodyImports}}"{{.}}"
+	{{end}}{{range .Extra}}"{{.}}"
+	{{end}}{{range .MappedImports}}{{.Name}} "{{.Path}}"
 	{{end}})
-type {{.Clean .ImplName}} struct{}
+{{.TypeComment}}type {{.Clean .ImplName}}{{.TypeParams}} {{.TypeDecl}}
 
-{{$rec := .First .ImplName}}
-{{range $R.Methods .Inter}}
+{{$rec := .Receiver}}
+{{range $R.Methods .Inter}}{{$named := .NamedResults}}
 {{if .Comment}}// {{ .Comment}} {{end}}
-func ({{$rec}} {{$R.ImplName}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{if not $R.NoNamedReturnValues}} {{.ArgName}} {{end}} {{$R.GetName .}} {{.Sep}} {{end}}) {
-	panic(errors.New("{{$R.ImplName}}.{{.Name}} not implemented")) }
-{{end}}
+func ({{$rec}} {{$R.ImplName}}{{$R.TypeArgs}}) {{.Name}} ({{range .Inputs}} {{.ArgName}} {{$R.GetName .}} {{.Sep}} {{end}}) ({{range .Outputs}} {{if $named}} {{.ArgName}} {{end}} {{$R.GetName .}} {{.Sep}} {{end}}) {
+	{{$R.Body $rec .}} }
+{{end}}{{.Sentinel}}
 `
 
 var _ = `{{if $R.NoNamedReturnValues}} {{range .Inputs}} _ {{if eq .Sep ""}} = {{else}} {{.Sep}} {{end}} {{end}} {{range .Inputs}} {{.ArgName}} {{.Sep}} {{end}}
@@ -371,7 +890,7 @@ var _ = `{{if $R.NoNamedReturnValues}} {{range .Inputs}} _ {{if eq .Sep ""}} = {
 var tm = template.New("impl")
 
 func init() {
-	_, err := tm.Parse(templateS)
+	_, err := tm.Parse(DefaultTemplate)
 	if err != nil {
 		panic(err)
 	}
@@ -2,13 +2,22 @@ package goimpl
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
+	"net/http"
 	"net/rpc"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
@@ -71,6 +80,323 @@ func (a AlmostClientCodec) WriteRequest(r *rpc.Request, i interface{}) (err erro
 }
 `, "'", "`", -1),
 		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "Impl",
+				Inter:               reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				Fragment:            true,
+				Comments:            map[string]string{"String": "Returns the name."},
+			},
+			expected: `// Returns the name.
+func (i Impl) String() string {
+	panic(errors.New("Impl.String not implemented"))
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				Existing:            &PartialReadWriteCloser{},
+				Inter:               reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				Delegate:            true,
+			},
+			expected: `package goimpl
+
+import (
+	"errors"
+)
+
+type PartialReadWriteCloser struct{}
+
+func (p *PartialReadWriteCloser) Close() error {
+	panic(errors.New("*PartialReadWriteCloser.Close not implemented"))
+}
+
+func (p *PartialReadWriteCloser) Write(u []uint8) (int, error) {
+	return p.log.Write(u)
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "*Impl",
+				Inter:               reflect.TypeOf((*Cache)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				Unimplemented:       UnimplementedError,
+				UnimplementedFor:    map[string]string{"Close": UnimplementedZero, "Len": UnimplementedLog},
+			},
+			expected: `package pkg
+
+import (
+	"errors"
+	"github.com/sasha-s/goimpl"
+	"log"
+)
+
+type Impl struct{}
+
+func (i *Impl) Close() {
+}
+
+func (i *Impl) Get(s string) (goimpl.Entry, error) {
+	return goimpl.Entry{}, errors.New("*Impl.Get not implemented")
+}
+
+func (i *Impl) Len() int {
+	log.Println("*Impl.Len not implemented")
+	return 0
+}
+
+func (i *Impl) Tags() ([]string, bool) {
+	panic(errors.New("*Impl.Tags not implemented"))
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "*Impl",
+				Inter:               reflect.TypeOf((*Cache)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				Unimplemented:       UnimplementedError,
+				UnimplementedFor:    map[string]string{"Close": UnimplementedZero, "Len": UnimplementedLog},
+				CallerLocation:      true,
+			},
+			expected: `package pkg
+
+import (
+	"fmt"
+	"github.com/sasha-s/goimpl"
+	"log"
+	"runtime"
+)
+
+type Impl struct{}
+
+func (i *Impl) Close() {
+}
+
+func (i *Impl) Get(s string) (goimpl.Entry, error) {
+	_, callerFile, callerLine, _ := runtime.Caller(1)
+	return goimpl.Entry{}, fmt.Errorf("*Impl.Get not implemented (called from %s:%d)", callerFile, callerLine)
+}
+
+func (i *Impl) Len() int {
+	_, callerFile, callerLine, _ := runtime.Caller(1)
+	log.Printf("*Impl.Len not implemented (called from %s:%d)", callerFile, callerLine)
+	return 0
+}
+
+func (i *Impl) Tags() ([]string, bool) {
+	_, callerFile, callerLine, _ := runtime.Caller(1)
+	panic(fmt.Errorf("*Impl.Tags not implemented (called from %s:%d)", callerFile, callerLine))
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				Existing:         &PartialReadWriteCloser{},
+				Inter:            reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem(),
+				NoGoImports:      true,
+				Delegate:         true,
+				WrapErrors:       true,
+				StructuredErrors: true,
+			},
+			expected: `package goimpl
+
+import (
+	"fmt"
+	"github.com/sasha-s/goimpl/notimpl"
+)
+
+type PartialReadWriteCloser struct{}
+
+func (p *PartialReadWriteCloser) Close() (err error) {
+	panic(&notimpl.Error{Interface: "io.ReadWriteCloser", Type: "*PartialReadWriteCloser", Method: "Close"})
+}
+
+func (p *PartialReadWriteCloser) Write(u []uint8) (i int, err error) {
+	i, err = p.log.Write(u)
+	if err != nil {
+		err = fmt.Errorf("*PartialReadWriteCloser.Write: %w", err)
+	}
+	return i, err
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "Codec",
+				Inter:               reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				ImportMap:           map[string]string{"net/rpc": "rpc2 example.com/fork/rpc"},
+				MethodBlacklist:     map[string]struct{}{"Close": {}, "ReadResponseBody": {}},
+			},
+			expected: `package pkg
+
+import (
+	"errors"
+	rpc2 "example.com/fork/rpc"
+)
+
+type Codec struct{}
+
+func (c Codec) ReadResponseHeader(r *rpc2.Response) error {
+	panic(errors.New("Codec.ReadResponseHeader not implemented"))
+}
+
+func (c Codec) WriteRequest(r *rpc2.Request, i interface{}) error {
+	panic(errors.New("Codec.WriteRequest not implemented"))
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "Codec",
+				Inter:               reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				ImportMap:           map[string]string{"net/rpc": "example.com/fork/fk"},
+				MethodBlacklist:     map[string]struct{}{"Close": {}, "ReadResponseBody": {}},
+			},
+			expected: `package pkg
+
+import (
+	"errors"
+	rpc "example.com/fork/fk"
+)
+
+type Codec struct{}
+
+func (c Codec) ReadResponseHeader(r *rpc.Response) error {
+	panic(errors.New("Codec.ReadResponseHeader not implemented"))
+}
+
+func (c Codec) WriteRequest(r *rpc.Request, i interface{}) error {
+	panic(errors.New("Codec.WriteRequest not implemented"))
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "WriterFunc",
+				Inter:               reflect.TypeOf((*io.Writer)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				FuncType:            true,
+			},
+			expected: `package pkg
+
+import ()
+
+type WriterFunc func([]uint8) (int, error)
+
+func (w WriterFunc) Write(u []uint8) (int, error) {
+	return w(u)
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				Existing:            WriterFunc(nil),
+				Inter:               reflect.TypeOf((*io.WriteCloser)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+			},
+			expected: `package goimpl
+
+import (
+	"errors"
+)
+
+type WriterFunc func([]uint8) (int, error)
+
+func (w WriterFunc) Close() error {
+	panic(errors.New("WriterFunc.Close not implemented"))
+}
+
+func (w WriterFunc) Write(u []uint8) (int, error) {
+	return w(u)
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				Existing:            &file{},
+				Inter:               reflect.TypeOf((*Named)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				Getters:             true,
+			},
+			expected: `package goimpl
+
+import (
+	"errors"
+)
+
+type file struct{}
+
+func (f *file) GetSize() int64 {
+	return f.Size
+}
+
+func (f *file) Mode() int {
+	panic(errors.New("*file.Mode not implemented"))
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:  "pkg",
+				ImplName: "*ReaderFunc",
+				Inter:    reflect.TypeOf((*io.ReadCloser)(nil)).Elem(),
+				FuncType: true,
+			},
+			shouldError: true,
+		},
+		{
+			opts: GenOpts{
+				PkgName:             "pkg",
+				ImplName:            "fakeStringer",
+				Inter:               reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+				NoNamedReturnValues: true,
+				NoGoImports:         true,
+				Underlying:          "map[string]string",
+				Unimplemented:       UnimplementedZero,
+			},
+			expected: `package pkg
+
+import ()
+
+type fakeStringer map[string]string
+
+func (f fakeStringer) String() string {
+	return ""
+}
+`,
+		},
+		{
+			opts: GenOpts{
+				PkgName:    "pkg",
+				ImplName:   "fakeStringer",
+				Inter:      reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+				Underlying: "*int",
+			},
+			shouldError: true,
+		},
 		{
 			opts: GenOpts{
 				PkgName:  "pkg",
@@ -116,3 +442,770 @@ func (a AlmostClientCodec) ReadResponseHeader(*rpc.Response) error {
 func (a AlmostClientCodec) WriteRequest(interface{}, *rpc.Request) error {
 	panic(errors.New("AlmostClientCodec.WriteReque not implemented"))
 }
+
+// Read is promoted, Write is delegated to log.
+type PartialReadWriteCloser struct {
+	io.Reader
+	name string
+	log  *bytes.Buffer
+}
+
+// Write calls the func, Close is generated.
+type WriterFunc func(p []byte) (int, error)
+
+type Named interface {
+	Name() string
+	GetSize() int64
+	Mode() int
+}
+
+// Name and GetSize return the fields, Mode is generated (the field has another type).
+type file struct {
+	name string
+	Size int64
+	mode uint32
+}
+
+type Entry struct{}
+
+type Cache interface {
+	Get(key string) (Entry, error)
+	Len() int
+	Tags() ([]string, bool)
+	Close()
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestGenerateBytes(t *testing.T) {
+	var a, b bytes.Buffer
+	opts := GenOpts{PkgName: "pkg", ImplName: "Impl", Inter: reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), NoGoImports: true}
+	bts, err := GenerateBytes(&opts, &a, failingWriter{}, &b)
+	if err == nil || err.Error() != "failed" {
+		t.Errorf("expected the error from the failing writer, got %v", err)
+	}
+	if len(bts) == 0 || a.String() != string(bts) || b.String() != string(bts) {
+		t.Errorf("expected the code in all the writers. Got:\n%s\n-----\n%s\n-----\n%s", bts, a.String(), b.String())
+	}
+	a.Reset()
+	opts = GenOpts{PkgName: "pkg", ImplName: "-bad name", Inter: reflect.TypeOf((*fmt.Stringer)(nil)).Elem()}
+	if _, err := GenerateBytes(&opts, &a); err == nil || a.Len() != 0 {
+		t.Errorf("expected an error and nothing written, got %v and:\n%s", err, a.String())
+	}
+}
+
+func TestGenerateParts(t *testing.T) {
+	opts := GenOpts{
+		PkgName:  "pkg",
+		ImplName: "*Impl",
+		Inter:    reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(),
+		Comments: map[string]string{"Close": "Closes the codec."},
+	}
+	p, err := GenerateParts(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := []Import{{Path: "errors"}, {Path: "net/rpc"}}
+	if !reflect.DeepEqual(p.Imports, imports) || p.Package != "pkg" || p.Type != "type Impl struct{}" {
+		t.Errorf("unexpected parts: %#v", p)
+	}
+	if !strings.HasPrefix(p.Methods, "// Closes the codec.\nfunc (i *Impl) Close() (err error) {") ||
+		!strings.HasSuffix(p.Methods, "\tpanic(errors.New(\"*Impl.WriteRequest not implemented\"))\n}\n") {
+		t.Errorf("unexpected methods:\n%s", p.Methods)
+	}
+}
+
+func TestOnPhase(t *testing.T) {
+	var phases []string
+	opts := GenOpts{PkgName: "pkg", ImplName: "Impl", Inter: reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), NoGoImports: true,
+		OnPhase: func(phase string, d time.Duration) { phases = append(phases, phase) }}
+	if _, err := GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{PhaseLoad, PhaseRender, PhaseFormat}; !reflect.DeepEqual(phases, want) {
+		t.Errorf("expected phases %v, got %v", want, phases)
+	}
+}
+
+func TestGenerateFiles(t *testing.T) {
+	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	o := Overlay{}
+	err := GenerateFiles(o, map[string]*GenOpts{
+		"a/impl.go": {PkgName: "a", ImplName: "Impl", Inter: stringer, NoGoImports: true},
+		"b/impl.go": {PkgName: "b", ImplName: "Impl", Inter: stringer, NoGoImports: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 2 || !bytes.HasPrefix(o["a/impl.go"], []byte("package a\n")) || !bytes.HasPrefix(o["b/impl.go"], []byte("package b\n")) {
+		t.Errorf("unexpected files: %q", o)
+	}
+	o = Overlay{}
+	err = GenerateFiles(o, map[string]*GenOpts{
+		"a/impl.go": {PkgName: "a", ImplName: "Impl", Inter: stringer, NoGoImports: true},
+		"b/impl.go": {PkgName: "b", ImplName: "-bad name", Inter: stringer},
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "b/impl.go: ") || len(o) != 0 {
+		t.Errorf("expected an error for b/impl.go and nothing written, got %v and %q", err, o)
+	}
+}
+
+type Logger interface {
+	Logf(format string, args ...interface{})
+	Handle(f func(...string) error)
+}
+
+func TestVariadic(t *testing.T) {
+	expected := `package pkg
+
+import (
+	"errors"
+)
+
+type Impl struct{}
+
+func (i *Impl) Handle(s func(...string) error) {
+	panic(errors.New("*Impl.Handle not implemented"))
+}
+
+func (i *Impl) Logf(s string, i1 ...interface{}) {
+	panic(errors.New("*Impl.Logf not implemented"))
+}
+`
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Logger)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "reflect", string(b), expected)
+
+	pkg := typeCheck(t, "example.com/log", "package log\n\ntype Logger interface {\n\tLogf(format string, args ...interface{})\n}\n")
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", NoGoImports: true, ParamNames: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Logger"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Logf(format string, args ...interface{}) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+func TestUseAny(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true, UseAny: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) WriteRequest(r *rpc.Request, i1 any) (err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\ntype KV interface {\n\tGet(key string) (map[string]interface{}, error)\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, UseAny: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Get(s string) (m map[string]any, err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+func TestBlankArgs(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true, BlankArgs: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) WriteRequest(_ *rpc.Request, _ interface{}) (err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\ntype KV interface {\n\tGet(key string) ([]byte, error)\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, BlankArgs: true, ParamNames: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Get(_ string) (b []byte, err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+func TestBodies(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true, Bodies: map[string]string{"Close": "return nil"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func (i *Impl) Close() (err error) {\n\treturn nil\n}\n",
+		"\tpanic(errors.New(\"*Impl.WriteRequest not implemented\"))\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+
+	opts.Bodies = map[string]string{"Close": "return nil }"}
+	_, err = GenerateBytes(&opts)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("invalid body: got %v, expected an InvalidOptions diagnostic", err)
+	}
+}
+
+func TestBodyGenerator(t *testing.T) {
+	gen := BodyFunc(func(m Method) (string, []string) {
+		if len(m.Outputs) == 0 {
+			return "dispatch.Call(" + strconv.Quote(m.Name) + ")", []string{"example.com/dispatch"}
+		}
+		return "return dispatch.Err(" + strconv.Quote(m.Name) + ")", []string{"example.com/dispatch"}
+	})
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true,
+		BodyGenerator: gen, Bodies: map[string]string{"Close": "return nil"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"import (\n\t\"example.com/dispatch\"\n\t\"net/rpc\"\n)\n",
+		"func (i *Impl) Close() (err error) {\n\treturn nil\n}\n",
+		"\treturn dispatch.Err(\"WriteRequest\")\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+}
+
+func TestPanicFormat(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.Reader)(nil)).Elem(), NoGoImports: true,
+		PanicFormat: "{{.Interface}}: {{.Impl}}.{{.Method}} is not implemented yet (TODO: JIRA-123, 100%)"}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `panic(errors.New("io.Reader: *Impl.Read is not implemented yet (TODO: JIRA-123, 100%)"))`; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+
+	opts.CallerLocation = true
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := `fmt.Errorf("io.Reader: *Impl.Read is not implemented yet (TODO: JIRA-123, 100%%) (called from %s:%d)", callerFile, callerLine)`; !strings.Contains(string(b), want) {
+		t.Errorf("caller: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\ntype KV interface {\n\tGet(key string) ([]byte, error)\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, InterName: "kv.KV", PanicFormat: opts.PanicFormat}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := `panic(errors.New("kv.KV: *Impl.Get is not implemented yet (TODO: JIRA-123, 100%)"))`; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+
+	opts.PanicFormat = "{{.Type}}"
+	err = GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions || d.Location != opts.PanicFormat {
+		t.Errorf("invalid format: got %v, expected an InvalidOptions diagnostic", err)
+	}
+}
+
+func TestHelper(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReadCloser)(nil)).Elem(), NoGoImports: true,
+		Helper: "example.com/report/v2.NotImplemented"}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"example.com/report/v2\"\n",
+		"\treturn report.NotImplemented(\"*Impl.Close not implemented\")\n",
+		"\treturn 0, report.NotImplemented(\"*Impl.Read not implemented\")\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\ntype KV interface {\n\tLen() int\n\tReset()\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, Helper: "notImplemented"}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\tnotImplemented(\"*Impl.Len not implemented\")\n\treturn 0\n",
+		"\tnotImplemented(\"*Impl.Reset not implemented\")\n}",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+		}
+	}
+
+	opts.Helper = "example.com/report."
+	err = GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("invalid helper: got %v, expected an InvalidOptions diagnostic", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReadWriter)(nil)).Elem(), NoGoImports: true, Lint: true,
+		Comments: map[string]string{"Write": "is next."}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Impl implements io.ReadWriter.\ntype Impl struct{}\n",
+		"// Read implements io.ReadWriter.\nfunc (i *Impl) Read(_ []uint8) (i1 int, err error) {\n\treturn 0, errors.New(\"*Impl.Read not implemented\")\n}\n",
+		"// Write is next.\nfunc (i *Impl) Write(_ []uint8) (i1 int, err error) {",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+}
+
+func TestForward(t *testing.T) {
+	expected := "package pkg\n\n" + `import (
+	"fmt"
+)
+
+type Impl struct {
+	next fmt.State
+}
+
+func (i *Impl) Flag(c int) (b bool) {
+	return i.next.Flag(c)
+}
+
+func (i *Impl) Precision() (prec int, ok bool) {
+	return i.next.Precision()
+}
+
+func (i *Impl) Width() (wid int, ok bool) {
+	return i.next.Width()
+}
+
+func (i *Impl) Write(b []uint8) (n int, err error) {
+	return i.next.Write(b)
+}
+`
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*fmt.State)(nil)).Elem(), Forward: true, ParamNames: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "forward", string(b), expected)
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\ntype KV interface {\n\tSet(key string, values ...[]byte) error\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, Forward: true, WrapErrors: true}
+	var buf bytes.Buffer
+	err = GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("go/types without InterName: got %v, expected an InvalidOptions diagnostic", err)
+	}
+	opts.InterName = "kv.KV"
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type Impl struct {\n\tnext KV\n}\n",
+		"\terr = i.next.Set(s, b...)\n\tif err != nil {\n\t\terr = fmt.Errorf(\"*Impl.Set: %w\", err)\n\t}\n\treturn err\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+		}
+	}
+}
+
+type Schedule interface {
+	Between(from, to time.Time) (n, m int, err error)
+}
+
+func TestGroupParams(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true, GroupParams: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Between(t, t1 time.Time) (i1, i2 int, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true, GroupParams: true,
+		NoNamedReturnValues: true, BlankArgs: true}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Between(_, _ time.Time) (int, int, error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("unnamed results: expected %q in\n%s", want, b)
+	}
+}
+
+func TestMaxLineLen(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true, MaxLineLen: 40}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Between(\n\tt time.Time,\n\tt1 time.Time,\n) (i1 int, i2 int, err error) {\n"; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true, MaxLineLen: 80}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Between(t time.Time, t1 time.Time) (i1 int, i2 int, err error) {\n"; !strings.Contains(string(b), want) {
+		t.Errorf("short enough: expected %q in\n%s", want, b)
+	}
+}
+
+func TestReceiverName(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", ReceiverName: "s", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (s *Impl) Between(t time.Time, t1 time.Time) (i int, i1 int, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", ReceiverName: "my impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem()}
+	_, err = GenerateBytes(&opts)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("invalid name: got %v, expected an InvalidOptions diagnostic", err)
+	}
+}
+
+func TestDigitsInImplName(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*storeV2", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type storeV2 struct", "func (s *storeV2) Between("} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+	if got := opts.Constructor(); got != "newStoreV2" {
+		t.Errorf("Constructor: got %q, expected newStoreV2", got)
+	}
+}
+
+func TestSmartReturnValues(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true, SmartReturnValues: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) WriteRequest(r *rpc.Request, i1 interface{}) error {"; !strings.Contains(string(b), want) {
+		t.Errorf("different types: expected %q in\n%s", want, b)
+	}
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Schedule)(nil)).Elem(), NoGoImports: true, SmartReturnValues: true,
+		NoNamedReturnValues: true, Unimplemented: UnimplementedZero}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Between(t time.Time, t1 time.Time) (i1 int, i2 int, err error) {\n\treturn 0, 0, nil\n}"; !strings.Contains(string(b), want) {
+		t.Errorf("same types: expected %q in\n%s", want, b)
+	}
+
+	// Compiles with the results named or not, whatever the bodies.
+	for _, mode := range []string{UnimplementedPanic, UnimplementedError, UnimplementedZero, UnimplementedLog, UnimplementedLogFatal, UnimplementedLogError} {
+		for _, smart := range []bool{false, true} {
+			opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(), NoGoImports: true,
+				NoNamedReturnValues: true, SmartReturnValues: smart, Unimplemented: mode, ArgValues: smart}
+			if b, err = GenerateBytes(&opts); err != nil {
+				t.Fatal(err)
+			}
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "impl.go", b, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := types.Config{Importer: importer.Default()}
+			if _, err := conf.Check("pkg", fset, []*ast.File{f}, nil); err != nil {
+				t.Errorf("%s: %v in\n%s", mode, err, b)
+			}
+		}
+	}
+}
+
+type Zeros interface {
+	Get() (time.Time, [2]int, time.Duration, *int, string, bool, struct{ N int }, error)
+}
+
+func TestZeroValues(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Zeros)(nil)).Elem(), NoGoImports: true, Unimplemented: UnimplementedZero}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\treturn time.Time{}, [2]int{}, 0, nil, \"\", false, struct{ N int }{}, nil\n"; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+}
+
+func TestLogModes(t *testing.T) {
+	tc := []struct {
+		mode     string
+		caller   bool
+		expected []string
+	}{
+		{UnimplementedLogFatal, false, []string{
+			"\tlog.Fatalln(\"*Impl.Read not implemented\")\n\treturn 0, nil\n",
+			"\tlog.Fatalln(\"*Impl.Close not implemented\")\n\treturn nil\n",
+		}},
+		{UnimplementedLogError, false, []string{
+			"\"errors\"",
+			"\tlog.Println(\"*Impl.Read not implemented\")\n\treturn 0, errors.New(\"*Impl.Read not implemented\")\n",
+		}},
+		{UnimplementedLogError, true, []string{
+			"\t_, callerFile, callerLine, _ := runtime.Caller(1)\n" +
+				"\tlog.Printf(\"*Impl.Read not implemented (called from %s:%d)\", callerFile, callerLine)\n" +
+				"\treturn 0, fmt.Errorf(\"*Impl.Read not implemented (called from %s:%d)\", callerFile, callerLine)\n",
+		}},
+	}
+	for _, c := range tc {
+		opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReadCloser)(nil)).Elem(), NoGoImports: true,
+			Unimplemented: c.mode, CallerLocation: c.caller}
+		b, err := GenerateBytes(&opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range c.expected {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s (caller %v): expected %q in\n%s", c.mode, c.caller, want, b)
+			}
+		}
+	}
+}
+
+func TestArgValues(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReaderAt)(nil)).Elem(), NoGoImports: true, ArgValues: true, BlankArgs: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\tpanic(fmt.Errorf(\"*Impl.ReadAt not implemented (u=%#v, i1=%#v)\", u, i1))\n"; !strings.Contains(string(b), want) {
+		t.Errorf("expected %q in\n%s", want, b)
+	}
+
+	opts.Unimplemented, opts.CallerLocation = UnimplementedLogError, true
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\tlog.Printf(\"*Impl.ReadAt not implemented (u=%#v, i1=%#v) (called from %s:%d)\", u, i1, callerFile, callerLine)\n",
+		"\treturn 0, fmt.Errorf(\"*Impl.ReadAt not implemented (u=%#v, i1=%#v) (called from %s:%d)\", u, i1, callerFile, callerLine)\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("logerror: expected %q in\n%s", want, b)
+		}
+	}
+
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*io.ReaderAt)(nil)).Elem(), NoGoImports: true, ArgValues: true,
+		Helper: "example.com/report.NotImplemented"}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\treturn 0, report.NotImplemented(fmt.Sprintf(\"*Impl.ReadAt not implemented (u=%#v, i1=%#v)\", u, i1))\n"; !strings.Contains(string(b), want) {
+		t.Errorf("helper: expected %q in\n%s", want, b)
+	}
+}
+
+type Greeter interface {
+	SayHello(ctx context.Context, name string) (string, error)
+	Reset()
+}
+
+func TestGRPC(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Greeter)(nil)).Elem(), NoGoImports: true, Unimplemented: UnimplementedGRPC}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"google.golang.org/grpc/codes\"\n\t\"google.golang.org/grpc/status\"\n",
+		"\treturn \"\", status.Errorf(codes.Unimplemented, \"method SayHello not implemented\")\n",
+		"\tpanic(status.Errorf(codes.Unimplemented, \"method Reset not implemented\"))\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+}
+
+func TestNotImplementedSentinel(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Zeros)(nil)).Elem(), NoGoImports: true, Unimplemented: UnimplementedSentinel}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\"errors\"",
+		"\treturn time.Time{}, [2]int{}, 0, nil, \"\", false, struct{ N int }{}, ErrNotImplemented\n",
+		"var ErrNotImplemented = errors.New(\"not implemented\")\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %q in\n%s", want, b)
+		}
+	}
+
+	opts.Declared = []string{"ErrNotImplemented"}
+	if b, err = GenerateBytes(&opts); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "var ErrNotImplemented") || strings.Contains(string(b), "\"errors\"") {
+		t.Errorf("declared ErrNotImplemented: got\n%s", b)
+	}
+}
+
+type Shapes interface {
+	Move(p struct {
+		X, Y int `xml:",attr"`
+		io.Reader
+	}) error
+	Use(c interface {
+		Foo() (int, error)
+		io.Closer
+	}, v interface{})
+}
+
+func TestLiteralTypes(t *testing.T) {
+	expected := "package pkg\n\n" + `import (
+	"errors"
+	"io"
+)
+
+type Impl struct{}
+
+func (i *Impl) Move(s struct {
+	X int ` + "`xml:\",attr\"`" + `
+	Y int ` + "`xml:\",attr\"`" + `
+	io.Reader
+}) (err error) {
+	panic(errors.New("*Impl.Move not implemented"))
+}
+
+func (i *Impl) Use(i1 interface {
+	Close() error
+	Foo() (int, error)
+}, i2 interface{}) {
+	panic(errors.New("*Impl.Use not implemented"))
+}
+`
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Shapes)(nil)).Elem()}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "literal types", string(b), expected)
+}
+
+type Hook func(err error) error
+
+type Router interface {
+	Handle(pattern string, h http.HandlerFunc, hooks ...Hook) Hook
+}
+
+func TestNamedFuncTypes(t *testing.T) {
+	expected := `func (i *Impl) Handle(s string, h http.HandlerFunc, h1 ...goimpl.Hook) (h2 goimpl.Hook) {`
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Router)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), expected) {
+		t.Errorf("reflect: expected %q in\n%s", expected, b)
+	}
+
+	pkg := typeCheck(t, "example.com/hooks", "package hooks\n\ntype Hook func(err error) error\n\ntype Hooks interface {\n\tAdd(h Hook) Hook\n}\n")
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Hooks"), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Add(h hooks.Hook) (h1 hooks.Hook) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+type Result[T any] struct{ Value T }
+
+type Option[T any] func(*T)
+
+type User struct{}
+
+type Users interface {
+	Get(id string) (Result[User], error)
+	Configure(opts map[string]Option[int], durations []Result[time.Duration])
+}
+
+func TestInstantiatedTypes(t *testing.T) {
+	for pkgName, expected := range map[string][]string{
+		"pkg": {
+			"func (i *Impl) Configure(o map[string]goimpl.Option[int], r []goimpl.Result[time.Duration]) {",
+			"func (i *Impl) Get(s string) (r goimpl.Result[goimpl.User], err error) {",
+		},
+		"goimpl": {
+			"func (i *Impl) Configure(o map[string]Option[int], r []Result[time.Duration]) {",
+			"func (i *Impl) Get(s string) (r Result[User], err error) {",
+		},
+	} {
+		opts := GenOpts{PkgName: pkgName, ImplName: "*Impl", Inter: reflect.TypeOf((*Users)(nil)).Elem(), NoGoImports: true}
+		b, err := GenerateBytes(&opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !strings.Contains(string(b), e) {
+				t.Errorf("%s: expected %q in\n%s", pkgName, e, b)
+			}
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	for _, c := range []struct {
+		name, comment string
+		readOnly      bool
+	}{
+		{name: "Get", readOnly: true},
+		{name: "GetUser", readOnly: true},
+		{name: "IsValid", readOnly: true},
+		{name: "Issue"},
+		{name: "Set"},
+		{name: "Read"},
+		{name: "Read", comment: "Read reads a snapshot. goimpl:read", readOnly: true},
+		{name: "GetOrCreate", comment: "goimpl:write"},
+	} {
+		m := Method{Method: reflect.Method{Name: c.name}, Comment: c.comment}
+		if got := m.ReadOnly(); got != c.readOnly {
+			t.Errorf("%s %q: got %v, expected %v", c.name, c.comment, got, c.readOnly)
+		}
+	}
+}
+
+func TestImportPathName(t *testing.T) {
+	for path, name := range map[string]string{
+		"time":                        "time",
+		"example.com/users":           "users",
+		"example.com/mod/v2":          "mod",
+		"gopkg.in/yaml.v3":            "yaml",
+		"github.com/go-kit/log-level": "log_level",
+	} {
+		if got := importPathName(path); got != name {
+			t.Errorf("%s: got %q, expected %q", path, got, name)
+		}
+	}
+}
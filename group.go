@@ -0,0 +1,86 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"unicode/utf8"
+)
+
+// groupParams collapses the adjacent parameters (and named results) of the same type of the methods in f,
+// as gofmt-idiomatic code does: (from, to time.Time) rather than (from time.Time, to time.Time). See GenOpts.GroupParams.
+func groupParams(f *ast.File) {
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv != nil {
+			groupFields(fd.Type.Params)
+			groupFields(fd.Type.Results)
+		}
+	}
+}
+
+// groupFields merges the names of the adjacent named fields of the same type.
+func groupFields(fl *ast.FieldList) {
+	if fl == nil {
+		return
+	}
+	var fields []*ast.Field
+	for _, f := range fl.List {
+		if n := len(fields); n > 0 && len(f.Names) > 0 && len(fields[n-1].Names) > 0 &&
+			types.ExprString(f.Type) == types.ExprString(fields[n-1].Type) {
+			fields[n-1].Names = append(fields[n-1].Names, f.Names...)
+			continue
+		}
+		fields = append(fields, f)
+	}
+	fl.List = fields
+}
+
+// wrapSignatures puts the parameters of the methods whose first line is longer than max runes one per line:
+//
+//	func (c *Client) Send(
+//		ctx context.Context,
+//		...
+//	) error {
+//
+// The code is returned as is if it can not be parsed.
+func wrapSignatures(src []byte, max int) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return src
+	}
+	offset := func(p token.Pos) int { return fset.Position(p).Offset }
+	var out bytes.Buffer
+	last := 0
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil || fd.Body == nil || len(fd.Type.Params.List) == 0 {
+			continue
+		}
+		start, params := offset(fd.Pos()), fd.Type.Params
+		if fset.Position(params.Opening).Line != fset.Position(params.Closing).Line {
+			continue // Wrapped already.
+		}
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 || utf8.RuneCount(src[start:start+end]) <= max {
+			continue
+		}
+		out.Write(src[last : offset(params.Opening)+1])
+		out.WriteByte('\n')
+		for _, p := range params.List {
+			out.WriteByte('\t')
+			out.Write(src[offset(p.Pos()):offset(p.End())])
+			out.WriteString(",\n")
+		}
+		last = offset(params.Closing)
+	}
+	out.Write(src[last:])
+	b, err := format.Source(out.Bytes())
+	if err != nil {
+		return src
+	}
+	return b
+}
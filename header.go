@@ -0,0 +1,57 @@
+package goimpl
+
+import (
+	"strings"
+	"text/template"
+)
+
+// GoimplVersion returns the version of goimpl, for the templates.
+func (*GenOpts) GoimplVersion() string {
+	return Version
+}
+
+// TypeComment returns the doc comment of the generated type (see GenOpts.TypeDoc), for the templates.
+func (opts *GenOpts) TypeComment() string {
+	return opts.typeDoc
+}
+
+// InterfaceName returns the name of the interface (package.Name), for the templates:
+// that of Inter, or InterName if the interface is described by go/types.
+func (opts *GenOpts) InterfaceName() string {
+	return opts.interName()
+}
+
+// renderDocs executes the Header and TypeDoc templates with the options.
+// It returns the header, the type doc is kept for TypeComment. Both are turned into line comments.
+func (opts *GenOpts) renderDocs() (header string, err error) {
+	if header, err = opts.renderComment("Header", opts.Header); err != nil {
+		return "", err
+	}
+	if header != "" {
+		header += "\n"
+	}
+	opts.typeDoc, err = opts.renderComment("TypeDoc", opts.TypeDoc)
+	return header, err
+}
+
+// renderComment executes the template and prefixes the lines that are not comments yet with //.
+func (opts *GenOpts) renderComment(name, text string) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", diagf(InvalidTemplate, name, "", "Error parsing template: %s", err.Error())
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, opts); err != nil {
+		return "", diagf(InvalidTemplate, name, "", "%s", err.Error())
+	}
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "//") {
+			lines[i] = strings.TrimSpace("// " + l)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
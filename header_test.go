@@ -0,0 +1,61 @@
+package goimpl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	opts := GenOpts{
+		PkgName:             "fakes",
+		ImplName:            "Stringer",
+		Inter:               reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+		NoNamedReturnValues: true,
+		NoGoImports:         true,
+		Unimplemented:       UnimplementedZero,
+		Header:              "Code generated by goimpl {{.GoimplVersion}} at {{.Revision}}. DO NOT EDIT.",
+		TypeDoc:             "{{.Clean .ImplName}} is a fake {{.InterfaceName}}.\n\nPackage {{.PkgPath}} of {{.ModulePath}}.",
+		ModulePath:          "example.com/app",
+		PkgPath:             "example.com/app/fakes",
+		Revision:            "0123abc",
+	}
+	bts, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "header", string(bts), `// Code generated by goimpl `+Version+` at 0123abc. DO NOT EDIT.
+
+package fakes
+
+import ()
+
+// Stringer is a fake fmt.Stringer.
+//
+// Package example.com/app/fakes of example.com/app.
+type Stringer struct{}
+
+func (s Stringer) String() string {
+	return ""
+}
+`)
+	opts.Header = "{{.Nope}}"
+	if err := Generate(&opts, new(bytes.Buffer)); err == nil {
+		t.Error("expected an error for the header template")
+	}
+}
+
+func TestTypeDocFromTypes(t *testing.T) {
+	pkg := typeCheck(t, "example.com/store", storeSrc)
+	opts := GenOpts{PkgName: "fakes", ImplName: "*Store", InterName: "store.Store", NoGoImports: true, Extra: []string{"example.com/store"},
+		TypeDoc: "{{.Clean .ImplName}} implements {{.InterfaceName}}."}
+	var b bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Store"), pkg, &b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "// Store implements store.Store.\ntype Store struct{}"; !strings.Contains(b.String(), want) {
+		t.Errorf("expected %q in\n%s", want, b.String())
+	}
+}
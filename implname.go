@@ -0,0 +1,56 @@
+package goimpl
+
+import (
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// checkImplName reports the ImplName that is not a type name (with an optional *),
+// suggesting SanitizeImplName. With FixImplName, the sanitized name is used instead.
+func (opts *GenOpts) checkImplName() error {
+	if token.IsIdentifier(strings.TrimPrefix(opts.ImplName, "*")) {
+		return nil
+	}
+	fixed := SanitizeImplName(opts.ImplName)
+	if opts.FixImplName {
+		opts.ImplName = fixed
+		return nil
+	}
+	return diagf(InvalidImplName, opts.ImplName, "use "+fixed+" (or set FixImplName).", "invalid ImplName %q", opts.ImplName)
+}
+
+// SanitizeImplName turns name into a valid ImplName: the runes that can not be in an identifier are dropped
+// and the words they separate are joined in camel case ("my fake-reader" becomes myFakeReader).
+// A leading * is kept. Names starting with a digit get a leading underscore, keywords a trailing one,
+// and the empty name becomes Impl.
+func SanitizeImplName(name string) string {
+	ptr := ""
+	if strings.HasPrefix(name, "*") {
+		ptr, name = "*", name[1:]
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper && b.Len() > 0 {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	s := b.String()
+	switch {
+	case s == "":
+		s = "Impl"
+	case unicode.IsDigit([]rune(s)[0]):
+		s = "_" + s
+	case token.IsKeyword(s):
+		s += "_"
+	}
+	return ptr + s
+}
@@ -0,0 +1,44 @@
+package goimpl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeImplName(t *testing.T) {
+	cases := map[string]string{
+		"Impl":           "Impl",
+		"*impl":          "*impl",
+		"-bad name":      "badName",
+		"my fake-reader": "myFakeReader",
+		"*fake.Reader":   "*fakeReader",
+		"2nd":            "_2nd",
+		"type":           "type_",
+		"":               "Impl",
+		"*":              "*Impl",
+		"übung_2":        "übung_2",
+	}
+	for name, expected := range cases {
+		if got := SanitizeImplName(name); got != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, got)
+		}
+	}
+}
+
+func TestFixImplName(t *testing.T) {
+	stringer := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	err := Generate(&GenOpts{PkgName: "pkg", ImplName: "*my impl", Inter: stringer}, new(strings.Builder))
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidImplName || !strings.Contains(d.Hint, "*myImpl") {
+		t.Errorf("expected a diagnostic suggesting *myImpl, got %#v", err)
+	}
+	opts := GenOpts{PkgName: "pkg", ImplName: "*my impl", Inter: stringer, FixImplName: true, NoGoImports: true}
+	bts, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bts), "func (m *myImpl) String() (s string)") {
+		t.Errorf("expected the methods of *myImpl, got:\n%s", bts)
+	}
+}
@@ -0,0 +1,284 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// mappedImport returns the import replacing the package path (see GenOpts.ImportMap).
+// ok is false if the path is not mapped.
+func (opts *GenOpts) mappedImport(path string) (imp Import, ok bool) {
+	spec, ok := opts.ImportMap[path]
+	if !ok {
+		return imp, false
+	}
+	if f := strings.Fields(spec); len(f) == 2 {
+		return Import{Name: f[0], Path: f[1]}, true
+	}
+	return Import{Path: strings.TrimSpace(spec)}, true
+}
+
+// mappedImports returns the imports replacing the packages of ImportMap, by the replaced path.
+// Without a name in ImportMap, the replacement is imported with the name of the package it replaces
+// when its path suggests another one (example.com/fork for package up): the generated code refers to that name.
+func (opts *GenOpts) mappedImports() map[string]Import {
+	refs := opts.referencedPackages()
+	imps := map[string]Import{}
+	for path := range opts.ImportMap {
+		imp, _ := opts.mappedImport(path)
+		if name, ok := refs[path]; ok && imp.Name == "" && name != importPathName(imp.Path) {
+			imp.Name = name
+		}
+		imps[path] = imp
+	}
+	return imps
+}
+
+// qualifier returns the name the package (named name, with the path) is referred to in the generated code.
+func (opts *GenOpts) qualifier(name, path string) string {
+	if imp, ok := opts.mappedImport(path); ok && imp.Name != "" {
+		return imp.Name
+	}
+	if renamed, ok := opts.renamed[path]; ok {
+		return renamed
+	}
+	return name
+}
+
+// MappedImports returns the imports of the packages from ImportMap and of the packages imported with a name
+// (see renameImports), sorted by path.
+func (opts *GenOpts) MappedImports() []Import {
+	var imps []Import
+	for _, imp := range opts.mappedImports() {
+		imps = append(imps, imp)
+	}
+	for path, name := range opts.renamed {
+		if name == importPathName(path) {
+			name = "" // Imported explicitly, so goimports does not pick another package of the same name.
+		}
+		imps = append(imps, Import{Name: name, Path: path})
+	}
+	sort.Slice(imps, func(i, j int) bool { return imps[i].Path < imps[j].Path })
+	return imps
+}
+
+// unmapped returns the paths without the ones replaced by ImportMap or imported for a name clash.
+func (opts *GenOpts) unmapped(paths []string) []string {
+	var r []string
+	for _, p := range paths {
+		_, mapped := opts.ImportMap[p]
+		if _, renamed := opts.renamed[p]; !mapped && !renamed {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// bodyPackages are the packages the generated bodies might use, by name. They keep their names.
+var bodyPackages = map[string]string{
+	"context": "context",
+	"errors":  "errors",
+	"fmt":     "fmt",
+	"log":     "log",
+	"runtime": "runtime",
+	"notimpl": "github.com/sasha-s/goimpl/notimpl",
+}
+
+// renameImports picks the names of the packages the signatures of the interface refer to, so that different packages
+// with the same name (e.g. text/template and html/template) do not clash. The standard library comes first, then
+// the paths in order: the first one keeps the name, the next ones are prefixed with the previous element
+// of their paths (texttemplate), or numbered if that is taken too. The packages of the bodies and of ImportMap keep their names.
+// All the packages of a clash, and the ones named other than their paths suggest, are imported explicitly (with a name).
+func (opts *GenOpts) renameImports() {
+	opts.renamed = map[string]string{}
+	taken := map[string]bool{opts.PkgName: true}
+	for name := range bodyPackages {
+		taken[name] = true
+	}
+	for _, imp := range opts.mappedImports() {
+		if imp.Name == "" {
+			imp.Name = importPathName(imp.Path)
+		}
+		taken[imp.Name] = true
+	}
+	refs := opts.referencedPackages()
+	paths := make([]string, 0, len(refs))
+	for path := range refs {
+		if _, mapped := opts.ImportMap[path]; !mapped && bodyPackages[refs[path]] != path {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if si, sj := isStd(paths[i]), isStd(paths[j]); si != sj {
+			return si
+		}
+		return paths[i] < paths[j]
+	})
+	first := map[string]string{} // The path keeping the name.
+	for _, path := range paths {
+		name := refs[path]
+		if !taken[name] {
+			taken[name] = true
+			first[name] = path
+			if name != importPathName(path) {
+				opts.renamed[path] = name // Imported with its name, e.g. store "example.com/go-store/v2".
+			}
+			continue
+		}
+		if p, ok := first[name]; ok {
+			opts.renamed[p] = name
+		}
+		renamed := name
+		if elems := strings.Split(path, "/"); len(elems) > 1 {
+			renamed = strings.ToLower(clean(elems[len(elems)-2])) + name
+		}
+		for i := 2; taken[renamed]; i++ {
+			renamed = name + strconv.Itoa(i)
+		}
+		taken[renamed] = true
+		opts.renamed[path] = renamed
+	}
+}
+
+// addTypeImports adds the paths of the packages the signatures refer to to the imports, so the generated code compiles without goimports.
+func (opts *GenOpts) addTypeImports(imports []string) []string {
+	refs := opts.referencedPackages()
+	paths := make([]string, 0, len(refs))
+	for path := range refs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	have := map[string]bool{}
+	for _, imp := range imports {
+		have[imp] = true
+	}
+	for _, path := range paths {
+		if !have[path] {
+			imports = append(imports, path)
+		}
+	}
+	return imports
+}
+
+// dropUnusedImports removes the imports the file does not use, like goimports would: e.g. the package of the interface,
+// given in Extra, when no signature refers to it.
+func (opts *GenOpts) dropUnusedImports(fset *token.FileSet, f *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	refs := opts.referencedPackages()
+	// Deleting an import shifts f.Imports: range over a copy.
+	for _, imp := range append([]*ast.ImportSpec(nil), f.Imports...) {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name, specName := importPathName(path), ""
+		if imp.Name != nil {
+			name, specName = imp.Name.Name, imp.Name.Name
+		} else if ref, ok := refs[path]; ok {
+			name = ref
+		}
+		if name != "_" && name != "." && !used[name] {
+			astutil.DeleteNamedImport(fset, f, specName, path)
+		}
+	}
+}
+
+// walkNamed calls visit with the named types t is made of (t itself if it is named), once.
+func walkNamed(t reflect.Type, seen map[reflect.Type]bool, visit func(reflect.Type)) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	if t.Name() != "" {
+		visit(t)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		walkNamed(t.Elem(), seen, visit)
+	case reflect.Map:
+		walkNamed(t.Key(), seen, visit)
+		walkNamed(t.Elem(), seen, visit)
+	case reflect.Func:
+		for i := 0; i < t.NumIn(); i++ {
+			walkNamed(t.In(i), seen, visit)
+		}
+		for i := 0; i < t.NumOut(); i++ {
+			walkNamed(t.Out(i), seen, visit)
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			walkNamed(t.Field(i).Type, seen, visit)
+		}
+	case reflect.Interface:
+		for i := 0; i < t.NumMethod(); i++ {
+			walkNamed(t.Method(i).Type, seen, visit)
+		}
+	}
+}
+
+// isStd reports whether the package is (likely) from the standard library: no dot in the first element of the path.
+func isStd(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+// referencedPackages returns the names of the packages the signatures of the generated methods (and Adapt) refer to, by path,
+// but the package the code is generated in.
+func (opts *GenOpts) referencedPackages() map[string]string {
+	refs := map[string]string{}
+	if opts.types != nil {
+		for i := 0; i < opts.types.inter.NumMethods(); i++ {
+			if _, skip := opts.MethodBlacklist[opts.types.inter.Method(i).Name()]; skip {
+				continue
+			}
+			types.TypeString(opts.types.inter.Method(i).Type(), func(p *types.Package) string {
+				if p != opts.types.pkg && !(opts.types.pkg == nil && p.Name() == opts.PkgName) {
+					refs[p.Path()] = p.Name()
+				}
+				return p.Name()
+			})
+		}
+		return refs
+	}
+	seen := map[reflect.Type]bool{}
+	walk := func(t reflect.Type) {
+		walkNamed(t, seen, func(t reflect.Type) {
+			if pkg, _ := packageAndName(t); pkg != "" && pkg != opts.PkgName {
+				refs[t.PkgPath()] = pkg
+			}
+			if i := strings.IndexByte(t.Name(), '['); i >= 0 {
+				for _, m := range qualifiedRe.FindAllStringSubmatch(t.Name()[i:], -1) {
+					if name := importPathName(m[1]); name != opts.PkgName {
+						refs[m[1]] = name
+					}
+				}
+			}
+		})
+	}
+	if opts.Inter != nil {
+		for i := 0; i < opts.Inter.NumMethod(); i++ {
+			if _, skip := opts.MethodBlacklist[opts.Inter.Method(i).Name]; !skip {
+				walk(opts.Inter.Method(i).Type)
+			}
+		}
+	}
+	if opts.Adapt != nil {
+		walk(opts.Adapt)
+	}
+	return refs
+}
@@ -0,0 +1,77 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	htmltemplate "html/template"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+type Renderer interface {
+	Render(t *template.Template, h *htmltemplate.Template) error
+}
+
+func TestRenameImports(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Renderer)(nil)).Elem(), NoGoImports: true,
+		Extra: []string{"html/template", "text/template"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"html/template\"\n\ttexttemplate \"text/template\"\n",
+		"func (i *Impl) Render(t *texttemplate.Template, t1 *template.Template) (err error) {",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("reflect: expected %q in\n%s", want, b)
+		}
+	}
+
+	// Two packages named types, and one named errors.
+	var params []*types.Var
+	for _, path := range []string{"example.com/b/types", "example.com/a/types", "example.com/errors"} {
+		p := types.NewPackage(path, path[strings.LastIndex(path, "/")+1:])
+		tn := types.NewTypeName(token.NoPos, p, "T", nil)
+		params = append(params, types.NewVar(token.NoPos, nil, "", types.NewNamed(tn, types.NewStruct(nil, nil), nil)))
+	}
+	sig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), nil, false)
+	inter := types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, nil, "Use", sig)}, nil).Complete()
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, inter, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\t\"example.com/a/types\"\n\tbtypes \"example.com/b/types\"\n\texamplecomerrors \"example.com/errors\"\n",
+		"func (i *Impl) Use(t btypes.T, t1 types.T, t2 examplecomerrors.T) {",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestTypeImports(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Renderer)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Compiles without goimports.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "impl.go", b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("pkg", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("%v in\n%s", err, b)
+	}
+}
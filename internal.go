@@ -0,0 +1,155 @@
+package goimpl
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// internalRoot returns the path of the package the internal package (path) belongs to: example.com/x for
+// example.com/x/internal/y, "" for the internal packages of the standard library. ok is false if it is not internal.
+func internalRoot(path string) (root string, ok bool) {
+	switch {
+	case path == "internal" || strings.HasPrefix(path, "internal/"):
+		return "", true
+	case strings.HasSuffix(path, "/internal"):
+		return strings.TrimSuffix(path, "/internal"), true
+	}
+	if i := strings.LastIndex(path, "/internal/"); i >= 0 {
+		return path[:i], true
+	}
+	return "", false
+}
+
+// canImport reports whether the package pkgPath can import path: the internal packages can only be imported
+// from within the tree of their parent.
+func canImport(pkgPath, path string) bool {
+	root, internal := internalRoot(path)
+	if !internal {
+		return true
+	}
+	if root == "" {
+		return isStd(pkgPath)
+	}
+	return pkgPath == root || strings.HasPrefix(pkgPath, root+"/")
+}
+
+// methodTypes returns the named types the signature of the method refers to, qualified by the import path
+// (example.com/x/internal/y.T).
+func (opts *GenOpts) methodTypes(name string) []string {
+	var sig string
+	if opts.types != nil {
+		for i := 0; i < opts.types.inter.NumMethods(); i++ {
+			if m := opts.types.inter.Method(i); m.Name() == name {
+				sig = types.TypeString(m.Type(), func(p *types.Package) string { return p.Path() })
+			}
+		}
+	} else if m, ok := opts.Inter.MethodByName(name); ok {
+		var names []string
+		walkNamed(m.Type, map[reflect.Type]bool{}, func(t reflect.Type) {
+			if t.PkgPath() != "" {
+				_, base := packageAndName(t)
+				names = append(names, t.PkgPath()+"."+base)
+			}
+			if i := strings.IndexByte(t.Name(), '['); i >= 0 {
+				names = append(names, t.Name()[i:])
+			}
+		})
+		sig = strings.Join(names, " ")
+	}
+	var qualified []string
+	seen := map[string]bool{}
+	for _, m := range qualifiedRe.FindAllString(sig, -1) {
+		if !seen[m] {
+			seen[m] = true
+			qualified = append(qualified, m)
+		}
+	}
+	return qualified
+}
+
+// checkInternal reports the methods referring to the internal packages of other modules, which can never be implemented
+// in PkgPath (if set). With InternalPlaceholders, they are commented out instead.
+func (opts *GenOpts) checkInternal() error {
+	if opts.PkgPath == "" {
+		return nil
+	}
+	opts.placeholders = map[string]string{}
+	var offending []string
+	for _, m := range opts.Methods(opts.Inter) {
+		var internal []string
+		for _, t := range opts.methodTypes(m.Name) {
+			if path := t[:strings.LastIndex(t, ".")]; !canImport(opts.PkgPath, path) {
+				internal = append(internal, t)
+			}
+		}
+		if len(internal) == 0 {
+			continue
+		}
+		offending = append(offending, fmt.Sprintf("%s (%s)", m.Name, strings.Join(internal, ", ")))
+		opts.placeholders[m.Name] = fmt.Sprintf("%s can not be implemented in %s: it refers to %s, internal to another module.",
+			m.Name, opts.PkgPath, strings.Join(internal, ", "))
+	}
+	if len(offending) == 0 || opts.InternalPlaceholders {
+		return nil
+	}
+	sort.Strings(offending)
+	location := ""
+	if opts.Inter != nil {
+		location = opts.Inter.String()
+	} else if opts.types != nil {
+		location = opts.types.inter.String()
+	}
+	return diagf(UnexportedInterface, location, "set InternalPlaceholders (-internal-placeholders) to comment the methods out, or generate the code within the module.",
+		"methods referring to internal packages can not be implemented in %s: %s", opts.PkgPath, strings.Join(offending, "; "))
+}
+
+// commentPlaceholders comments out the declarations of the methods that can not be implemented (see checkInternal),
+// preceded by the reason.
+func (opts *GenOpts) commentPlaceholders(src []byte) []byte {
+	if len(opts.placeholders) == 0 {
+		return src
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return src // Reported when the code is formatted.
+	}
+	var out bytes.Buffer
+	last := 0
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil {
+			continue
+		}
+		reason, ok := opts.placeholders[fd.Name.Name]
+		if !ok {
+			continue
+		}
+		start := fset.Position(fd.Pos()).Offset
+		if fd.Doc != nil {
+			start = fset.Position(fd.Doc.Pos()).Offset
+		}
+		start = bytes.LastIndexByte(src[:start], '\n') + 1
+		var decl bytes.Buffer
+		if err := format.Node(&decl, fset, &printer.CommentedNode{Node: fd, Comments: f.Comments}); err != nil {
+			return src
+		}
+		out.Write(src[last:start])
+		fmt.Fprintf(&out, "// %s\n", reason)
+		for _, line := range strings.Split(decl.String(), "\n") {
+			fmt.Fprintf(&out, "// %s\n", line)
+		}
+		last = fset.Position(fd.End()).Offset
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,78 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestCanImport(t *testing.T) {
+	tc := []struct {
+		pkgPath, path string
+		expected      bool
+	}{
+		{"example.com/app", "example.com/lib", true},
+		{"example.com/app", "example.com/lib/internal/wire", false},
+		{"example.com/lib/fakes", "example.com/lib/internal/wire", true},
+		{"example.com/lib", "example.com/lib/internal", true},
+		{"example.com/library", "example.com/lib/internal", false},
+		{"example.com/app", "internal/poll", false},
+		{"os", "internal/poll", true},
+	}
+	for _, c := range tc {
+		if got := canImport(c.pkgPath, c.path); got != c.expected {
+			t.Errorf("canImport(%q, %q): got %v, expected %v", c.pkgPath, c.path, got, c.expected)
+		}
+	}
+}
+
+// frameInter returns an interface with a method referring to a type from an internal package.
+func frameInter() *types.Interface {
+	p := types.NewPackage("example.com/lib/internal/wire", "wire")
+	frame := types.NewNamed(types.NewTypeName(token.NoPos, p, "Frame", nil), types.NewStruct(nil, nil), nil)
+	errType := types.Universe.Lookup("error").Type()
+	send := types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "f", frame)),
+		types.NewTuple(types.NewVar(token.NoPos, nil, "", errType)), false)
+	closeSig := types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", errType)), false)
+	return types.NewInterfaceType([]*types.Func{
+		types.NewFunc(token.NoPos, nil, "Send", send),
+		types.NewFunc(token.NoPos, nil, "Close", closeSig),
+	}, nil).Complete()
+}
+
+func TestInternalTypes(t *testing.T) {
+	opts := GenOpts{PkgName: "fakes", ImplName: "*Conn", PkgPath: "example.com/app/fakes", NoGoImports: true}
+	err := GenerateFromTypes(&opts, frameInter(), nil, &bytes.Buffer{})
+	if d, ok := err.(*Diagnostic); !ok || d.Code != UnexportedInterface || !strings.Contains(d.Message, "Send (example.com/lib/internal/wire.Frame)") {
+		t.Errorf("got %v, expected an UnexportedInterface diagnostic listing Send", err)
+	}
+
+	opts = GenOpts{PkgName: "fakes", ImplName: "*Conn", PkgPath: "example.com/app/fakes", NoGoImports: true, InternalPlaceholders: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, frameInter(), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Send can not be implemented in example.com/app/fakes: it refers to example.com/lib/internal/wire.Frame, internal to another module.\n" +
+			"// func (c *Conn) Send(f wire.Frame) (err error) {\n",
+		"func (c *Conn) Close() (err error) {",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("placeholders: expected %q in\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `"example.com/lib/internal/wire"`) {
+		t.Errorf("placeholders: unexpected import of the internal package in\n%s", buf.String())
+	}
+
+	opts = GenOpts{PkgName: "fakes", ImplName: "*Conn", PkgPath: "example.com/lib/fakes", NoGoImports: true}
+	buf.Reset()
+	if err := GenerateFromTypes(&opts, frameInter(), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "func (c *Conn) Send(f wire.Frame) (err error) {") {
+		t.Errorf("same module: expected Send in\n%s", buf.String())
+	}
+}
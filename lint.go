@@ -0,0 +1,43 @@
+package goimpl
+
+import (
+	"go/token"
+	"strings"
+)
+
+// applyLint sets the defaults of Lint: unless set otherwise, the stubs return errors (instead of panicking) and do not
+// name the parameters they do not use, the errors returned by the fields the methods are delegated to are wrapped,
+// and the exported type gets a doc comment.
+func (opts *GenOpts) applyLint() {
+	if !opts.Lint {
+		return
+	}
+	if opts.Unimplemented == "" {
+		opts.Unimplemented = UnimplementedError
+	}
+	opts.BlankArgs = true
+	opts.WrapErrors = true
+	if name := opts.Clean(opts.ImplName); opts.TypeDoc == "" && token.IsExported(name) {
+		opts.TypeDoc = name + " " + opts.implements() + "."
+	}
+}
+
+// lintComment gives the exported methods a doc comment starting with their name (see GenOpts.Lint).
+func (opts *GenOpts) lintComment(m *Method) {
+	if !opts.Lint || !token.IsExported(m.Name) || strings.HasPrefix(m.Comment, m.Name+" ") {
+		return
+	}
+	if m.Comment == "" {
+		m.Comment = m.Name + " " + opts.implements() + "."
+		return
+	}
+	m.Comment = m.Name + " " + m.Comment
+}
+
+// implements describes what the generated code implements, for the doc comments.
+func (opts *GenOpts) implements() string {
+	if name := opts.interName(); name != "" {
+		return "implements " + name
+	}
+	return "is generated by goimpl"
+}
@@ -0,0 +1,90 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode type checks the package and its dependencies from source,
+// so the export data of the installed Go (which might be newer than the loader) is not needed.
+const loadMode = packages.NeedName | packages.NeedTypes | packages.NeedTypesSizes | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps
+
+// LoadPackage loads and type checks the package matching the pattern (usually an import path) with golang.org/x/tools/go/packages,
+// as the go command sees it from the current directory: go.mod, replace directives, the module cache and go.work are honored.
+// It fails unless the pattern matches a single package.
+func LoadPackage(pattern string) (*types.Package, error) {
+	return loadPackage(pattern, "")
+}
+
+// loadPackage is LoadPackage from the module in dir (the current one if empty).
+func loadPackage(pattern, dir string) (*types.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: loadMode, Dir: dir}, pattern)
+	if err != nil {
+		return nil, diagf(UnresolvableType, pattern, "check the pattern and the module it is loaded from.", "%s", err.Error())
+	}
+	if len(pkgs) != 1 {
+		return nil, diagf(UnresolvableType, pattern, "use an import path.", "%s matches %d packages, want 1", pattern, len(pkgs))
+	}
+	// The type errors (e.g. in a stale generated file of the package) leave the rest of the types usable.
+	p := pkgs[0]
+	for _, e := range p.Errors {
+		if e.Kind != packages.TypeError || p.Types == nil {
+			return nil, diagf(UnresolvableType, pattern, "check the import path and the module it is loaded from.", "%s", e.Error())
+		}
+	}
+	return p.Types, nil
+}
+
+// ParseInterface type checks the Go file at path (or the package in the directory) on its own
+// and returns the interface named name, for scratch files and packages that do not compile yet:
+// the errors are ignored as long as the interface is declared. The imports are type checked from source.
+// The import path of the package is the one the go command reports, or its name outside of a module.
+func ParseInterface(path, name string) (*types.Named, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, diagf(UnresolvableType, path, "", "%s", err.Error())
+	}
+	files, dir := []string{path}, filepath.Dir(path)
+	if fi.IsDir() {
+		dir = path
+		if files, err = filepath.Glob(filepath.Join(path, "*.go")); err != nil {
+			return nil, err
+		}
+	}
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") && fi.IsDir() {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if f == nil {
+			return nil, diagf(InvalidCode, file, "", "%s", err.Error())
+		}
+		parsed = append(parsed, f)
+	}
+	if len(parsed) == 0 {
+		return nil, diagf(UnresolvableType, path, "", "no Go files in %s", path)
+	}
+	pkgPath := parsed[0].Name.Name
+	cmd := exec.Command("go", "list", "-e", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = dir
+	if out, err := cmd.Output(); err == nil && !strings.HasPrefix(string(out), "_") {
+		pkgPath = strings.TrimSpace(string(out))
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(pkgPath, fset, parsed, nil)
+	return InterfaceOf(pkg, name)
+}
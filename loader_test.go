@@ -0,0 +1,57 @@
+package goimpl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPackage(t *testing.T) {
+	pkg, err := LoadPackage("github.com/sasha-s/goimpl/notimpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name() != "notimpl" || pkg.Scope().Lookup("Error") == nil {
+		t.Errorf("expected the notimpl package with Error, got %s %v", pkg.Name(), pkg.Scope().Names())
+	}
+	for _, pattern := range []string{"./...", "example.com/nope"} {
+		if _, err := LoadPackage(pattern); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		} else if d, ok := err.(*Diagnostic); !ok || d.Code != UnresolvableType {
+			t.Errorf("%s: expected an UnresolvableType diagnostic, got %v", pattern, err)
+		}
+	}
+}
+
+func TestParseInterface(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "contracts.go")
+	src := `package contracts
+
+import "context"
+
+type Item struct{}
+
+type Store interface {
+	Get(ctx context.Context, key string) (*Item, error)
+}
+
+var _ = notYetWritten()
+`
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{file, dir} {
+		named, err := ParseInterface(path, "Store")
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if got := named.Underlying().String(); got != "interface{Get(ctx context.Context, key string) (*contracts.Item, error)}" {
+			t.Errorf("%s: got %s", path, got)
+		}
+	}
+	if _, err := ParseInterface(file, "Item"); !errors.Is(err, ErrNotAnInterface) {
+		t.Errorf("Item: expected ErrNotAnInterface, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package goimpl
+
+import (
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ArgType describes the type of an argument to name, see NamingStrategy.
+type ArgType struct {
+	Package string       // Name of the package of the type, "" for the predeclared and the literal types.
+	PkgPath string       // Import path of the package of the type.
+	Name    string       // Name of the type, without the pointers and the slices: Reader for []*io.Reader, struct and interface for the literals.
+	Error   bool         // The type implements error.
+	Context bool         // The type implements context.Context.
+	Type    reflect.Type // The type (with the pointers and the slices), if the interface is described by reflect.
+	T       types.Type   // The type, if the interface is described by go/types.
+}
+
+// NamingStrategy names the arguments of the generated methods (see GenOpts.Naming).
+type NamingStrategy interface {
+	// ArgName returns the name of an argument of type t. It does not need to be unique: the names taken get a number
+	// appended (r, r1). The invalid identifiers (and "") are replaced with the name ShortNames gives.
+	ArgName(t ArgType) string
+}
+
+// NamingFunc is a NamingStrategy calling the func.
+type NamingFunc func(t ArgType) string
+
+// ArgName calls f.
+func (f NamingFunc) ArgName(t ArgType) string {
+	return f(t)
+}
+
+// KnownArgNames are the names ShortNames gives to the arguments of well-known types, by the import path and the name
+// of the type (without the pointers and the slices). Add to it to recognize more types, or use GenOpts.ArgNames.
+var KnownArgNames = map[string]string{
+	"bytes.Buffer":            "buf",
+	"database/sql.DB":         "db",
+	"database/sql.Tx":         "tx",
+	"io.Reader":               "r",
+	"io.Writer":               "w",
+	"net/http.Request":        "r",
+	"net/http.ResponseWriter": "w",
+	"sync.Mutex":              "mu",
+	"sync.WaitGroup":          "wg",
+	"testing.B":               "b",
+	"testing.T":               "t",
+	"testing.TB":              "tb",
+	"time.Duration":           "d",
+}
+
+// abbreviations of the type names repeating the package name, without it (see unstutter).
+var abbreviations = map[string]string{
+	"Config":   "cfg",
+	"Message":  "msg",
+	"Options":  "opts",
+	"Request":  "req",
+	"Response": "resp",
+}
+
+// ShortNames is the default NamingStrategy: err and ctx for the errors and the contexts, the KnownArgNames,
+// the type in lowercase for the short names (ip for net.IP), the first letter otherwise (s for fmt.Stringer).
+// The names repeating the package name are shortened without it: id for user.UserID, req for payment.PaymentRequest.
+type ShortNames struct{}
+
+// ArgName implements NamingStrategy.
+func (ShortNames) ArgName(t ArgType) string {
+	switch {
+	case t.Error:
+		return "err"
+	case t.Context:
+		return "ctx"
+	}
+	if name, ok := KnownArgNames[t.PkgPath+"."+t.Name]; ok && t.PkgPath != "" {
+		return name
+	}
+	name := t.Name
+	if rest, ok := unstutter(t.Package, name); ok {
+		if abbr, ok := abbreviations[rest]; ok {
+			return abbr
+		}
+		name = rest
+	}
+	n, clean := lowerName(name)
+	// Very short names.
+	if len(n) <= 3 && string(n) != clean && string(n) != t.Package {
+		return string(n)
+	}
+	return GenOpts{}.First(name)
+}
+
+// unstutter returns the name of the type without the name of the package it starts with: ID for UserID in package user.
+// ok is false if it does not start with it (or it is all there is).
+func unstutter(pkg, name string) (rest string, ok bool) {
+	if pkg == "" || len(name) <= len(pkg) || !strings.EqualFold(name[:len(pkg)], pkg) {
+		return name, false
+	}
+	rest = name[len(pkg):]
+	if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsUpper(r) {
+		return name, false
+	}
+	return rest, true
+}
+
+// TypeNames is a NamingStrategy using the names of the types in lowerCamelCase (reader for io.Reader,
+// httpClient for *HTTPClient), except for err and ctx. The names that would shadow a predeclared identifier
+// or the package of the type are the ones of ShortNames.
+type TypeNames struct{}
+
+// ArgName implements NamingStrategy.
+func (TypeNames) ArgName(t ArgType) string {
+	name := lowerCamel(clean(t.Name))
+	if t.Error || t.Context || name == "" || name == t.Package || token.Lookup(name).IsKeyword() || types.Universe.Lookup(name) != nil {
+		return ShortNames{}.ArgName(t)
+	}
+	return name
+}
+
+// lowerCamel lowers the leading uppercase letters of s, but the first letter of the next word: HTTPClient -> httpClient.
+func lowerCamel(s string) string {
+	rs := []rune(s)
+	for i, r := range rs {
+		if !unicode.IsUpper(r) || i > 0 && i+1 < len(rs) && unicode.IsLower(rs[i+1]) {
+			break
+		}
+		rs[i] = unicode.ToLower(r)
+	}
+	return string(rs)
+}
+
+// checkArgNames reports the ArgNames that are not valid identifiers.
+func (opts *GenOpts) checkArgNames() error {
+	for t, name := range opts.ArgNames {
+		if !token.IsIdentifier(name) || name == "_" {
+			return diagf(InvalidOptions, t, "use an identifier.", "invalid argument name %q", name)
+		}
+	}
+	return nil
+}
+
+// argName returns the name ArgNames gives to the arguments of type t ("" if none): for the type itself,
+// or for the type without the pointers and the slices.
+func (opts *GenOpts) argName(t ArgType) string {
+	if len(opts.ArgNames) == 0 {
+		return ""
+	}
+	full, base := t.Name, t.Name
+	if t.Package != "" {
+		base = t.Package + "." + t.Name
+	}
+	if t.Type != nil {
+		full = t.Type.String()
+	} else if t.T != nil {
+		full = types.TypeString(t.T, func(p *types.Package) string { return p.Name() })
+	}
+	for _, s := range []string{full, base} {
+		for k, name := range opts.ArgNames {
+			// interface {} is interface{} for reflect.
+			if strings.ReplaceAll(k, " ", "") == strings.ReplaceAll(s, " ", "") {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// reservedIdents is reservedNames for a method of type ft described by reflect.
+func (opts *GenOpts) reservedIdents(ft reflect.Type) map[string]bool {
+	reserved := opts.bodyReserved()
+	for _, t := range opts.typeNames(ft) {
+		for _, m := range qualifierRe.FindAllStringSubmatch(t, -1) {
+			reserved[m[1]] = true
+		}
+	}
+	return reserved
+}
@@ -0,0 +1,170 @@
+package goimpl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Fetcher interface {
+	Fetch(ctx context.Context, c *http.Client, u *url.URL, r io.Reader, n int) (*http.Response, error)
+}
+
+type Tester interface {
+	Run(t *testing.T, wg *sync.WaitGroup, timeout time.Duration, f fmt.Stringer) error
+}
+
+func TestNaming(t *testing.T) {
+	tc := []struct {
+		naming   NamingStrategy
+		expected string
+	}{
+		{nil, "func (i *Impl) Fetch(ctx context.Context, c *http.Client, u *url.URL, r io.Reader, i1 int) (r1 *http.Response, err error) {"},
+		{TypeNames{}, "func (i *Impl) Fetch(ctx context.Context, client *http.Client, u *url.URL, reader io.Reader, i1 int) (response *http.Response, err error) {"},
+		{NamingFunc(func(t ArgType) string { return "the" + t.Name }), "func (i *Impl) Fetch(theContext context.Context, theClient *http.Client, theURL *url.URL, theReader io.Reader, theint int) (theResponse *http.Response, theerror error) {"},
+		// Invalid names fall back to ShortNames.
+		{NamingFunc(func(t ArgType) string { return "" }), "func (i *Impl) Fetch(ctx context.Context, c *http.Client, u *url.URL, r io.Reader, i1 int) (r1 *http.Response, err error) {"},
+	}
+	for _, c := range tc {
+		opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Fetcher)(nil)).Elem(), NoGoImports: true, Naming: c.naming}
+		b, err := GenerateBytes(&opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), c.expected) {
+			t.Errorf("%T: expected %q in\n%s", c.naming, c.expected, b)
+		}
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\nimport \"io\"\n\ntype KV interface {\n\tCopy(dst io.Writer, src io.Reader) (int64, error)\n}\n")
+	opts := GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, Naming: TypeNames{}}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Copy(writer io.Writer, reader io.Reader) (i1 int64, err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+func TestLowerCamel(t *testing.T) {
+	for s, expected := range map[string]string{"Reader": "reader", "HTTPClient": "httpClient", "URL": "url", "ID": "id", "x": "x", "": ""} {
+		if got := lowerCamel(s); got != expected {
+			t.Errorf("lowerCamel(%q): got %q, expected %q", s, got, expected)
+		}
+	}
+}
+
+func TestArgNames(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Fetcher)(nil)).Elem(), NoGoImports: true,
+		ArgNames: map[string]string{"*http.Client": "client", "url.URL": "target", "context.Context": "c"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Fetch(c context.Context, client *http.Client, target *url.URL, r io.Reader, i1 int) (r1 *http.Response, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\nimport \"io\"\n\ntype KV interface {\n\tCopy(dst io.Writer, src io.Reader) (int64, error)\n}\n")
+	opts = GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true, ArgNames: map[string]string{"io.Writer": "w", "int64": "n"}}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Copy(w io.Writer, r io.Reader) (n int64, err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Fetcher)(nil)).Elem(), ArgNames: map[string]string{"io.Reader": "my reader"}}
+	_, err = GenerateBytes(&opts)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("invalid name: got %v, expected an InvalidOptions diagnostic", err)
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	pkg := typeCheck(t, "example.com/kv", "package kv\n\nimport \"io\"\n\ntype IO struct{}\n\ntype Len int\n\ntype KV interface {\n\tCopy(x IO, r io.Reader, n Len) error\n}\n")
+	opts := GenOpts{PkgName: "kv", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "KV"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Copy(io1 IO, r io.Reader, len1 Len) (err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Fetcher)(nil)).Elem(), NoGoImports: true,
+		ArgNames: map[string]string{"*url.URL": "url", "int": "len"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Fetch(ctx context.Context, c *http.Client, url1 *url.URL, r io.Reader, len1 int) (r1 *http.Response, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+}
+
+func TestLocals(t *testing.T) {
+	pkg := typeCheck(t, "example.com/graph", "package graph\n\ntype Graph struct{}\n\ntype Walker interface {\n\tWalk(g Graph, next func(Graph) bool) error\n}\n")
+	opts := GenOpts{PkgName: "graph", ImplName: "*gen", ParamNames: true, NoGoImports: true, Locals: []string{"g", "next"}}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Walker"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (g1 *gen) Walk(g2 Graph, g3 func(Graph) bool) (err error) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+
+	opts = GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Fetcher)(nil)).Elem(), NoGoImports: true, Locals: []string{"ctx", "c"}}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Fetch(ctx1 context.Context, c1 *http.Client, u *url.URL, r io.Reader, i1 int) (r1 *http.Response, err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+}
+
+func TestKnownArgNames(t *testing.T) {
+	KnownArgNames["fmt.Stringer"] = "str"
+	defer delete(KnownArgNames, "fmt.Stringer")
+	opts := GenOpts{PkgName: "pkg", ImplName: "*Impl", Inter: reflect.TypeOf((*Tester)(nil)).Elem(), NoGoImports: true}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Run(t *testing.T, wg *sync.WaitGroup, d time.Duration, str fmt.Stringer) (err error) {"; !strings.Contains(string(b), want) {
+		t.Errorf("reflect: expected %q in\n%s", want, b)
+	}
+
+	pkg := typeCheck(t, "example.com/web", "package web\n\nimport \"net/http\"\n\ntype Handler interface {\n\tServe(http.ResponseWriter, *http.Request)\n}\n")
+	opts = GenOpts{PkgName: "web", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Handler"), pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Serve(w http.ResponseWriter, r *http.Request) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("go/types: expected %q in\n%s", want, buf.String())
+	}
+}
+
+func TestUnstutter(t *testing.T) {
+	pkg := typeCheck(t, "example.com/user", "package user\n\ntype UserID string\n\ntype UserRequest struct{}\n\ntype User struct{}\n\ntype Users interface {\n\tGet(UserID, UserRequest, User, Username)\n}\n\ntype Username string\n")
+	opts := GenOpts{PkgName: "fakes", ImplName: "*Impl", NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Users"), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (i *Impl) Get(id user.UserID, req user.UserRequest, u user.User, u1 user.Username) {"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in\n%s", want, buf.String())
+	}
+}
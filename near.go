@@ -0,0 +1,114 @@
+package goimpl
+
+import (
+	"go/types"
+	"sort"
+)
+
+// Candidate is a type that implements an interface, or nearly does (see FindCandidates).
+type Candidate struct {
+	Type    types.Type // The named type, or a pointer to it if some of the methods have pointer receivers.
+	Missing []string   // Methods the type does not have.
+	Wrong   []string   // Methods the type has with another signature: "Name: has func(), want func() error".
+}
+
+// Distance is the number of methods to add or fix for the type to implement the interface.
+func (c Candidate) Distance() int {
+	return len(c.Missing) + len(c.Wrong)
+}
+
+// compare returns what the named type t is missing to implement inter.
+// found is the number of the methods of inter t has (with any signature).
+func compare(t *types.Named, inter *types.Interface) (c Candidate, found int) {
+	c.Type = t
+	qf := types.RelativeTo(t.Obj().Pkg())
+	for i := 0; i < inter.NumMethods(); i++ {
+		m := inter.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(t, true, m.Pkg(), m.Name())
+		f, ok := obj.(*types.Func)
+		if !ok {
+			c.Missing = append(c.Missing, m.Name())
+			continue
+		}
+		found++
+		sig := f.Type().(*types.Signature)
+		if recv := sig.Recv(); recv != nil {
+			if _, ptr := recv.Type().(*types.Pointer); ptr {
+				c.Type = types.NewPointer(t)
+			}
+		}
+		if !types.Identical(sig, m.Type()) {
+			c.Wrong = append(c.Wrong, m.Name()+": has "+types.TypeString(sig, qf)+", want "+types.TypeString(m.Type(), qf))
+		}
+	}
+	return c, found
+}
+
+// FindCandidates returns the named types declared in pkgs that have some of the methods of inter
+// and miss at most max of them (missing or with another signature), the closest first.
+// The types that implement inter have a zero Distance.
+func FindCandidates(inter *types.Interface, pkgs []*types.Package, max int) []Candidate {
+	var cs []Candidate
+	for _, pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			t, ok := tn.Type().(*types.Named)
+			if !ok || t.TypeParams().Len() > 0 || types.IsInterface(t) {
+				continue
+			}
+			if c, found := compare(t, inter); found > 0 && c.Distance() <= max {
+				cs = append(cs, c)
+			}
+		}
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Distance() < cs[j].Distance() })
+	return cs
+}
+
+// Match is an interface a type implements, or nearly does (see FindInterfaces).
+type Match struct {
+	Interface *types.TypeName
+	Candidate // What the type misses.
+}
+
+// FindInterfaces returns the interfaces declared in pkgs that the named type t has some of the methods of
+// and misses at most max of, the closest first. Only the interfaces t could implement are considered:
+// exported (unless declared in the package of t), with methods, and without type parameters.
+func FindInterfaces(t *types.Named, pkgs []*types.Package, max int) []Match {
+	var ms []Match
+	for _, pkg := range pkgs {
+		local := pkg == t.Obj().Pkg()
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !local && !tn.Exported() {
+				continue
+			}
+			if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+			inter, ok := tn.Type().Underlying().(*types.Interface)
+			if !ok || !inter.IsMethodSet() || inter.NumMethods() == 0 || !local && hasUnexported(inter) {
+				continue
+			}
+			if c, found := compare(t, inter); found > 0 && c.Distance() <= max {
+				ms = append(ms, Match{Interface: tn, Candidate: c})
+			}
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Distance() < ms[j].Distance() })
+	return ms
+}
+
+func hasUnexported(inter *types.Interface) bool {
+	for i := 0; i < inter.NumMethods(); i++ {
+		if !inter.Method(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
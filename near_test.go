@@ -0,0 +1,79 @@
+package goimpl
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nearSrc = `package near
+
+type File struct{}
+
+func (*File) Read(p []byte) (int, error) { return 0, nil }
+func (*File) Close() error               { return nil }
+
+type Reader struct{}
+
+func (Reader) Read(p []byte) (int, error) { return 0, nil }
+
+type Wrong struct{}
+
+func (Wrong) Read(p []byte) int { return 0 }
+func (Wrong) Close() error      { return nil }
+
+type Closer interface{ Close() error }
+
+type Empty struct{}
+`
+
+func TestFindCandidates(t *testing.T) {
+	pkg := typeCheck(t, "example.com/near", nearSrc)
+	io := typeCheck(t, "example.com/io", "package io\n\ntype ReadCloser interface {\n\tRead(p []byte) (int, error)\n\tClose() error\n}\n")
+	type candidate struct {
+		Type           string
+		Missing, Wrong []string
+	}
+	var got []candidate
+	for _, c := range FindCandidates(lookupInterface(t, io, "ReadCloser"), []*types.Package{pkg}, 1) {
+		got = append(got, candidate{types.TypeString(c.Type, nil), c.Missing, c.Wrong})
+	}
+	expected := []candidate{
+		{Type: "*example.com/near.File"},
+		{Type: "example.com/near.Reader", Missing: []string{"Close"}},
+		{Type: "example.com/near.Wrong", Wrong: []string{"Read: has func(p []byte) int, want func(p []byte) (int, error)"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestFindInterfaces(t *testing.T) {
+	pkg := typeCheck(t, "example.com/near", nearSrc)
+	io := typeCheck(t, "example.com/io", `package io
+
+type Reader interface{ Read(p []byte) (int, error) }
+type ReadCloser interface {
+	Reader
+	Close() error
+}
+type ReadWriteCloser interface {
+	ReadCloser
+	Write(p []byte) (int, error)
+}
+type Seeker interface{ Seek(offset int64, whence int) (int64, error) }
+type hidden interface{ Read(p []byte) (int, error) }
+`)
+	var got []string
+	for _, m := range FindInterfaces(pkg.Scope().Lookup("Reader").Type().(*types.Named), []*types.Package{io, pkg}, 1) {
+		got = append(got, m.Interface.Name()+" "+types.TypeString(m.Type, nil)+" "+strings.Join(m.Missing, ","))
+	}
+	expected := []string{
+		"Reader example.com/near.Reader ",
+		"ReadCloser example.com/near.Reader Close",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
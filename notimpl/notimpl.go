@@ -0,0 +1,32 @@
+// Package notimpl has the error returned (or panicked with) by the methods generated by goimpl
+// with GenOpts.StructuredErrors. It has no dependencies, so the generated code can use it.
+package notimpl
+
+import (
+	"runtime"
+	"strconv"
+)
+
+// Error tells which method is not implemented. Use errors.As to get it.
+type Error struct {
+	Interface string // Interface the method belongs to, e.g. io.Reader. Might be empty.
+	Type      string // Type the method is generated for, e.g. *Impl.
+	Method    string
+	Caller    string // file:line of the caller of the method. Might be empty.
+}
+
+func (e *Error) Error() string {
+	if e.Caller != "" {
+		return e.Type + "." + e.Method + " not implemented (called from " + e.Caller + ")"
+	}
+	return e.Type + "." + e.Method + " not implemented"
+}
+
+// Caller returns the file:line of the caller of the function calling Caller.
+func Caller() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return ""
+	}
+	return file + ":" + strconv.Itoa(line)
+}
@@ -0,0 +1,33 @@
+package notimpl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	err := fmt.Errorf("reading: %w", &Error{Interface: "io.Reader", Type: "*Impl", Method: "Read"})
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("errors.As failed for %v", err)
+	}
+	if e.Interface != "io.Reader" || e.Method != "Read" {
+		t.Errorf("unexpected %+v", e)
+	}
+	if err.Error() != "reading: *Impl.Read not implemented" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func stub() error {
+	return &Error{Type: "*Impl", Method: "Read", Caller: Caller()}
+}
+
+func TestCaller(t *testing.T) {
+	err := stub()
+	if !strings.Contains(err.Error(), "notimpl_test.go:") {
+		t.Errorf("no caller in %q", err.Error())
+	}
+}
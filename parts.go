@@ -0,0 +1,175 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Parts is the generated code split into pieces, so the methods can be inserted into an existing file
+// and the imports merged by the caller.
+type Parts struct {
+	Package string   `json:"package"`         // Package name.
+	Imports []Import `json:"imports"`         // Imports required by the declarations.
+	Type    string   `json:"type"`            // Declaration of the implementation type.
+	Decls   string   `json:"decls,omitempty"` // The other declarations before the methods (e.g. the types and the errors of a template).
+	Methods string   `json:"methods"`         // Method declarations (with comments).
+}
+
+// Import is a single import spec.
+type Import struct {
+	Name string `json:"name,omitempty"` // Empty unless the import is renamed.
+	Path string `json:"path"`
+}
+
+// GenerateParts is like GenerateBytes, but returns the imports and the declarations separately.
+// opts.Fragment is ignored.
+func GenerateParts(opts *GenOpts) (*Parts, error) {
+	bts, err := opts.generate()
+	if err != nil {
+		return nil, err
+	}
+	return SplitCode(bts)
+}
+
+// SplitCode splits the code generated by goimpl into parts.
+func SplitCode(src []byte) (*Parts, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "dummy.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, diagf(InvalidCode, "", "", "Error parsing generated code: %s", err.Error())
+	}
+	p := &Parts{Package: f.Name.Name, Imports: []Import{}}
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		i := Import{Path: path}
+		if imp.Name != nil {
+			i.Name = imp.Name.Name
+		}
+		p.Imports = append(p.Imports, i)
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	text := func(start, end token.Pos, doc *ast.CommentGroup) string {
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return string(src[offset(start):offset(end)])
+	}
+	recv := receiverType(f)
+	var decls []string
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			if d.Tok == token.TYPE && p.Type == "" && (recv == "" || declares(d, recv)) {
+				p.Type = text(d.Pos(), d.End(), d.Doc)
+			} else {
+				decls = append(decls, text(d.Pos(), d.End(), d.Doc))
+			}
+		case *ast.FuncDecl:
+			p.Decls = strings.Join(decls, "\n\n")
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			p.Methods = string(bytes.TrimSpace(src[offset(start):])) + "\n"
+			return p, nil
+		}
+	}
+	p.Decls = strings.Join(decls, "\n\n")
+	return p, nil
+}
+
+// receiverType returns the name of the type of the receiver of the first method in f (the implementation type).
+func receiverType(f *ast.File) string {
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 {
+			continue
+		}
+		t := fd.Recv.List[0].Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		switch x := t.(type) {
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		}
+		if id, ok := t.(*ast.Ident); ok {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+// declares reports whether the declaration declares name.
+func declares(d *ast.GenDecl, name string) bool {
+	for _, n := range declNames(d) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// declNames returns the names the declaration declares.
+func declNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, spec.Name.Name)
+		case *ast.ValueSpec:
+			for _, n := range spec.Names {
+				names = append(names, n.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Missing returns the declarations of p (Type and Decls) src does not declare, separated by blank lines:
+// the declarations to add to src with the methods.
+func (p *Parts) Missing(src []byte) (string, error) {
+	decls := strings.TrimSpace(p.Type + "\n\n" + p.Decls)
+	if decls == "" {
+		return "", nil
+	}
+	fset := token.NewFileSet()
+	full := "package p\n" + decls
+	d, err := parser.ParseFile(fset, "", full, parser.ParseComments)
+	if err != nil {
+		return "", diagf(InvalidCode, "", "", "Error parsing the declarations %q", decls)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return "", diagf(InvalidCode, "", "", "Error parsing the file: %s", err.Error())
+	}
+	var missing []string
+	for _, decl := range d.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		declared := true
+		for _, name := range declNames(gd) {
+			declared = declared && f.Scope.Lookup(name) != nil
+		}
+		if declared {
+			continue
+		}
+		start := gd.Pos()
+		if gd.Doc != nil {
+			start = gd.Doc.Pos()
+		}
+		missing = append(missing, full[fset.Position(start).Offset:fset.Position(gd.End()).Offset])
+	}
+	return strings.Join(missing, "\n\n"), nil
+}
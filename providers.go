@@ -0,0 +1,155 @@
+package goimpl
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/imports"
+)
+
+// Dependency injection frameworks (see ProvidersOpts.Framework).
+const (
+	ProvidersWire = "wire" // github.com/google/wire: a ProviderSet.
+	ProvidersFx   = "fx"   // go.uber.org/fx: a Module (fx.Options).
+)
+
+var providersImports = map[string]string{
+	ProvidersWire: "github.com/google/wire",
+	ProvidersFx:   "go.uber.org/fx",
+}
+
+// Binding binds an implementation to an interface it implements.
+type Binding struct {
+	Interface   string // package.interfaceTypeName, as for the generation.
+	Type        string // [*]typeName, declared in the package of the providers.
+	Constructor string // Function returning the Type. Generated if empty: NewTypeName (newTypeName for the unexported types).
+}
+
+// ProvidersOpts are the options of Providers.
+type ProvidersOpts struct {
+	Framework   string    // ProvidersWire (the default) or ProvidersFx.
+	PkgName     string    // Package of the providers (and the implementations).
+	Bindings    []Binding // Several bindings of the same type share the constructor.
+	Extra       []string  // Extra imports (of the interfaces).
+	Declared    []string  // Functions declared in the package. The constructors declared already are not generated.
+	NoGoImports bool      // No goimports if set. The interfaces need to be imported with Extra.
+}
+
+// Providers generates a file with the constructors of the implementations and a wire.ProviderSet (ProviderSet)
+// or an fx.Options (Module) binding them to their interfaces, so the generated fakes and wrappers can be injected.
+// The generated constructors return the zero value (a pointer to it for the pointer types).
+func Providers(opts ProvidersOpts) ([]byte, error) {
+	if opts.Framework == "" {
+		opts.Framework = ProvidersWire
+	}
+	framework, ok := providersImports[opts.Framework]
+	if !ok {
+		return nil, diagf(InvalidOptions, "", "use wire or fx.", "unknown providers framework %q", opts.Framework)
+	}
+	if len(opts.Bindings) == 0 {
+		return nil, diagf(InvalidOptions, "", "", "no implementations to provide")
+	}
+	var types []string
+	constructors := map[string]string{}
+	inters := map[string][]string{}
+	for _, b := range opts.Bindings {
+		name := strings.TrimPrefix(b.Type, "*")
+		if !token.IsIdentifier(name) {
+			return nil, diagf(InvalidImplName, b.Type, "give the type without the package and the type parameters.", "invalid type %q", b.Type)
+		}
+		if _, ok := constructors[b.Type]; !ok {
+			types = append(types, b.Type)
+			constructors[b.Type] = b.Constructor
+		}
+		if b.Constructor != "" {
+			constructors[b.Type] = b.Constructor
+		}
+		inters[b.Type] = append(inters[b.Type], opts.interName(b.Interface))
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "package %s\n\nimport (\n%s\n", opts.PkgName, strconv.Quote(framework))
+	for _, e := range opts.Extra {
+		fmt.Fprintln(buf, strconv.Quote(e))
+	}
+	buf.WriteString(")\n")
+	declared := map[string]bool{}
+	for _, f := range opts.Declared {
+		declared[f] = true
+	}
+	for _, t := range types {
+		if constructors[t] != "" {
+			continue
+		}
+		name := strings.TrimPrefix(t, "*")
+		if constructors[t] = constructorName(name); declared[constructors[t]] {
+			continue
+		}
+		lit := name + "{}"
+		if t != name {
+			lit = "&" + lit
+		}
+		fmt.Fprintf(buf, "\n// %s returns a new %s.\nfunc %s() %s {\nreturn %s\n}\n", constructors[t], name, constructors[t], t, lit)
+	}
+	if opts.Framework == ProvidersFx {
+		buf.WriteString("\n// Module provides the implementations as their interfaces.\nvar Module = fx.Options(\nfx.Provide(\n")
+		for _, t := range types {
+			as := make([]string, len(inters[t]))
+			for i, inter := range inters[t] {
+				as[i] = "fx.As(new(" + inter + "))"
+			}
+			fmt.Fprintf(buf, "fx.Annotate(%s, %s),\n", constructors[t], strings.Join(as, ", "))
+		}
+		buf.WriteString("),\n)\n")
+	} else {
+		buf.WriteString("\n// ProviderSet provides the implementations, bound to their interfaces.\nvar ProviderSet = wire.NewSet(\n")
+		for _, t := range types {
+			fmt.Fprintf(buf, "%s,\n", constructors[t])
+			for _, inter := range inters[t] {
+				fmt.Fprintf(buf, "wire.Bind(new(%s), new(%s)),\n", inter, t)
+			}
+		}
+		buf.WriteString(")\n")
+	}
+
+	if opts.NoGoImports {
+		b, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, diagf(InvalidCode, "", "", "Error formatting generated code: %s", err.Error()).
+				wrap(&ErrFormatFailed{Output: buf.Bytes(), Err: err})
+		}
+		return b, nil
+	}
+	b, err := imports.Process("dummy.go", buf.Bytes(), nil)
+	if err != nil {
+		return nil, diagf(InvalidCode, "", "add the imports to Extra, or set NoGoImports.", "Error fixing imports: %s", err.Error()).
+			wrap(fmt.Errorf("%w: %v", ErrImportsFailed, err))
+	}
+	return b, nil
+}
+
+// interName returns the interface as seen from the package of the providers.
+func (opts *ProvidersOpts) interName(inter string) string {
+	if parts := strings.SplitN(inter, ".", 2); len(parts) == 2 && parts[0] == opts.PkgName {
+		return parts[1]
+	}
+	return inter
+}
+
+// constructorName returns the name of the generated constructor of the type, exported if the type is.
+func constructorName(name string) string {
+	return prefixed("New", name)
+}
+
+// prefixed returns the name with the prefix, exported if the name is.
+func prefixed(prefix, name string) string {
+	if unicode.IsUpper([]rune(name)[0]) {
+		return prefix + name
+	}
+	return strings.ToLower(prefix[:1]) + prefix[1:] + strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,88 @@
+package goimpl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+	bindings := []Binding{
+		{Interface: "io.Reader", Type: "*fakeFile"},
+		{Interface: "io.Closer", Type: "*fakeFile"},
+		{Interface: "pkg.Store", Type: "MemStore", Constructor: "OpenMemStore"},
+		{Interface: "io.Writer", Type: "Buffer"},
+	}
+	tc := []struct {
+		framework string
+		expected  string
+	}{
+		{
+			framework: ProvidersWire,
+			expected: `package pkg
+
+import (
+	"github.com/google/wire"
+	"io"
+)
+
+// newFakeFile returns a new fakeFile.
+func newFakeFile() *fakeFile {
+	return &fakeFile{}
+}
+
+// ProviderSet provides the implementations, bound to their interfaces.
+var ProviderSet = wire.NewSet(
+	newFakeFile,
+	wire.Bind(new(io.Reader), new(*fakeFile)),
+	wire.Bind(new(io.Closer), new(*fakeFile)),
+	OpenMemStore,
+	wire.Bind(new(Store), new(MemStore)),
+	NewBuffer,
+	wire.Bind(new(io.Writer), new(Buffer)),
+)
+`,
+		},
+		{
+			framework: ProvidersFx,
+			expected: `package pkg
+
+import (
+	"go.uber.org/fx"
+	"io"
+)
+
+// newFakeFile returns a new fakeFile.
+func newFakeFile() *fakeFile {
+	return &fakeFile{}
+}
+
+// Module provides the implementations as their interfaces.
+var Module = fx.Options(
+	fx.Provide(
+		fx.Annotate(newFakeFile, fx.As(new(io.Reader)), fx.As(new(io.Closer))),
+		fx.Annotate(OpenMemStore, fx.As(new(Store))),
+		fx.Annotate(NewBuffer, fx.As(new(io.Writer))),
+	),
+)
+`,
+		},
+	}
+	for _, c := range tc {
+		b, err := Providers(ProvidersOpts{Framework: c.framework, PkgName: "pkg", Bindings: bindings, Extra: []string{"io"}, Declared: []string{"NewBuffer"}, NoGoImports: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != c.expected {
+			t.Errorf("%s: got\n%s\nexpected\n%s", c.framework, b, c.expected)
+		}
+	}
+
+	_, err := Providers(ProvidersOpts{Framework: "dig", PkgName: "pkg", Bindings: bindings})
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("unknown framework: got %v, expected an InvalidOptions diagnostic", err)
+	}
+	_, err = Providers(ProvidersOpts{PkgName: "pkg", Bindings: []Binding{{Interface: "io.Reader", Type: "other.Reader"}}})
+	if !errors.Is(err, ErrInvalidImplName) {
+		t.Errorf("qualified type: got %v, expected ErrInvalidImplName", err)
+	}
+}
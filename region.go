@@ -0,0 +1,70 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Markers of the regions managed by goimpl in hand-edited files (see ReplaceRegion).
+const (
+	RegionBegin = "// goimpl:begin" // Followed by the name of the region (the interface).
+	RegionEnd   = "// goimpl:end"
+)
+
+// ReplaceRegion replaces the content of the region called name in the Go source src with the generated parts
+// and adds the imports they need. The region is appended to the file if it is not there yet.
+// The declarations (the type, and the ones accompanying it) are only put in the region if they are not declared
+// in the rest of the file, so the type, the helpers and the hand-written methods can live next to the generated methods.
+func ReplaceRegion(src []byte, name string, p *Parts) ([]byte, error) {
+	begin := RegionBegin + " " + name
+	lines := strings.SplitAfter(string(src), "\n")
+	start, end := -1, -1
+	for i, l := range lines {
+		switch strings.TrimSpace(l) {
+		case begin:
+			if start >= 0 {
+				return nil, diagf(InvalidOptions, name, "", "more than one %q", begin)
+			}
+			start = i
+		case RegionEnd:
+			if start >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+	if start >= 0 && end < 0 {
+		return nil, diagf(InvalidOptions, name, "add "+RegionEnd+" after the region.", "%q without %q", begin, RegionEnd)
+	}
+	var before, after string
+	if start < 0 {
+		before = strings.TrimRight(string(src), "\n") + "\n\n"
+	} else {
+		before = strings.Join(lines[:start], "")
+		after = strings.Join(lines[end+1:], "")
+	}
+	missing, err := p.Missing([]byte(before + after))
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimSpace(missing + "\n\n" + p.Methods)
+	updated := before + begin + "\n" + content + "\n" + RegionEnd + "\n" + after
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", updated, parser.ParseComments)
+	if err != nil {
+		return nil, diagf(InvalidCode, name, "", "Error parsing the file with the region: %s", err.Error())
+	}
+	for _, imp := range p.Imports {
+		astutil.AddNamedImport(fset, f, imp.Name, imp.Path)
+	}
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, f); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
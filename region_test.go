@@ -0,0 +1,153 @@
+package goimpl
+
+import (
+	"reflect"
+	"testing"
+)
+
+const regionSrc = `package pkg
+
+import "fmt"
+
+type store struct{ name string }
+
+func (s *store) String() string { return fmt.Sprint(s.name) }
+
+// goimpl:begin io.ReadCloser
+func (s *store) Old() {}
+
+// goimpl:end
+
+func helper() {}
+`
+
+func TestReplaceRegion(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*store", Inter: reflect.TypeOf((*readCloser)(nil)).Elem(), NoGoImports: true,
+		NoNamedReturnValues: true, Unimplemented: UnimplementedError}
+	p, err := GenerateParts(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReplaceRegion([]byte(regionSrc), "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "replace", string(got), `package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+type store struct{ name string }
+
+func (s *store) String() string { return fmt.Sprint(s.name) }
+
+// goimpl:begin io.ReadCloser
+func (s *store) Close() error {
+	return errors.New("*store.Close not implemented")
+}
+
+func (s *store) Read(u []uint8) (int, error) {
+	return 0, errors.New("*store.Read not implemented")
+}
+
+// goimpl:end
+
+func helper() {}
+`)
+	again, err := ReplaceRegion(got, "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "idempotent", string(again), string(got))
+
+	added, err := ReplaceRegion([]byte("package pkg\n\nfunc helper() {}\n"), "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "append", string(added), `package pkg
+
+import "errors"
+
+func helper() {}
+
+// goimpl:begin io.ReadCloser
+type store struct{}
+
+func (s *store) Close() error {
+	return errors.New("*store.Close not implemented")
+}
+
+func (s *store) Read(u []uint8) (int, error) {
+	return 0, errors.New("*store.Read not implemented")
+}
+
+// goimpl:end
+`)
+
+	if _, err := ReplaceRegion([]byte("package pkg\n\n// goimpl:begin io.ReadCloser\n"), "io.ReadCloser", p); err == nil {
+		t.Error("expected an error for a region without the end")
+	}
+}
+
+type readCloser interface {
+	Read([]byte) (int, error)
+	Close() error
+}
+
+// eventsTemplate declares a type and a var accompanying the implementation, like the templates of the commands.
+const eventsTemplate = `{{$R := .}}package {{.PkgName}}
+
+import "errors"
+
+// {{.Clean .ImplName}}Event records a call.
+type {{.Clean .ImplName}}Event struct{ Method string }
+
+var errClosed = errors.New("closed")
+
+type {{.Clean .ImplName}} struct{ events []{{.Clean .ImplName}}Event }
+{{range $R.Methods .Inter}}
+func ({{$R.Receiver}} {{$R.ImplName}}) {{.Name}}({{range .Inputs}}{{.ArgName}} {{$R.GetName .}}{{.Sep}}{{end}}) ({{range .Outputs}}{{$R.GetName .}}{{.Sep}}{{end}}) {
+	{{$R.Receiver}}.events = append({{$R.Receiver}}.events, {{$R.Clean $R.ImplName}}Event{ {{printf "%q" .Name}} })
+	panic(errClosed)
+}
+{{end}}`
+
+func TestReplaceRegionDecls(t *testing.T) {
+	opts := GenOpts{PkgName: "pkg", ImplName: "*store", Inter: reflect.TypeOf((*readCloser)(nil)).Elem(), NoGoImports: true,
+		Template: eventsTemplate}
+	p, err := GenerateParts(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "type store struct{ events []storeEvent }" {
+		t.Errorf("expected the type of the methods, got %q", p.Type)
+	}
+	checkGenerated(t, "decls", p.Decls, "// storeEvent records a call.\ntype storeEvent struct{ Method string }\n\nvar errClosed = errors.New(\"closed\")")
+
+	added, err := ReplaceRegion([]byte("package pkg\n\nfunc helper() {}\n"), "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, "example.com/pkg", string(added))
+	again, err := ReplaceRegion(added, "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "idempotent", string(again), string(added))
+
+	// The type declared out of the region stays there, the other declarations go into the region.
+	declared, err := ReplaceRegion([]byte("package pkg\n\ntype store struct{ events []storeEvent }\n"), "io.ReadCloser", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, "example.com/pkg", string(declared))
+
+	opts.Fragment = true
+	fragment, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, "example.com/pkg", "package pkg\n\nimport \"errors\"\n\ntype store struct{ events []storeEvent }\n\n"+string(fragment))
+}
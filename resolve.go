@@ -0,0 +1,126 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// Target describes an implementation to generate, in the syntax of the command line.
+type Target struct {
+	Interface string // importpath.InterfaceName, e.g. io.Reader or github.com/sasha-s/goimpl/notimpl.Iface.
+	Type      string // [*][package.]TypeName[[typeParams]]: the type to generate.
+	Output    string // File to write the code to. Defaults to the lowercase type name with .go, in Dir.
+	Dir       string // Directory the packages are resolved from. The current one if empty.
+}
+
+// ResolveAndGenerate runs the whole pipeline of the command for the target without a bootstrap program:
+// it parses the target, loads the package of the interface from source with go/types,
+// generates and formats the implementation with opts and decides where it should be written.
+// opts.ImplName, opts.TypeParams and opts.PkgName (if the type has a package) are set from the target.
+// Unexported interfaces can be implemented in their own package.
+// Nothing is written: the caller gets the code and the output path.
+func ResolveAndGenerate(t Target, opts GenOpts) (code []byte, output string, err error) {
+	pkgPath, name, err := splitInterface(t.Interface)
+	if err != nil {
+		return nil, "", err
+	}
+	ptr, pkgName, typeName, typeParams := splitType(t.Type)
+	if typeName == "" {
+		return nil, "", diagf(InvalidOptions, t.Type, "use [*][package.]TypeName.", "no type name")
+	}
+	opts.ImplName, opts.TypeParams = ptr+typeName, typeParams
+	if pkgName != "" {
+		opts.PkgName = pkgName
+	}
+	dir := t.Dir
+	if dir == "" {
+		dir = "."
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, "", err
+	}
+	named, err := LoadInterface(t.Interface, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	inter := named.Underlying().(*types.Interface)
+	pkg := named.Obj().Pkg()
+	opts.InterName = pkg.Name() + "." + name
+	if opts.PkgName == "" {
+		opts.PkgName = pkg.Name()
+	}
+	if !named.Obj().Exported() && opts.PkgName != pkg.Name() {
+		return nil, "", diagf(UnexportedInterface, t.Interface, "generate the implementation in package "+pkg.Name()+".",
+			"unexported interface %s can only be implemented in package %s", name, pkg.Name())
+	}
+	if opts.PkgName != pkg.Name() {
+		opts.Extra = append(opts.Extra, pkgPath)
+	}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, inter, nil, &buf); err != nil {
+		return nil, "", err
+	}
+	output = t.Output
+	if output == "" {
+		output = strings.ToLower(typeName) + ".go"
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	return buf.Bytes(), output, nil
+}
+
+// LoadInterface loads the package of the interface (importpath.InterfaceName) with LoadPackage,
+// as seen from the module in dir, and returns the interface.
+// Unlike reflection, it keeps the names of the parameters and needs no bootstrap program.
+func LoadInterface(inter, dir string) (*types.Named, error) {
+	pkgPath, name, err := splitInterface(inter)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := loadPackage(pkgPath, dir)
+	if err != nil {
+		return nil, err
+	}
+	return InterfaceOf(pkg, name)
+}
+
+// InterfaceOf returns the interface declared in the package, e.g. loaded by LoadPackage or LoadExport.
+func InterfaceOf(pkg *types.Package, name string) (*types.Named, error) {
+	inter := pkg.Path() + "." + name
+	tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, diagf(UnresolvableType, inter, "", "%s is not a type in %s", name, pkg.Path())
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok || !types.IsInterface(named) {
+		return nil, diagf(NotAnInterface, inter, "", "%s is %s, not an interface", inter, tn.Type().Underlying())
+	}
+	return named, nil
+}
+
+// splitInterface splits importpath.InterfaceName.
+func splitInterface(s string) (pkgPath, name string, err error) {
+	i := strings.LastIndex(s, ".")
+	if i <= strings.LastIndex(s, "/") || i == len(s)-1 {
+		return "", "", diagf(InvalidOptions, s, "use importpath.InterfaceName, e.g. io.Reader.", "bad interface")
+	}
+	return s[:i], s[i+1:], nil
+}
+
+// splitType splits [*][package.]TypeName[[typeParams]].
+func splitType(s string) (ptr, pkg, name, typeParams string) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "*") {
+		ptr, s = "*", s[1:]
+	}
+	if i := strings.Index(s, "["); i >= 0 {
+		s, typeParams = s[:i], s[i:]
+	}
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		pkg, s = s[:i], s[i+1:]
+	}
+	return ptr, pkg, s, typeParams
+}
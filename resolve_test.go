@@ -0,0 +1,71 @@
+package goimpl
+
+import (
+	"errors"
+	"go/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveAndGenerate(t *testing.T) {
+	code, output, err := ResolveAndGenerate(Target{Interface: "io.ReadCloser", Type: "*fakes.reader"}, GenOpts{NoNamedReturnValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "io.ReadCloser", string(code), `package fakes
+
+import (
+	"errors"
+)
+
+type reader struct{}
+
+func (r *reader) Close() error {
+	panic(errors.New("*reader.Close not implemented"))
+}
+
+func (r *reader) Read(b []byte) (int, error) {
+	panic(errors.New("*reader.Read not implemented"))
+}
+`)
+	if abs, _ := filepath.Abs("reader.go"); output != abs {
+		t.Errorf("expected the output in %s, got %s", abs, output)
+	}
+
+	cases := []struct {
+		target Target
+		code   Code
+	}{
+		{Target{Interface: "io.Nope", Type: "T"}, UnresolvableType},
+		{Target{Interface: "io.SeekWhence", Type: "T"}, UnresolvableType},
+		{Target{Interface: "io.EOF", Type: "T"}, UnresolvableType},
+		{Target{Interface: "bytes.Buffer", Type: "T"}, NotAnInterface},
+		{Target{Interface: "io", Type: "T"}, InvalidOptions},
+		{Target{Interface: "io.Reader", Type: "*"}, InvalidOptions},
+		{Target{Interface: "strings.replacer", Type: "fakes.T"}, UnexportedInterface},
+	}
+	// An unexported interface can be implemented in its own package.
+	if _, _, err := ResolveAndGenerate(Target{Interface: "strings.replacer", Type: "T"}, GenOpts{}); err != nil {
+		t.Errorf("strings.replacer in package strings: %v", err)
+	}
+	for _, c := range cases {
+		_, _, err := ResolveAndGenerate(c.target, GenOpts{})
+		if d, ok := err.(*Diagnostic); !ok || d.Code != c.code {
+			t.Errorf("%+v: expected a %s diagnostic, got %v", c.target, c.code, err)
+		}
+	}
+}
+
+func TestLoadInterface(t *testing.T) {
+	named, err := LoadInterface("io.Writer", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := named.Underlying().(*types.Interface).Method(0).Type().(*types.Signature)
+	if name := sig.Params().At(0).Name(); name != "p" {
+		t.Errorf("expected the name of the parameter of io.Writer.Write to be p, got %q", name)
+	}
+	if _, err := LoadInterface("bytes.Buffer", "."); !errors.Is(err, ErrNotAnInterface) {
+		t.Errorf("bytes.Buffer: expected ErrNotAnInterface, got %v", err)
+	}
+}
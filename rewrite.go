@@ -0,0 +1,226 @@
+package goimpl
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rewrite rules (see GenOpts.Rewrites) work like gofmt -r: single lowercase letters in the pattern are wildcards
+// matching any expression, the same wildcard in the replacement stands for the matched expression.
+
+// rewriteRule is a parsed rewrite rule.
+type rewriteRule struct {
+	pattern, replace ast.Expr
+}
+
+// parseRewrite parses "pattern -> replacement".
+func parseRewrite(rule string) (rewriteRule, error) {
+	f := strings.Split(rule, "->")
+	if len(f) != 2 {
+		return rewriteRule{}, diagf(InvalidOptions, rule, "use 'pattern -> replacement', e.g. 'interface{} -> any'.", "bad rewrite rule")
+	}
+	pattern, err := parser.ParseExpr(strings.TrimSpace(f[0]))
+	if err != nil {
+		return rewriteRule{}, diagf(InvalidOptions, rule, "", "bad pattern in the rewrite rule: %s", err.Error())
+	}
+	replace, err := parser.ParseExpr(strings.TrimSpace(f[1]))
+	if err != nil {
+		return rewriteRule{}, diagf(InvalidOptions, rule, "", "bad replacement in the rewrite rule: %s", err.Error())
+	}
+	return rewriteRule{pattern, replace}, nil
+}
+
+// apply rewrites the file.
+func (r rewriteRule) apply(f *ast.File) *ast.File {
+	m := map[string]reflect.Value{}
+	pat := reflect.ValueOf(r.pattern)
+	repl := reflect.ValueOf(r.replace)
+	var rewriteVal func(val reflect.Value) reflect.Value
+	rewriteVal = func(val reflect.Value) reflect.Value {
+		if !val.IsValid() {
+			return reflect.Value{}
+		}
+		val = applyRewrite(rewriteVal, val)
+		for k := range m {
+			delete(m, k)
+		}
+		if match(m, pat, val) {
+			val = subst(m, repl, reflect.ValueOf(val.Interface().(ast.Node).Pos()))
+		}
+		return val
+	}
+	return applyRewrite(rewriteVal, reflect.ValueOf(f)).Interface().(*ast.File)
+}
+
+var (
+	objectPtrNil = reflect.ValueOf((*ast.Object)(nil))
+	scopePtrNil  = reflect.ValueOf((*ast.Scope)(nil))
+
+	identType     = reflect.TypeOf((*ast.Ident)(nil))
+	objectPtrType = reflect.TypeOf((*ast.Object)(nil))
+	positionType  = reflect.TypeOf(token.NoPos)
+	callExprType  = reflect.TypeOf((*ast.CallExpr)(nil))
+	scopePtrType  = reflect.TypeOf((*ast.Scope)(nil))
+)
+
+// applyRewrite replaces every child of val with f(child) and returns val.
+func applyRewrite(f func(reflect.Value) reflect.Value, val reflect.Value) reflect.Value {
+	if !val.IsValid() {
+		return reflect.Value{}
+	}
+	// The objects and scopes introduce cycles and are likely incorrect after the rewrite: drop them.
+	if val.Type() == objectPtrType {
+		return objectPtrNil
+	}
+	if val.Type() == scopePtrType {
+		return scopePtrNil
+	}
+	switch v := reflect.Indirect(val); v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			setValue(e, f(e))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			e := v.Field(i)
+			setValue(e, f(e))
+		}
+	case reflect.Interface:
+		e := v.Elem()
+		setValue(v, f(e))
+	}
+	return val
+}
+
+func setValue(x, y reflect.Value) {
+	if !y.IsValid() || !x.CanSet() {
+		return
+	}
+	x.Set(y)
+}
+
+func isWildcard(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size == len(s) && unicode.IsLower(r)
+}
+
+// match reports whether pattern matches val, recording the wildcards in m.
+func match(m map[string]reflect.Value, pattern, val reflect.Value) bool {
+	// A wildcard matches any expression. If it appears more than once in the pattern, it must match the same expression.
+	if m != nil && pattern.IsValid() && pattern.Type() == identType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if isWildcard(name) && val.IsValid() {
+			if _, ok := val.Interface().(ast.Expr); ok && !val.IsNil() {
+				if old, ok := m[name]; ok {
+					return match(nil, old, val)
+				}
+				m[name] = val
+				return true
+			}
+		}
+	}
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+	if pattern.Type() != val.Type() {
+		return false
+	}
+	switch pattern.Type() {
+	case identType:
+		// Only the names matter.
+		p, v := pattern.Interface().(*ast.Ident), val.Interface().(*ast.Ident)
+		return p == nil && v == nil || p != nil && v != nil && p.Name == v.Name
+	case objectPtrType, positionType:
+		return true
+	case callExprType:
+		// f(x) and f(x...) are different.
+		p, v := pattern.Interface().(*ast.CallExpr), val.Interface().(*ast.CallExpr)
+		if p.Ellipsis.IsValid() != v.Ellipsis.IsValid() {
+			return false
+		}
+	}
+	p, v := reflect.Indirect(pattern), reflect.Indirect(val)
+	if !p.IsValid() || !v.IsValid() {
+		return !p.IsValid() && !v.IsValid()
+	}
+	switch p.Kind() {
+	case reflect.Slice:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for i := 0; i < p.Len(); i++ {
+			if !match(m, p.Index(i), v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < p.NumField(); i++ {
+			if !match(m, p.Field(i), v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Interface:
+		return match(m, p.Elem(), v.Elem())
+	}
+	return p.Interface() == v.Interface()
+}
+
+// subst returns a copy of pattern with the wildcards replaced by the matched expressions from m
+// and the positions set to pos.
+func subst(m map[string]reflect.Value, pattern reflect.Value, pos reflect.Value) reflect.Value {
+	if !pattern.IsValid() {
+		return reflect.Value{}
+	}
+	if m != nil && pattern.Type() == identType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if isWildcard(name) {
+			if old, ok := m[name]; ok {
+				return subst(nil, old, reflect.Value{})
+			}
+		}
+	}
+	if pos.IsValid() && pattern.Type() == positionType {
+		if old := pattern.Interface().(token.Pos); !old.IsValid() {
+			return pattern
+		}
+		return pos
+	}
+	switch p := pattern; p.Kind() {
+	case reflect.Slice:
+		if p.IsNil() {
+			return reflect.Zero(p.Type())
+		}
+		v := reflect.MakeSlice(p.Type(), p.Len(), p.Len())
+		for i := 0; i < p.Len(); i++ {
+			v.Index(i).Set(subst(m, p.Index(i), pos))
+		}
+		return v
+	case reflect.Struct:
+		v := reflect.New(p.Type()).Elem()
+		for i := 0; i < p.NumField(); i++ {
+			v.Field(i).Set(subst(m, p.Field(i), pos))
+		}
+		return v
+	case reflect.Ptr:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(subst(m, elem, pos).Addr())
+		}
+		return v
+	case reflect.Interface:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(subst(m, elem, pos))
+		}
+		return v
+	}
+	return pattern
+}
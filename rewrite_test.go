@@ -0,0 +1,51 @@
+package goimpl
+
+import (
+	"net/rpc"
+	"reflect"
+	"testing"
+)
+
+func TestRewrites(t *testing.T) {
+	opts := GenOpts{
+		PkgName:             "pkg",
+		ImplName:            "Impl",
+		Inter:               reflect.TypeOf((*rpc.ClientCodec)(nil)).Elem(),
+		NoGoImports:         true,
+		NoNamedReturnValues: true,
+		Rewrites:            []string{"interface{} -> any", "panic(x) -> panic(\"todo: \" + x.Error())"},
+	}
+	b, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "rewrites", string(b), `package pkg
+
+import (
+	"errors"
+	"net/rpc"
+)
+
+type Impl struct{}
+
+func (i Impl) Close() error {
+	panic("todo: " + errors.New("Impl.Close not implemented").Error())
+}
+
+func (i Impl) ReadResponseBody(i1 any) error {
+	panic("todo: " + errors.New("Impl.ReadResponseBody not implemented").Error())
+}
+
+func (i Impl) ReadResponseHeader(r *rpc.Response) error {
+	panic("todo: " + errors.New("Impl.ReadResponseHeader not implemented").Error())
+}
+
+func (i Impl) WriteRequest(r *rpc.Request, i1 any) error {
+	panic("todo: " + errors.New("Impl.WriteRequest not implemented").Error())
+}
+`)
+	opts.Rewrites = []string{"interface{}"}
+	if _, err := GenerateBytes(&opts); err == nil {
+		t.Error("expected an error for a rule without ->")
+	}
+}
@@ -0,0 +1,78 @@
+package goimpl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sidecar holds the per-method options kept next to the code, e.g.
+//
+//	methods:
+//	  Close:
+//	    comment: Close releases the connection.
+//	    body: return nil
+//	  Flush:
+//	    skip: true
+//
+// JSON is accepted too.
+type Sidecar struct {
+	Methods map[string]MethodOptions `yaml:"methods" json:"methods"`
+}
+
+// MethodOptions are the options of a single method.
+type MethodOptions struct {
+	Comment string `yaml:"comment,omitempty" json:"comment,omitempty"` // Same as GenOpts.Comments.
+	Skip    bool   `yaml:"skip,omitempty" json:"skip,omitempty"`       // Same as GenOpts.MethodBlacklist.
+
+	Unimplemented string `yaml:"unimplemented,omitempty" json:"unimplemented,omitempty"` // Same as GenOpts.UnimplementedFor.
+	Body          string `yaml:"body,omitempty" json:"body,omitempty"`                   // Same as GenOpts.Bodies.
+}
+
+// LoadSidecar reads a sidecar file. Unknown keys are errors, so typos do not go unnoticed.
+func LoadSidecar(file string) (*Sidecar, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sidecar{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && err != io.EOF {
+		return nil, diagf(InvalidOptions, file, "", "Error parsing sidecar: %s", err.Error())
+	}
+	return s, nil
+}
+
+// Apply sets the comments, the blacklist, the unimplemented modes and the bodies of opts.
+// The comments, the modes and the bodies already in opts take precedence.
+func (s *Sidecar) Apply(opts *GenOpts) {
+	if opts.Comments == nil {
+		opts.Comments = map[string]string{}
+	}
+	if opts.MethodBlacklist == nil {
+		opts.MethodBlacklist = map[string]struct{}{}
+	}
+	if opts.UnimplementedFor == nil {
+		opts.UnimplementedFor = map[string]string{}
+	}
+	if opts.Bodies == nil {
+		opts.Bodies = map[string]string{}
+	}
+	for name, m := range s.Methods {
+		if _, ok := opts.Comments[name]; !ok && m.Comment != "" {
+			opts.Comments[name] = m.Comment
+		}
+		if _, ok := opts.UnimplementedFor[name]; !ok && m.Unimplemented != "" {
+			opts.UnimplementedFor[name] = m.Unimplemented
+		}
+		if _, ok := opts.Bodies[name]; !ok && m.Body != "" {
+			opts.Bodies[name] = m.Body
+		}
+		if m.Skip {
+			opts.MethodBlacklist[name] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package goimpl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSidecar(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"rwc.yaml": `methods:
+  Close:
+    comment: Close releases the connection.
+  Write:
+    skip: true
+`,
+		"rwc.json": `{"methods": {"Close": {"comment": "Close releases the connection."}, "Write": {"skip": true}}}`,
+		"typo.yaml": `methods:
+  Close:
+    coment: Close releases the connection.
+`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `package pkg
+
+import (
+	"errors"
+)
+
+type Impl struct{}
+
+// Close releases the connection.
+func (i Impl) Close() error {
+	panic(errors.New("Impl.Close not implemented"))
+}
+
+func (i Impl) Read(u []uint8) (int, error) {
+	panic(errors.New("Impl.Read not implemented"))
+}
+`
+	for _, name := range []string{"rwc.yaml", "rwc.json"} {
+		var buf bytes.Buffer
+		opts := GenOpts{
+			PkgName:             "pkg",
+			ImplName:            "Impl",
+			Inter:               reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem(),
+			NoNamedReturnValues: true,
+			Sidecar:             filepath.Join(dir, name),
+		}
+		if err := Generate(&opts, &buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkGenerated(t, name, buf.String(), expected)
+	}
+	var d *Diagnostic
+	if _, err := LoadSidecar(filepath.Join(dir, "typo.yaml")); !errors.As(err, &d) || d.Code != InvalidOptions {
+		t.Errorf("typo.yaml: expected an InvalidOptions diagnostic, got %v", err)
+	}
+}
+
+func TestUnknownMethods(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rwc.yaml")
+	if err := ioutil.WriteFile(file, []byte("methods:\n  Clsoe:\n    body: return nil\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inter := reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem()
+	for name, opts := range map[string]GenOpts{
+		"sidecar":          {Sidecar: file},
+		"Bodies":           {Bodies: map[string]string{"Wrtie": "return 0, nil"}},
+		"UnimplementedFor": {UnimplementedFor: map[string]string{"Raed": UnimplementedZero}},
+	} {
+		opts.PkgName, opts.ImplName, opts.Inter = "pkg", "Impl", inter
+		var d *Diagnostic
+		if err := Generate(&opts, &bytes.Buffer{}); !errors.As(err, &d) || d.Code != InvalidOptions {
+			t.Errorf("%s: expected an InvalidOptions diagnostic, got %v", name, err)
+		}
+	}
+}
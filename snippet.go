@@ -0,0 +1,103 @@
+package goimpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// Snippet formats.
+const (
+	SnippetVSCode    = "vscode"    // VSCode JSON snippet file.
+	SnippetUltiSnips = "ultisnips" // UltiSnips (vim) snippet file.
+)
+
+// Snippet renders the methods of the code generated by goimpl as an editor snippet,
+// with a tab stop at the body of every method. prefix is the trigger of the snippet.
+func Snippet(format, prefix string, code []byte) ([]byte, error) {
+	p, err := SplitCode(code)
+	if err != nil {
+		return nil, err
+	}
+	var escape func(s string, placeholder bool) string
+	switch format {
+	case SnippetVSCode:
+		escape = escapeVSCode
+	case SnippetUltiSnips:
+		escape = escapeUltiSnips
+	default:
+		return nil, diagf(InvalidOptions, "", "", "unknown snippet format %q, want %q or %q", format, SnippetVSCode, SnippetUltiSnips)
+	}
+	methods := p.Methods
+	if p.Decls != "" {
+		methods = p.Decls + "\n\n" + methods
+	}
+	body, err := snippetBody(methods, escape)
+	if err != nil {
+		return nil, err
+	}
+	description := fmt.Sprintf("goimpl: methods of %s", strings.TrimPrefix(p.Type, "type "))
+	if format == SnippetUltiSnips {
+		return []byte(fmt.Sprintf("snippet %s %q b\n%s\nendsnippet\n", prefix, description, body)), nil
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{
+		prefix: map[string]interface{}{
+			"prefix":      prefix,
+			"body":        strings.Split(body, "\n"),
+			"description": description,
+		},
+	}, "", "\t")
+	return append(b, '\n'), err
+}
+
+// snippetBody replaces the bodies of the methods with placeholders.
+func snippetBody(methods string, escape func(s string, placeholder bool) string) (string, error) {
+	const header = "package p\n"
+	src := header + methods
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "dummy.go", src, parser.ParseComments)
+	if err != nil {
+		return "", diagf(InvalidCode, "", "", "Error parsing generated code: %s", err.Error())
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	buf := new(bytes.Buffer)
+	last := len(header)
+	n := 0
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		n++
+		start, end := offset(fd.Body.Lbrace)+1, offset(fd.Body.Rbrace)
+		buf.WriteString(escape(src[last:start], false))
+		fmt.Fprintf(buf, "\n\t${%d:%s}\n", n, escape(strings.TrimSpace(src[start:end]), true))
+		last = end
+	}
+	buf.WriteString(escape(strings.TrimRight(src[last:], "\n"), false))
+	buf.WriteString("\n$0")
+	return buf.String(), nil
+}
+
+func escapeVSCode(s string, placeholder bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "$", `\$`, -1)
+	if placeholder {
+		s = strings.Replace(s, "}", `\}`, -1)
+	}
+	return s
+}
+
+func escapeUltiSnips(s string, placeholder bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "$", `\$`, -1)
+	s = strings.Replace(s, "`", "\\`", -1)
+	if placeholder {
+		s = strings.Replace(s, "}", `\}`, -1)
+	}
+	return s
+}
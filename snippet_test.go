@@ -0,0 +1,65 @@
+package goimpl
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	opts := GenOpts{
+		PkgName:             "pkg",
+		ImplName:            "*Impl",
+		Inter:               reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+		NoNamedReturnValues: true,
+		NoGoImports:         true,
+		Comments:            map[string]string{"String": "Costs $1."},
+	}
+	code, err := GenerateBytes(&opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: SnippetVSCode,
+			expected: `{
+	"impl": {
+		"body": [
+			"// Costs \\$1.",
+			"func (i *Impl) String() string {",
+			"\t${1:panic(errors.New(\"*Impl.String not implemented\"))}",
+			"}",
+			"$0"
+		],
+		"description": "goimpl: methods of Impl struct{}",
+		"prefix": "impl"
+	}
+}
+`,
+		},
+		{
+			format: SnippetUltiSnips,
+			expected: `snippet impl "goimpl: methods of Impl struct{}" b
+// Costs \$1.
+func (i *Impl) String() string {
+	${1:panic(errors.New("*Impl.String not implemented"))}
+}
+$0
+endsnippet
+`,
+		},
+	}
+	for _, c := range tc {
+		b, err := Snippet(c.format, "impl", code)
+		if err != nil {
+			t.Errorf("%s: %v", c.format, err)
+		}
+		checkGenerated(t, c.format, string(b), c.expected)
+	}
+	if _, err := Snippet("emacs", "impl", code); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
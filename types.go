@@ -0,0 +1,207 @@
+package goimpl
+
+import (
+	"go/types"
+	"io"
+	"strings"
+)
+
+// typesInter is an interface described by go/types.
+type typesInter struct {
+	inter *types.Interface
+	pkg   *types.Package // Package the code is generated in. Might be nil.
+	// Type parameters of the interface renamed to the ones of the generated type (see GenOpts.TypeParams).
+	rename map[string]string
+}
+
+// GenerateFromTypes generates an empty implementation of the interface described by go/types.
+// pkg is the package the code is generated in: the types from it are not qualified.
+// If pkg is nil, the types from the package named opts.PkgName are not qualified.
+// opts.Inter and opts.Existing are not supported.
+func GenerateFromTypes(opts *GenOpts, inter *types.Interface, pkg *types.Package, out io.Writer) error {
+	if opts.Inter != nil || opts.Existing != nil {
+		return diagf(InvalidOptions, "", "", "Inter and Existing are not supported with go/types.")
+	}
+	var rename map[string]string
+	if opts.TypeParams == "" {
+		if err := checkTypesInter(inter); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if rename, err = opts.renameTypeParams(inter); err != nil {
+			return err
+		}
+	}
+	if opts.PkgName == "" && pkg != nil {
+		opts.PkgName = pkg.Name()
+	}
+	if err := opts.checkTypesMethods(inter, pkg); err != nil {
+		return err
+	}
+	opts.types = &typesInter{inter: inter.Complete(), pkg: pkg, rename: rename}
+	return Generate(opts, out)
+}
+
+func (opts *GenOpts) typesMethods() []Method {
+	it := opts.types.inter
+	m := make([]Method, 0, it.NumMethods())
+	rec := opts.Receiver()
+	for i := 0; i < it.NumMethods(); i++ {
+		f := it.Method(i)
+		if _, ok := opts.MethodBlacklist[f.Name()]; ok {
+			continue
+		}
+		mtd := opts.typesMethod(rec, f)
+		if c, ok := opts.Comments[f.Name()]; ok {
+			mtd.Comment = c
+		}
+		mtd.Calls = opts.calls[f.Name()]
+		if opts.Forward {
+			mtd.Delegate = "next"
+		}
+		mtd.NamedResults = opts.namedResults(mtd.Outputs)
+		opts.blankArgs(&mtd)
+		opts.lintComment(&mtd)
+		m = append(m, mtd)
+	}
+	return m
+}
+
+// typesMethod is like Method, for a method described by go/types.
+func (opts *GenOpts) typesMethod(recName string, f *types.Func) Method {
+	cur := map[string]struct{}{recName: struct{}{}} // Current names. Start with the name of receiver.
+	sig := f.Type().(*types.Signature)
+	reserved := opts.reservedNames(sig)
+	mtd := Method{Inputs: opts.typesArgs(sig.Params(), cur, reserved), Outputs: opts.typesArgs(sig.Results(), cur, reserved), Variadic: sig.Variadic()}
+	if mtd.Variadic {
+		mtd.Inputs[len(mtd.Inputs)-1].Variadic = true
+	}
+	mtd.Name = f.Name()
+	return mtd
+}
+
+// typesArgs names the arguments after their types,
+// or with ParamNames, keeps their names unless they are taken or reserved.
+func (opts *GenOpts) typesArgs(tuple *types.Tuple, cur map[string]struct{}, reserved map[string]bool) []Arg {
+	args := make([]Arg, tuple.Len())
+	last := len(args) - 1
+	for i := range args {
+		t := tuple.At(i).Type()
+		sep := ", "
+		if i == last {
+			sep = ""
+		}
+		name := tuple.At(i).Name()
+		if _, taken := cur[name]; !opts.ParamNames || name == "" || name == "_" || taken || reserved[name] {
+			name = opts.typesShort(t, cur, reserved)
+		} else {
+			cur[name] = struct{}{}
+		}
+		args[i] = Arg{T: t, ArgName: name, Sep: sep}
+	}
+	return args
+}
+
+// bodyIdents are the identifiers the generated bodies might use.
+var bodyIdents = []string{"context", "errors", "fmt", "log", "runtime", "notimpl", "callerFile", "callerLine"}
+
+// bodyReserved returns the identifiers the bodies might use: bodyIdents and the Locals of the template.
+func (opts *GenOpts) bodyReserved() map[string]bool {
+	reserved := map[string]bool{}
+	for _, id := range bodyIdents {
+		reserved[id] = true
+	}
+	for _, id := range opts.Locals {
+		reserved[id] = true
+	}
+	return reserved
+}
+
+// reservedNames returns the names the parameters can not have:
+// the identifiers the body might use and the names of the packages of the types of the signature.
+func (opts *GenOpts) reservedNames(sig *types.Signature) map[string]bool {
+	reserved := opts.bodyReserved()
+	qualifier := func(p *types.Package) string {
+		reserved[opts.qualifier(p.Name(), p.Path())] = true
+		return p.Name()
+	}
+	types.TypeString(sig, qualifier)
+	return reserved
+}
+
+// typesShort is like Short, for a type described by go/types.
+func (opts *GenOpts) typesShort(t types.Type, cur map[string]struct{}, reserved map[string]bool) string {
+	tt := t
+	for {
+		if p, ok := tt.(*types.Pointer); ok {
+			tt = p.Elem()
+		} else if s, ok := tt.(*types.Slice); ok {
+			tt = s.Elem()
+		} else {
+			break
+		}
+	}
+	pkg, name := typesPackageAndName(tt)
+	path := ""
+	if n, ok := tt.(interface{ Obj() *types.TypeName }); ok && n.Obj().Pkg() != nil {
+		path = n.Obj().Pkg().Path()
+	}
+	return opts.short(ArgType{Package: pkg, PkgPath: path, Name: name, Error: types.Implements(t, errorIface), Context: implementsCtx(t), T: t}, cur, reserved)
+}
+
+func typesPackageAndName(t types.Type) (pkgName, name string) {
+	switch t := t.(type) {
+	case *types.Alias:
+		if t.Obj().Pkg() != nil {
+			pkgName = t.Obj().Pkg().Name()
+		}
+		return pkgName, t.Obj().Name()
+	case *types.Named:
+		if t.Obj().Pkg() != nil {
+			pkgName = t.Obj().Pkg().Name()
+		}
+		return pkgName, t.Obj().Name()
+	case *types.Basic:
+		return "", t.Name()
+	case *types.Struct:
+		return "", "struct"
+	case *types.Interface:
+		return "", "interface"
+	default:
+		return "", types.TypeString(t, nil)
+	}
+}
+
+// implementsCtx reports whether t is or has the methods of context.Context.
+func implementsCtx(t types.Type) bool {
+	if n, ok := types.Unalias(t).(*types.Named); ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "context" && n.Obj().Name() == "Context" {
+		return true
+	}
+	ms := types.NewMethodSet(t)
+	for _, name := range []string{"Deadline", "Done", "Err", "Value"} {
+		if ms.Lookup(nil, name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+var errorIface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// typeString is like GetName, for a type described by go/types.
+func (opts *GenOpts) typeString(t types.Type) string {
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == opts.types.pkg || opts.types.pkg == nil && p.Name() == opts.PkgName {
+			return ""
+		}
+		return opts.qualifier(p.Name(), p.Path())
+	})
+	if len(opts.types.rename) > 0 && hasTypeParams(t) {
+		s = renameIdents(s, opts.types.rename)
+	}
+	if opts.UseAny {
+		s = strings.ReplaceAll(s, "interface{}", "any")
+	}
+	return s
+}
@@ -0,0 +1,232 @@
+package goimpl
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// typeCheck type checks the source of a single file package.
+func typeCheck(t *testing.T, path, src string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(path, fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func lookupInterface(t *testing.T, pkg *types.Package, name string) *types.Interface {
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil {
+		t.Fatalf("%s not found in %s", name, pkg.Path())
+	}
+	return obj.Type().Underlying().(*types.Interface)
+}
+
+func checkGenerated(t *testing.T, name, gen, expected string) {
+	if gen != expected {
+		d := diffmatchpatch.New()
+		diff := d.DiffToDelta(d.DiffMain(gen, expected, false))
+		t.Errorf("%s. expected:\n%s\n-----\nGot:\n%s\n-----\nDiff:\n%v", name, expected, gen, diff)
+	}
+}
+
+const storeSrc = `package store
+
+import (
+	"context"
+	"io"
+)
+
+type Item struct{}
+
+type Store interface {
+	Get(ctx context.Context, key string) (*Item, error)
+	Put(context.Context, *Item) error
+	Dumper
+}
+
+type Dumper interface {
+	Dump(w io.Writer, items map[string][]byte) error
+}
+`
+
+func TestGenerateFromTypes(t *testing.T) {
+	pkg := typeCheck(t, "example.com/store", storeSrc)
+	tc := []struct {
+		name     string
+		inter    string
+		opts     GenOpts
+		pkg      *types.Package
+		expected string
+	}{
+		{
+			name:  "same package",
+			inter: "Store",
+			opts:  GenOpts{ImplName: "*fake", NoNamedReturnValues: true, NoGoImports: true},
+			pkg:   pkg,
+			expected: `package store
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+type fake struct{}
+
+func (f *fake) Dump(w io.Writer, m map[string][]byte) error {
+	panic(errors.New("*fake.Dump not implemented"))
+}
+
+func (f *fake) Get(ctx context.Context, s string) (*Item, error) {
+	panic(errors.New("*fake.Get not implemented"))
+}
+
+func (f *fake) Put(ctx context.Context, i *Item) error {
+	panic(errors.New("*fake.Put not implemented"))
+}
+`,
+		},
+		{
+			name:  "other package",
+			inter: "Store",
+			opts:  GenOpts{PkgName: "fakes", ImplName: "Store", NoGoImports: true, MethodBlacklist: map[string]struct{}{"Dump": {}}},
+			expected: `package fakes
+
+import (
+	"context"
+	"errors"
+	"example.com/store"
+)
+
+type Store struct{}
+
+func (s Store) Get(ctx context.Context, s1 string) (i *store.Item, err error) {
+	panic(errors.New("Store.Get not implemented"))
+}
+
+func (s Store) Put(ctx context.Context, i *store.Item) (err error) {
+	panic(errors.New("Store.Put not implemented"))
+}
+`,
+		},
+		{
+			name:  "param names",
+			inter: "Store",
+			opts:  GenOpts{PkgName: "fakes", ImplName: "*fake", NoGoImports: true, ParamNames: true},
+			expected: `package fakes
+
+import (
+	"context"
+	"errors"
+	"example.com/store"
+	"io"
+)
+
+type fake struct{}
+
+func (f *fake) Dump(w io.Writer, items map[string][]byte) (err error) {
+	panic(errors.New("*fake.Dump not implemented"))
+}
+
+func (f *fake) Get(ctx context.Context, key string) (i *store.Item, err error) {
+	panic(errors.New("*fake.Get not implemented"))
+}
+
+func (f *fake) Put(ctx context.Context, i *store.Item) (err error) {
+	panic(errors.New("*fake.Put not implemented"))
+}
+`,
+		},
+		{
+			name:  "goimports",
+			inter: "Dumper",
+			opts:  GenOpts{PkgName: "fakes", ImplName: "dumper", NoNamedReturnValues: true},
+			expected: `package fakes
+
+import (
+	"errors"
+	"io"
+)
+
+type dumper struct{}
+
+func (d dumper) Dump(w io.Writer, m map[string][]byte) error {
+	panic(errors.New("dumper.Dump not implemented"))
+}
+`,
+		},
+	}
+	for _, c := range tc {
+		var buf bytes.Buffer
+		if err := GenerateFromTypes(&c.opts, lookupInterface(t, pkg, c.inter), c.pkg, &buf); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		checkGenerated(t, c.name, buf.String(), c.expected)
+	}
+}
+
+const cacheSrc = `package cache
+
+type Entry[V any] struct{ Value V }
+
+type Cache[K comparable, V any] interface {
+	Get(key K) (Entry[V], bool)
+	Put(key K, v V)
+	Keys() []K
+}
+`
+
+func TestGenericImpl(t *testing.T) {
+	pkg := typeCheck(t, "example.com/cache", cacheSrc)
+	opts := GenOpts{PkgName: "fakes", ImplName: "*memCache", TypeParams: "[Key comparable, Val any]", NoNamedReturnValues: true, NoGoImports: true}
+	var buf bytes.Buffer
+	if err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Cache"), nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, "generic", buf.String(), `package fakes
+
+import (
+	"errors"
+	"example.com/cache"
+)
+
+type memCache[Key comparable, Val any] struct{}
+
+func (m *memCache[Key, Val]) Get(k Key) (cache.Entry[Val], bool) {
+	panic(errors.New("*memCache.Get not implemented"))
+}
+
+func (m *memCache[Key, Val]) Keys() []Key {
+	panic(errors.New("*memCache.Keys not implemented"))
+}
+
+func (m *memCache[Key, Val]) Put(k Key, v Val) {
+	panic(errors.New("*memCache.Put not implemented"))
+}
+`)
+
+	opts = GenOpts{PkgName: "fakes", ImplName: "memCache", TypeParams: "[Key comparable]"}
+	err := GenerateFromTypes(&opts, lookupInterface(t, pkg, "Cache"), nil, &buf)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != GenericInterface {
+		t.Errorf("expected a GenericInterface diagnostic, got %v", err)
+	}
+	opts = GenOpts{PkgName: "fakes", ImplName: "memCache", TypeParams: "[Key comparable"}
+	err = GenerateFromTypes(&opts, lookupInterface(t, pkg, "Cache"), nil, &buf)
+	if d, ok := err.(*Diagnostic); !ok || d.Code != InvalidOptions {
+		t.Errorf("expected an InvalidOptions diagnostic, got %v", err)
+	}
+}